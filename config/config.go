@@ -2,17 +2,34 @@ package config
 
 import (
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds the application's configuration.
 type Config struct {
-	AppName  string
-	HttpPort string
-	BaseUrl  string
-	Redis    RedisConfig
-	Mongo    MongoConfig
+	AppName         string
+	HttpPort        string
+	BaseUrl         string
+	UserStore       string // "mock" or "postgres"
+	MessageStore    string // "mongo", "postgres" or "memory"
+	ModerationStore string // "mongo" or "postgres"
+	Redis           RedisConfig
+	Mongo           MongoConfig
+	Postgres        PostgresConfig
+	Auth            AuthConfig
+	Chat            ChatConfig
+	Filter          ContentFilterConfig
+	Presence        PresenceConfig
+	Inbox           InboxConfig
+	UserCache       UserCacheConfig
+	Storage         StorageConfig
+	Upload          UploadConfig
+	Thumbnail       ThumbnailConfig
+	Scanner         ScannerConfig
 }
 
 // RedisConfig holds Redis-specific connection details.
@@ -28,6 +45,116 @@ type MongoConfig struct {
 	Database string
 }
 
+// PostgresConfig holds PostgreSQL-specific connection details.
+type PostgresConfig struct {
+	DSN string
+}
+
+// AuthConfig holds the settings for issuing access and refresh tokens.
+type AuthConfig struct {
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// ChatConfig holds limits applied to chat messages.
+type ChatConfig struct {
+	AllowedMessageTypes []string
+	MaxMessageLength    int
+	DedupeWindow        time.Duration // How long client message IDs are remembered
+	HistoryCacheSize    int           // Messages cached per room and sent to connecting users
+	HistoryCacheTTL     time.Duration
+}
+
+// ContentFilterConfig holds the settings of the built-in content filters.
+// Modes are "mask", "flag" or "reject".
+type ContentFilterConfig struct {
+	Words          []string
+	WordsMode      string
+	BlockedDomains []string
+	DomainsMode    string
+}
+
+// PresenceConfig holds the settings for online/away tracking.
+type PresenceConfig struct {
+	IdleTimeout       time.Duration
+	SweepInterval     time.Duration
+	HeartbeatInterval time.Duration // How often this node refreshes its connections
+	NodeTTL           time.Duration // How long the connections of a node outlive its last heartbeat
+}
+
+// InboxConfig holds the settings for events queued for offline users.
+type InboxConfig struct {
+	TTL      time.Duration
+	MaxItems int
+}
+
+// UserCacheConfig holds the settings for the in-memory user cache.
+type UserCacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+// StorageConfig holds the settings of the file storage backend.
+type StorageConfig struct {
+	Backend   string // "local" or "s3"
+	LocalPath string
+	S3        S3StorageConfig
+
+	SignedURLs bool          // Serve attachments only through signed, expiring URLs
+	URLSecret  string        // Signs attachment URLs; required with SignedURLs, and must differ from the JWT secret
+	URLTTL     time.Duration // How long signed attachment URLs stay valid
+}
+
+// S3StorageConfig holds the settings of an S3-compatible file storage.
+type S3StorageConfig struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	PathStyle       bool
+	PublicBaseURL   string // Required unless SignedURLs is set, since presigned URLs expire
+	PresignExpiry   time.Duration
+	PartSize        int
+}
+
+// UploadConfig holds the rules enforced on uploaded files.
+// Types are MIME type patterns such as "image/png" or "image/*".
+type UploadConfig struct {
+	AllowedTypes   []string // Empty allows every type that is not denied
+	DeniedTypes    []string
+	SizeLimits     []string // Written as "pattern=size", e.g. "image/*=10MB"; the first match applies
+	DefaultMaxSize string   // Applies to types without a size limit, e.g. "4MB"; "0" disables the limit
+
+	ResumableExpiry time.Duration // How long resumable uploads are kept without receiving a chunk
+	SweepInterval   time.Duration // How often chunks of expired resumable uploads are deleted
+
+	GCInterval    time.Duration // How often files that no message references are deleted; zero disables it
+	GCGracePeriod time.Duration // How long unreferenced files are kept after they were uploaded or claimed
+	GCDryRun      bool          // Only log the files that would be deleted; set by default, so deleting is opt-in
+
+	UserQuota   string        // Bytes users may upload per period, e.g. "1GB"; "0" is unlimited
+	AdminQuota  string        // Bytes admins may upload per period; "0" is unlimited
+	QuotaPeriod time.Duration // How long after a user's first upload their usage is reset; zero never resets it
+}
+
+// ThumbnailConfig holds the settings for thumbnails of uploaded images.
+type ThumbnailConfig struct {
+	Sizes       []int // Bounding boxes in pixels; empty disables thumbnails
+	MaxPixels   int   // Larger images get no thumbnails
+	Concurrency int   // Images decoded at once across all uploads
+}
+
+// ScannerConfig holds the settings of the malware scanner that uploads go through.
+type ScannerConfig struct {
+	Backend      string        // "clamd" by default; "none" must be set explicitly to publish uploads unscanned
+	ClamdAddress string        // "tcp://host:port", "unix:///path/to/clamd.sock" or a socket path
+	Timeout      time.Duration // Limit for scanning a single file
+}
+
 // NewConfig initializes and loads the application configuration by explicitly
 // reading each key from the environment.
 func NewConfig() *Config {
@@ -49,9 +176,12 @@ func NewConfig() *Config {
 
 	// --- Explicitly Read and Populate Config ---
 	cfg := &Config{
-		AppName:  getEnv("APP_NAME", "WebSocketApp"),
-		HttpPort: getEnv("HTTP_PORT", "8080"),
-		BaseUrl:  getEnv("BASE_URL", "http://localhost:8080"),
+		AppName:         getEnv("APP_NAME", "WebSocketApp"),
+		HttpPort:        getEnv("HTTP_PORT", "8080"),
+		BaseUrl:         getEnv("BASE_URL", "http://localhost:8080"),
+		UserStore:       getEnv("USER_STORE", "mock"),
+		MessageStore:    getEnv("MESSAGE_STORE", "mongo"),
+		ModerationStore: getEnv("MODERATION_STORE", "mongo"),
 		Redis: RedisConfig{
 			URI:      getEnv("REDIS_URL", "localhost:6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
@@ -61,6 +191,88 @@ func NewConfig() *Config {
 			URI:      getEnv("MONGO_URI", "mongodb://localhost:27017"),
 			Database: getEnv("MONGO_DATABASE", "chat_db"),
 		},
+		Postgres: PostgresConfig{
+			DSN: getEnv("POSTGRES_DSN", "host=localhost user=postgres password=postgres dbname=chat_db port=5432 sslmode=disable"),
+		},
+		Auth: AuthConfig{
+			JWTSecret:       getEnv("JWT_SECRET", ""),
+			AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		},
+		Chat: ChatConfig{
+			AllowedMessageTypes: getEnvList("CHAT_ALLOWED_MESSAGE_TYPES", []string{"text", "file"}),
+			MaxMessageLength:    getEnvInt("CHAT_MAX_MESSAGE_LENGTH", 4000),
+			DedupeWindow:        getEnvDuration("CHAT_DEDUPE_WINDOW", 24*time.Hour),
+			HistoryCacheSize:    getEnvInt("CHAT_HISTORY_CACHE_SIZE", 100),
+			HistoryCacheTTL:     getEnvDuration("CHAT_HISTORY_CACHE_TTL", 10*time.Minute),
+		},
+		Filter: ContentFilterConfig{
+			Words:          getEnvList("CONTENT_FILTER_WORDS", nil),
+			WordsMode:      getEnv("CONTENT_FILTER_WORDS_MODE", "mask"),
+			BlockedDomains: getEnvList("CONTENT_FILTER_BLOCKED_DOMAINS", nil),
+			DomainsMode:    getEnv("CONTENT_FILTER_DOMAINS_MODE", "reject"),
+		},
+		Presence: PresenceConfig{
+			IdleTimeout:       getEnvDuration("PRESENCE_IDLE_TIMEOUT", 5*time.Minute),
+			SweepInterval:     getEnvDuration("PRESENCE_SWEEP_INTERVAL", 30*time.Second),
+			HeartbeatInterval: getEnvDuration("PRESENCE_HEARTBEAT_INTERVAL", 10*time.Second),
+			NodeTTL:           getEnvDuration("PRESENCE_NODE_TTL", 30*time.Second),
+		},
+		Inbox: InboxConfig{
+			TTL:      getEnvDuration("INBOX_TTL", 7*24*time.Hour),
+			MaxItems: getEnvInt("INBOX_MAX_ITEMS", 500),
+		},
+		UserCache: UserCacheConfig{
+			Size: getEnvInt("USER_CACHE_SIZE", 10000),
+			TTL:  getEnvDuration("USER_CACHE_TTL", 5*time.Minute),
+		},
+		Storage: StorageConfig{
+			Backend:   getEnv("FILE_STORAGE", "local"),
+			LocalPath: getEnv("FILE_STORAGE_PATH", "./uploads"),
+			S3: S3StorageConfig{
+				Endpoint:        getEnv("S3_ENDPOINT", "s3.amazonaws.com"),
+				Region:          getEnv("S3_REGION", "us-east-1"),
+				Bucket:          getEnv("S3_BUCKET", ""),
+				Prefix:          getEnv("S3_PREFIX", ""),
+				AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+				UseSSL:          getEnvBool("S3_USE_SSL", true),
+				PathStyle:       getEnvBool("S3_PATH_STYLE", false),
+				PublicBaseURL:   getEnv("S3_PUBLIC_BASE_URL", ""),
+				PresignExpiry:   getEnvDuration("S3_PRESIGN_EXPIRY", 7*24*time.Hour),
+				PartSize:        getEnvInt("S3_PART_SIZE", 16<<20),
+			},
+			SignedURLs: getEnvBool("FILE_SIGNED_URLS", true),
+			URLSecret:  getEnv("FILE_URL_SECRET", ""),
+			URLTTL:     getEnvDuration("FILE_URL_TTL", time.Hour),
+		},
+		Upload: UploadConfig{
+			AllowedTypes:   getEnvList("UPLOAD_ALLOWED_TYPES", nil),
+			DeniedTypes:    getEnvList("UPLOAD_DENIED_TYPES", []string{"text/html", "image/svg+xml", "application/javascript", "text/javascript"}),
+			SizeLimits:     getEnvList("UPLOAD_SIZE_LIMITS", nil),
+			DefaultMaxSize: getEnv("UPLOAD_MAX_SIZE", "4MB"),
+
+			ResumableExpiry: getEnvDuration("UPLOAD_RESUMABLE_EXPIRY", 24*time.Hour),
+			SweepInterval:   getEnvDuration("UPLOAD_SWEEP_INTERVAL", 15*time.Minute),
+
+			GCInterval:    getEnvDuration("UPLOAD_GC_INTERVAL", time.Hour),
+			GCGracePeriod: getEnvDuration("UPLOAD_GC_GRACE_PERIOD", 24*time.Hour),
+			GCDryRun:      getEnvBool("UPLOAD_GC_DRY_RUN", true),
+
+			UserQuota:   getEnv("UPLOAD_QUOTA_USER", "1GB"),
+			AdminQuota:  getEnv("UPLOAD_QUOTA_ADMIN", "0"),
+			QuotaPeriod: getEnvDuration("UPLOAD_QUOTA_PERIOD", 30*24*time.Hour),
+		},
+		Thumbnail: ThumbnailConfig{
+			Sizes:       getEnvIntList("THUMBNAIL_SIZES", []int{256, 1024}),
+			MaxPixels:   getEnvInt("THUMBNAIL_MAX_PIXELS", 16_000_000),
+			Concurrency: getEnvInt("THUMBNAIL_CONCURRENCY", 2),
+		},
+		Scanner: ScannerConfig{
+			Backend:      getEnv("SCANNER", "clamd"),
+			ClamdAddress: getEnv("CLAMD_ADDRESS", "tcp://localhost:3310"),
+			Timeout:      getEnvDuration("CLAMD_TIMEOUT", 30*time.Second),
+		},
 	}
 
 	return cfg
@@ -73,3 +285,61 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt is a helper to read an integer environment variable or return a default value.
+func getEnvInt(key string, defaultValue int) int {
+	if viper.IsSet(key) {
+		return viper.GetInt(key)
+	}
+	return defaultValue
+}
+
+// getEnvBool is a helper to read a boolean environment variable (e.g. "true") or return a default value.
+func getEnvBool(key string, defaultValue bool) bool {
+	if viper.IsSet(key) {
+		return viper.GetBool(key)
+	}
+	return defaultValue
+}
+
+// getEnvDuration is a helper to read a duration environment variable (e.g. "15m") or return a default value.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if viper.IsSet(key) {
+		return viper.GetDuration(key)
+	}
+	return defaultValue
+}
+
+// getEnvList is a helper to read a comma-separated environment variable or return a default value.
+func getEnvList(key string, defaultValue []string) []string {
+	value := viper.GetString(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvIntList is a helper to read a comma-separated list of integers or return a default value.
+func getEnvIntList(key string, defaultValue []int) []int {
+	items := getEnvList(key, nil)
+	if items == nil {
+		return defaultValue
+	}
+
+	values := make([]int, 0, len(items))
+	for _, item := range items {
+		value, err := strconv.Atoi(item)
+		if err != nil {
+			log.Fatalf("Invalid integer %q in %s", item, key)
+		}
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// InboxItem is an event queued for a user who was offline when it was sent.
+type InboxItem struct {
+	ID        string          `json:"id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+}
+
+// InboxDeliveryResponse wraps a queued event when it is delivered on reconnect.
+// Clients acknowledge it with its InboxID so that it is not delivered again.
+type InboxDeliveryResponse struct {
+	Event     string          `json:"event"`
+	InboxID   string          `json:"inboxId"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
@@ -17,6 +17,10 @@ type Message struct {
 	IsRead    bool               `bson:"is_read" json:"isRead"`
 	Type      string             `bson:"type" json:"type"` // "text" or "file"
 	Metadata  *FileMetadata      `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	Flags     []string           `bson:"flags,omitempty" json:"flags,omitempty"` // Why content filters flagged it for review
+	EditedAt  *time.Time         `bson:"edited_at,omitempty" json:"editedAt,omitempty"`
+
+	ClientMessageID string `bson:"client_message_id,omitempty" json:"clientMessageId,omitempty"` // Supplied by the sender for retries
 }
 
 // FileMetadata holds information about an uploaded file.
@@ -25,6 +29,16 @@ type FileMetadata struct {
 	FileName string `bson:"file_name" json:"fileName"`
 	FileSize int64  `bson:"file_size" json:"fileSize"`
 	MIMEType string `bson:"mime_type" json:"mimeType"`
+	SHA256   string `bson:"sha256,omitempty" json:"sha256,omitempty"` // Hex-encoded hash of the content
+
+	Thumbnails []Thumbnail `bson:"thumbnails,omitempty" json:"thumbnails,omitempty"` // Smallest first; only for images
+}
+
+// Thumbnail is a resized preview of an uploaded image.
+type Thumbnail struct {
+	URL    string `bson:"url" json:"url"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
 }
 
 // MessageResponse is a DTO for sending message data to clients.
@@ -41,4 +55,49 @@ type MessageResponse struct {
 	IsRead    bool               `json:"isRead"`
 	Type      string             `json:"type"`
 	Metadata  *FileMetadata      `json:"metadata,omitempty"`
+	EditedAt  *time.Time         `json:"editedAt,omitempty"`
+
+	ClientMessageID string `json:"clientMessageId,omitempty"`
+}
+
+// MessageDeletedResponse notifies a room that a message was deleted.
+type MessageDeletedResponse struct {
+	Event     string             `json:"event"`
+	RoomID    string             `json:"roomId"`
+	MessageID primitive.ObjectID `json:"messageId"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// MessageAckResponse confirms to the sender that a message with a client message ID was stored.
+type MessageAckResponse struct {
+	Event           string             `json:"event"`
+	ClientMessageID string             `json:"clientMessageId"`
+	MessageID       primitive.ObjectID `json:"messageId"`
+	RoomID          string             `json:"roomId"`
+	Timestamp       time.Time          `json:"timestamp"`
+}
+
+// MessageNackResponse tells the sender that a message with a client message ID was rejected.
+type MessageNackResponse struct {
+	Event           string    `json:"event"`
+	ClientMessageID string    `json:"clientMessageId"`
+	Code            int       `json:"code"`
+	Message         string    `json:"message"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// ErrorResponse is a DTO for reporting a rejected request back to a client.
+type ErrorResponse struct {
+	Event     string    `json:"event"`
+	Code      int       `json:"code"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UnreadUpdatedResponse notifies a user that their unread count for a room has changed.
+type UnreadUpdatedResponse struct {
+	Event       string    `json:"event"`
+	RoomID      string    `json:"roomId"`
+	UnreadCount int64     `json:"unreadCount"`
+	Timestamp   time.Time `json:"timestamp"`
 }
@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ModerationAction defines the type for moderation actions performed by admins.
+type ModerationAction string
+
+const (
+	ActionKick   ModerationAction = "kick"
+	ActionMute   ModerationAction = "mute"
+	ActionUnmute ModerationAction = "unmute"
+	ActionBan    ModerationAction = "ban"
+	ActionUnban  ModerationAction = "unban"
+	ActionFlag   ModerationAction = "flag" // Recorded when a content filter flags a message
+)
+
+// SanctionType defines the kind of restriction placed on a user in a room.
+type SanctionType string
+
+const (
+	SanctionMute SanctionType = "mute"
+	SanctionBan  SanctionType = "ban"
+)
+
+// Sanction represents an active mute or ban of a user in a room.
+// A zero ExpiresAt means the sanction never expires.
+type Sanction struct {
+	RoomID    string       `bson:"room_id" json:"roomId"`
+	UserID    string       `bson:"user_id" json:"userId"`
+	Type      SanctionType `bson:"type" json:"type"`
+	Reason    string       `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedBy string       `bson:"created_by" json:"createdBy"`
+	CreatedAt time.Time    `bson:"created_at" json:"createdAt"`
+	ExpiresAt time.Time    `bson:"expires_at,omitempty" json:"expiresAt,omitempty"`
+}
+
+// IsActive reports whether the sanction is still in effect at the given time.
+func (s *Sanction) IsActive(now time.Time) bool {
+	return s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt)
+}
+
+// AuditEntry records a single moderation action for later review.
+type AuditEntry struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action       ModerationAction   `bson:"action" json:"action"`
+	RoomID       string             `bson:"room_id" json:"roomId"`
+	ActorUserID  string             `bson:"actor_user_id" json:"actorUserId"`
+	TargetUserID string             `bson:"target_user_id" json:"targetUserId"`
+	MessageID    string             `bson:"message_id,omitempty" json:"messageId,omitempty"`
+	Reason       string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Duration     string             `bson:"duration,omitempty" json:"duration,omitempty"`
+	Timestamp    time.Time          `bson:"timestamp" json:"timestamp"`
+}
@@ -0,0 +1,32 @@
+package entities
+
+import "time"
+
+// PresenceStatus defines the type for a user's presence status.
+type PresenceStatus string
+
+const (
+	StatusOnline  PresenceStatus = "online"
+	StatusAway    PresenceStatus = "away"
+	StatusOffline PresenceStatus = "offline"
+)
+
+// Presence describes whether a user is connected and when they were last active.
+type Presence struct {
+	UserID     string         `json:"userId"`
+	Username   string         `json:"username"`
+	Status     PresenceStatus `json:"status"`
+	LastActive time.Time      `json:"lastActive,omitempty"`
+	LastSeen   time.Time      `json:"lastSeen,omitempty"` // When the user's last connection closed
+}
+
+// PresenceChangedResponse notifies a room that a user's presence status has changed.
+type PresenceChangedResponse struct {
+	Event     string         `json:"event"`
+	RoomID    string         `json:"roomId"`
+	UserID    string         `json:"userId"`
+	Username  string         `json:"username"`
+	Status    PresenceStatus `json:"status"`
+	LastSeen  time.Time      `json:"lastSeen,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// StorageUsage is the number of bytes a user uploaded in the current quota period.
+type StorageUsage struct {
+	UserID   string
+	Bytes    int64
+	Files    int64
+	Rooms    map[string]int64 // Bytes uploaded for each room; uploads without a room only count towards Bytes
+	ResetsAt *time.Time       // Nil if the usage never resets or nothing was uploaded
+}
@@ -0,0 +1,17 @@
+package entities
+
+import "time"
+
+// ResumableUpload is a file uploaded in chunks with the tus protocol.
+type ResumableUpload struct {
+	ID        string
+	UserID    string // The user uploading the file, the only one who can see and continue the upload
+	RoomID    string // The room the file is meant for, if any
+	FileName  string
+	Length    int64    // The size of the whole file, in bytes
+	Offset    int64    // The number of bytes received so far
+	Chunks    []string // The storage keys of the received chunks, in order
+	CreatedAt time.Time
+	ExpiresAt time.Time     // Moved forward whenever a chunk is received
+	Result    *FileMetadata // The stored file, once the upload is complete
+}
@@ -1,5 +1,7 @@
 package entities
 
+import "time"
+
 // UserRole defines the type for user roles.
 type UserRole string
 
@@ -10,13 +12,25 @@ const (
 
 // User represents a user in the system.
 type User struct {
-	ID       string   `json:"id"`
-	Username string   `json:"username"`
-	Role     UserRole `json:"role"`
+	ID           string   `json:"id"`
+	Username     string   `json:"username"`
+	Role         UserRole `json:"role"`
+	PasswordHash string   `json:"-"`
+}
+
+// RefreshToken is a server-side record of an issued refresh token.
+// Tokens are rotated on every use; all tokens descending from the same login share a FamilyID.
+type RefreshToken struct {
+	TokenHash string    `json:"tokenHash"`
+	UserID    string    `json:"userId"`
+	FamilyID  string    `json:"familyId"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 // UserCountResponse is used for broadcasting the number of active users.
 type UserCountResponse struct {
-	ActiveUsers int `json:"activeUsers"`
-	TotalUsers  int `json:"totalUsers"`
+	Event       string `json:"event"`
+	RoomID      string `json:"roomId"`
+	ActiveUsers int    `json:"activeUsers"`
+	TotalUsers  int    `json:"totalUsers"`
 }
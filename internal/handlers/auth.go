@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// credentialsRequest is the request body for registration and login.
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// refreshTokenRequest is the request body for token refresh and logout.
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// AuthHandler handles HTTP requests for registration and token issuance.
+type AuthHandler struct {
+	useCase usecases.AuthUseCase
+}
+
+// NewAuthHandler creates a new AuthHandler.
+func NewAuthHandler(useCase usecases.AuthUseCase) *AuthHandler {
+	return &AuthHandler{
+		useCase: useCase,
+	}
+}
+
+// Register is the handler for the POST /auth/register endpoint.
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	var req credentialsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("invalid request body"))
+	}
+
+	user, err := h.useCase.Register(c.Context(), req.Username, req.Password)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(user)
+}
+
+// Login is the handler for the POST /auth/login endpoint.
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var req credentialsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("invalid request body"))
+	}
+
+	tokens, err := h.useCase.Login(c.Context(), req.Username, req.Password)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(tokens)
+}
+
+// Refresh is the handler for the POST /auth/refresh endpoint.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req refreshTokenRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("refreshToken is required"))
+	}
+
+	tokens, err := h.useCase.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(tokens)
+}
+
+// Logout is the handler for the POST /auth/logout endpoint.
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req refreshTokenRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("refreshToken is required"))
+	}
+
+	if err := h.useCase.Logout(c.Context(), req.RefreshToken); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
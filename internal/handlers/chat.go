@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
 	"api-gateway/pkg/ws"
 	"context"
 	"encoding/json"
@@ -14,22 +15,42 @@ import (
 // ChatHandler handles the WebSocket connections for the chat.
 type ChatHandler struct {
 	useCase     usecases.ChatUseCase
+	moderation  usecases.ModerationUseCase
+	inbox       usecases.InboxUseCase
 	connManager *ws.ConnectionManager
 }
 
 // NewChatHandler creates a new ChatHandler.
-func NewChatHandler(useCase usecases.ChatUseCase, connManager *ws.ConnectionManager) *ChatHandler {
+func NewChatHandler(
+	useCase usecases.ChatUseCase,
+	moderation usecases.ModerationUseCase,
+	inbox usecases.InboxUseCase,
+	connManager *ws.ConnectionManager,
+) *ChatHandler {
 	return &ChatHandler{
 		useCase:     useCase,
+		moderation:  moderation,
+		inbox:       inbox,
 		connManager: connManager,
 	}
 }
 
 // ServeWS is the entry point for WebSocket connections.
 func (h *ChatHandler) ServeWS(c *fiber.Ctx) error {
+	userID := currentUserID(c)
+	roomID := c.Query("roomId")
+	if roomID == "" {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("roomId is required"))
+	}
+
+	// Banned users are rejected before the connection is upgraded.
+	if err := h.moderation.CheckCanJoin(c.Context(), userID, roomID); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
 	return websocket.New(func(conn *websocket.Conn) {
-		// Create a new client from the WebSocket connection.
-		client := ws.NewClient(conn, h.connManager)
+		// Create a new client for the authenticated user from the WebSocket connection.
+		client := ws.NewClientWithID(conn, h.connManager, userID, roomID)
 		h.connManager.RegisterClient(client)
 		defer h.connManager.UnregisterClient(client)
 
@@ -48,6 +69,17 @@ func (h *ChatHandler) ServeWS(c *fiber.Ctx) error {
 			client.SendMessage(payload)
 		}
 
+		// Flush the events queued while the user was offline, in order.
+		// They are removed once the client acknowledges them with an inbox-ack message.
+		pending, err := h.inbox.Pending(context.Background(), client.GetID())
+		if err != nil {
+			log.Printf("Failed to read inbox of user %s: %v", client.GetID(), err)
+		}
+		for _, delivery := range pending {
+			payload, _ := json.Marshal(delivery)
+			client.SendMessage(payload)
+		}
+
 		// Start a listening goroutine for the client.
 		go client.WritePump()
 
@@ -62,7 +94,9 @@ func (h *ChatHandler) ServeWS(c *fiber.Ctx) error {
 				break
 			}
 			// Process the message using the use case.
-			h.useCase.ProcessMessage(context.Background(), client.GetID(), client.GetRoomID(), string(msg))
+			if err := h.useCase.ProcessMessage(context.Background(), client.GetID(), client.GetRoomID(), msg); err != nil {
+				log.Printf("Failed to process message from client %s: %v", client.GetID(), err)
+			}
 		}
 	})(c)
 }
@@ -2,25 +2,38 @@ package handlers
 
 import (
 	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
 	"net/http"
+	"net/url"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // FileUploadHandler handles HTTP requests for file uploads.
 type FileUploadHandler struct {
-	useCase usecases.FileUploadUseCase
+	useCase    usecases.FileUploadUseCase
+	moderation usecases.ModerationUseCase
 }
 
 // NewFileUploadHandler creates a new FileUploadHandler.
-func NewFileUploadHandler(useCase usecases.FileUploadUseCase) *FileUploadHandler {
+func NewFileUploadHandler(useCase usecases.FileUploadUseCase, moderation usecases.ModerationUseCase) *FileUploadHandler {
 	return &FileUploadHandler{
-		useCase: useCase,
+		useCase:    useCase,
+		moderation: moderation,
 	}
 }
 
-// UploadFile is the handler for the POST /files/upload endpoint.
+// UploadFile is the handler for the POST /files/upload endpoint. The optional "roomId" form field
+// names the room the file is meant for, which is recorded in the user's storage usage.
 func (h *FileUploadHandler) UploadFile(c *fiber.Ctx) error {
+	// Users banned from the room cannot upload files for it.
+	roomID := c.FormValue("roomId")
+	if roomID != "" {
+		if err := h.moderation.CheckCanJoin(c.Context(), currentUserID(c), roomID); err != nil {
+			return errs.HandleFiberError(c, err)
+		}
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
@@ -34,10 +47,64 @@ func (h *FileUploadHandler) UploadFile(c *fiber.Ctx) error {
 	defer src.Close()
 
 	// Upload the file using the use case.
-	uploadRes, err := h.useCase.UploadFile(c.Context(), src, file.Filename, file.Size)
+	uploadRes, err := h.useCase.UploadFile(c.Context(), src, currentUserID(c), roomID, file.Filename, file.Size)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(uploadRes)
+}
+
+// claimFileRequest is the body of the POST /files/claim endpoint.
+type claimFileRequest struct {
+	SHA256   string `json:"sha256"`
+	FileName string `json:"fileName"`
+}
+
+// ClaimFile is the handler for the POST /files/claim endpoint. It returns the stored file with
+// the given hash, so that clients can skip uploading a file they uploaded before.
+func (h *FileUploadHandler) ClaimFile(c *fiber.Ctx) error {
+	var req claimFileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("invalid request body"))
+	}
+
+	uploadRes, err := h.useCase.ClaimFile(c.Context(), currentUserID(c), req.SHA256, req.FileName)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return errs.HandleFiberError(c, err)
 	}
 
 	return c.Status(http.StatusOK).JSON(uploadRes)
 }
+
+// FileDownloadHandler handles HTTP requests for downloading stored files.
+type FileDownloadHandler struct {
+	useCase usecases.FileDownloadUseCase
+}
+
+// NewFileDownloadHandler creates a new FileDownloadHandler.
+func NewFileDownloadHandler(useCase usecases.FileDownloadUseCase) *FileDownloadHandler {
+	return &FileDownloadHandler{
+		useCase: useCase,
+	}
+}
+
+// DownloadFile is the handler for the GET /files/* endpoint. It streams the file from the file storage.
+func (h *FileDownloadHandler) DownloadFile(c *fiber.Ctx) error {
+	requestURL, err := url.ParseRequestURI(c.OriginalURL())
+	if err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("invalid URL"))
+	}
+
+	reader, info, err := h.useCase.OpenFile(c.Context(), c.Params("*"), requestURL)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, info.ContentType)
+	c.Set(fiber.HeaderLastModified, info.ModTime.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+
+	// The reader is closed once the body has been sent.
+	return c.Status(http.StatusOK).SendStream(reader, int(info.Size))
+}
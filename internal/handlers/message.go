@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MessageHandler handles HTTP requests for reading and posting room messages.
+type MessageHandler struct {
+	useCase usecases.ChatUseCase
+}
+
+// NewMessageHandler creates a new MessageHandler.
+func NewMessageHandler(useCase usecases.ChatUseCase) *MessageHandler {
+	return &MessageHandler{
+		useCase: useCase,
+	}
+}
+
+// GetMessages is the handler for the GET /rooms/:id/messages endpoint.
+// It accepts the optional query parameters limit, cursor, since, until (RFC 3339) and type (comma-separated).
+func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
+	query := usecases.HistoryQuery{
+		Limit:  c.QueryInt("limit"),
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+	if query.Since, err = parseTimeQuery(c, "since"); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+	if query.Until, err = parseTimeQuery(c, "until"); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+	if types := c.Query("type"); types != "" {
+		query.Types = strings.Split(types, ",")
+	}
+
+	page, err := h.useCase.GetMessages(c.Context(), currentUserID(c), c.Params("id"), query)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(page)
+}
+
+// PostMessage is the handler for the POST /rooms/:id/messages endpoint.
+func (h *MessageHandler) PostMessage(c *fiber.Ctx) error {
+	var msg usecases.IncomingMessage
+	if err := c.BodyParser(&msg); err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("invalid request body"))
+	}
+
+	dto, err := h.useCase.PostMessage(c.Context(), currentUserID(c), c.Params("id"), msg)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(dto)
+}
+
+// EditMessage is the handler for the PATCH /rooms/:id/messages/:messageId endpoint.
+func (h *MessageHandler) EditMessage(c *fiber.Ctx) error {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("invalid request body"))
+	}
+
+	dto, err := h.useCase.EditMessage(c.Context(), currentUserID(c), c.Params("id"), c.Params("messageId"), req.Content)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(dto)
+}
+
+// DeleteMessage is the handler for the DELETE /rooms/:id/messages/:messageId endpoint.
+func (h *MessageHandler) DeleteMessage(c *fiber.Ctx) error {
+	if err := h.useCase.DeleteMessage(c.Context(), currentUserID(c), c.Params("id"), c.Params("messageId")); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// SearchMessages is the handler for the GET /search/messages endpoint.
+// It accepts the query parameters q, rooms (comma-separated), limit and cursor.
+func (h *MessageHandler) SearchMessages(c *fiber.Ctx) error {
+	query := usecases.SearchQuery{
+		Query:  c.Query("q"),
+		Limit:  c.QueryInt("limit"),
+		Cursor: c.Query("cursor"),
+	}
+	if rooms := c.Query("rooms"); rooms != "" {
+		query.RoomIDs = strings.Split(rooms, ",")
+	}
+
+	page, err := h.useCase.SearchMessages(c.Context(), currentUserID(c), query)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(page)
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter.
+func parseTimeQuery(c *fiber.Ctx, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errs.NewBadRequestError(key + " must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userIDKey is the key under which the authenticated user ID is stored in the request locals.
+const userIDKey = "userID"
+
+// NewAuthMiddleware authenticates requests with a bearer access token.
+// Browsers cannot set headers on WebSocket upgrades, so the token may also be
+// passed in the access_token query parameter.
+func NewAuthMiddleware(auth usecases.AuthUseCase) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		accessToken := c.Query("access_token")
+		if header := c.Get(fiber.HeaderAuthorization); header != "" {
+			scheme, value, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") {
+				return errs.HandleFiberError(c, errs.NewUnauthorizedError("authorization header must use the Bearer scheme"))
+			}
+			accessToken = value
+		}
+		if accessToken == "" {
+			return errs.HandleFiberError(c, errs.NewUnauthorizedError("missing access token"))
+		}
+
+		userID, err := auth.Authenticate(c.Context(), accessToken)
+		if err != nil {
+			return errs.HandleFiberError(c, err)
+		}
+
+		c.Locals(userIDKey, userID)
+		return c.Next()
+	}
+}
+
+// NewAdminMiddleware rejects users who are not admins. It must run after the auth middleware.
+func NewAdminMiddleware(auth usecases.AuthUseCase) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := auth.RequireAdmin(c.Context(), currentUserID(c)); err != nil {
+			return errs.HandleFiberError(c, err)
+		}
+		return c.Next()
+	}
+}
+
+// currentUserID returns the user ID stored by the auth middleware.
+func currentUserID(c *fiber.Ctx) string {
+	userID, _ := c.Locals(userIDKey).(string)
+	return userID
+}
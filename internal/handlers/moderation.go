@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ModerationHandler handles HTTP requests for room moderation.
+type ModerationHandler struct {
+	useCase usecases.ModerationUseCase
+}
+
+// NewModerationHandler creates a new ModerationHandler.
+func NewModerationHandler(useCase usecases.ModerationUseCase) *ModerationHandler {
+	return &ModerationHandler{
+		useCase: useCase,
+	}
+}
+
+// Kick is the handler for the POST /rooms/:id/moderation/kick endpoint.
+func (h *ModerationHandler) Kick(c *fiber.Ctx) error {
+	return h.execute(c, entities.ActionKick)
+}
+
+// Mute is the handler for the POST /rooms/:id/moderation/mute endpoint.
+func (h *ModerationHandler) Mute(c *fiber.Ctx) error {
+	return h.execute(c, entities.ActionMute)
+}
+
+// Unmute is the handler for the DELETE /rooms/:id/moderation/mute/:userId endpoint.
+func (h *ModerationHandler) Unmute(c *fiber.Ctx) error {
+	return h.execute(c, entities.ActionUnmute)
+}
+
+// Ban is the handler for the POST /rooms/:id/moderation/ban endpoint.
+func (h *ModerationHandler) Ban(c *fiber.Ctx) error {
+	return h.execute(c, entities.ActionBan)
+}
+
+// Unban is the handler for the DELETE /rooms/:id/moderation/ban/:userId endpoint.
+func (h *ModerationHandler) Unban(c *fiber.Ctx) error {
+	return h.execute(c, entities.ActionUnban)
+}
+
+// AuditLog is the handler for the GET /rooms/:id/moderation/audit endpoint.
+func (h *ModerationHandler) AuditLog(c *fiber.Ctx) error {
+	entries, err := h.useCase.AuditLog(c.Context(), currentUserID(c), c.Params("id"), int64(c.QueryInt("limit")))
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(entries)
+}
+
+// execute parses the optional command body and applies the given action.
+// The target may be given in the body or, for DELETE routes, as the :userId parameter.
+func (h *ModerationHandler) execute(c *fiber.Ctx, action entities.ModerationAction) error {
+	var cmd usecases.ModerationCommand
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&cmd); err != nil {
+			return errs.HandleFiberError(c, errs.NewBadRequestError("invalid request body"))
+		}
+	}
+	cmd.Action = action
+	if userID := c.Params("userId"); userID != "" {
+		cmd.TargetUserID = userID
+	}
+
+	if err := h.useCase.Execute(c.Context(), currentUserID(c), c.Params("id"), cmd); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PresenceHandler handles HTTP requests for user presence.
+type PresenceHandler struct {
+	useCase usecases.PresenceUseCase
+}
+
+// NewPresenceHandler creates a new PresenceHandler.
+func NewPresenceHandler(useCase usecases.PresenceUseCase) *PresenceHandler {
+	return &PresenceHandler{
+		useCase: useCase,
+	}
+}
+
+// GetRoomPresence is the handler for the GET /rooms/:id/presence endpoint.
+func (h *PresenceHandler) GetRoomPresence(c *fiber.Ctx) error {
+	presence, err := h.useCase.RoomPresence(c.Context(), c.Params("id"))
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(presence)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+
+	headerTusResumable   = "Tus-Resumable"
+	headerTusVersion     = "Tus-Version"
+	headerTusExtension   = "Tus-Extension"
+	headerTusMaxSize     = "Tus-Max-Size"
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadLength   = "Upload-Length"
+	headerUploadMetadata = "Upload-Metadata"
+	headerUploadExpires  = "Upload-Expires"
+)
+
+// ResumableUploadHandler handles HTTP requests for resumable uploads, following the tus protocol 1.0.0
+// with the creation, expiration and termination extensions.
+type ResumableUploadHandler struct {
+	useCase    usecases.ResumableUploadUseCase
+	moderation usecases.ModerationUseCase
+}
+
+// NewResumableUploadHandler creates a new ResumableUploadHandler.
+func NewResumableUploadHandler(useCase usecases.ResumableUploadUseCase, moderation usecases.ModerationUseCase) *ResumableUploadHandler {
+	return &ResumableUploadHandler{
+		useCase:    useCase,
+		moderation: moderation,
+	}
+}
+
+// RequireTusResumable rejects requests for another version of the tus protocol.
+// OPTIONS requests are let through, as clients use them to discover the version.
+func (h *ResumableUploadHandler) RequireTusResumable(c *fiber.Ctx) error {
+	c.Set(headerTusResumable, tusVersion)
+	if c.Method() != fiber.MethodOptions && c.Get(headerTusResumable) != tusVersion {
+		c.Set(headerTusVersion, tusVersion)
+		return c.Status(http.StatusPreconditionFailed).JSON(fiber.Map{"message": "unsupported tus version"})
+	}
+	return c.Next()
+}
+
+// Options is the handler for the OPTIONS /files/tus endpoint. It describes the server's capabilities.
+func (h *ResumableUploadHandler) Options(c *fiber.Ctx) error {
+	c.Set(headerTusVersion, tusVersion)
+	c.Set(headerTusExtension, tusExtensions)
+	if maxSize := h.useCase.MaxSize(); maxSize > 0 {
+		c.Set(headerTusMaxSize, strconv.FormatInt(maxSize, 10))
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// Create is the handler for the POST /files/tus endpoint. The file name is read from the
+// "filename" or "name" key of the Upload-Metadata header, and the room the file is meant for
+// from the optional "roomId" key.
+func (h *ResumableUploadHandler) Create(c *fiber.Ctx) error {
+	length, err := strconv.ParseInt(c.Get(headerUploadLength), 10, 64)
+	if err != nil {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("Upload-Length header is required"))
+	}
+	metadata, err := parseUploadMetadata(c.Get(headerUploadMetadata))
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+	fileName := metadata["filename"]
+	if fileName == "" {
+		fileName = metadata["name"]
+	}
+	// Users banned from the room cannot upload files for it.
+	roomID := metadata["roomId"]
+	if roomID != "" {
+		if err := h.moderation.CheckCanJoin(c.Context(), currentUserID(c), roomID); err != nil {
+			return errs.HandleFiberError(c, err)
+		}
+	}
+
+	upload, err := h.useCase.Create(c.Context(), currentUserID(c), roomID, fileName, length)
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	setUploadHeaders(c, upload)
+	c.Location(strings.TrimSuffix(c.BaseURL()+c.Path(), "/") + "/" + upload.ID)
+	return c.SendStatus(http.StatusCreated)
+}
+
+// Head is the handler for the HEAD /files/tus/:id endpoint. It reports how many bytes were received.
+func (h *ResumableUploadHandler) Head(c *fiber.Ctx) error {
+	upload, err := h.useCase.Status(c.Context(), currentUserID(c), c.Params("id"))
+	if err != nil {
+		return c.SendStatus(errorStatus(err))
+	}
+
+	setUploadHeaders(c, upload)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.SendStatus(http.StatusOK)
+}
+
+// Patch is the handler for the PATCH /files/tus/:id endpoint. It appends a chunk at Upload-Offset.
+// The last chunk is answered with the FileUploadResponse of the stored file.
+func (h *ResumableUploadHandler) Patch(c *fiber.Ctx) error {
+	if c.Get(fiber.HeaderContentType) != tusContentType {
+		return c.Status(http.StatusUnsupportedMediaType).JSON(fiber.Map{"message": "Content-Type must be " + tusContentType})
+	}
+	offset, err := strconv.ParseInt(c.Get(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		return errs.HandleFiberError(c, errs.NewBadRequestError("Upload-Offset header is required"))
+	}
+
+	upload, uploadRes, err := h.useCase.WriteChunk(c.Context(), currentUserID(c), c.Params("id"), offset, bytes.NewReader(c.Body()))
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	setUploadHeaders(c, upload)
+	if uploadRes != nil {
+		return c.Status(http.StatusOK).JSON(uploadRes)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// Delete is the handler for the DELETE /files/tus/:id endpoint. It cancels the upload.
+func (h *ResumableUploadHandler) Delete(c *fiber.Ctx) error {
+	if err := h.useCase.Terminate(c.Context(), currentUserID(c), c.Params("id")); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// setUploadHeaders describes the state of an upload.
+func setUploadHeaders(c *fiber.Ctx, upload *entities.ResumableUpload) {
+	c.Set(headerUploadOffset, strconv.FormatInt(upload.Offset, 10))
+	c.Set(headerUploadLength, strconv.FormatInt(upload.Length, 10))
+	c.Set(headerUploadExpires, upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated pairs of a key and a base64 value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, errs.NewBadRequestError("Upload-Metadata values must be base64 encoded")
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// errorStatus returns the HTTP status of an error, for responses without a body.
+func errorStatus(err error) int {
+	if customErr, ok := err.(errs.CustomError); ok {
+		return customErr.Code
+	}
+	return http.StatusInternalServerError
+}
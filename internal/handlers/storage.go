@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StorageHandler handles HTTP requests for the bytes users upload against their quota.
+type StorageHandler struct {
+	useCase usecases.StorageQuotaUseCase
+}
+
+// NewStorageHandler creates a new StorageHandler.
+func NewStorageHandler(useCase usecases.StorageQuotaUseCase) *StorageHandler {
+	return &StorageHandler{
+		useCase: useCase,
+	}
+}
+
+// GetUsage is the handler for the GET /me/storage endpoint.
+// It reports the bytes the user uploaded in the current quota period, not the size of the files they store.
+func (h *StorageHandler) GetUsage(c *fiber.Ctx) error {
+	usage, err := h.useCase.Usage(c.Context(), currentUserID(c))
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(usage)
+}
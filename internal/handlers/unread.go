@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"api-gateway/internal/usecases"
+	"api-gateway/pkg/errs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UnreadHandler handles HTTP requests for unread message counters.
+type UnreadHandler struct {
+	useCase usecases.UnreadUseCase
+}
+
+// NewUnreadHandler creates a new UnreadHandler.
+func NewUnreadHandler(useCase usecases.UnreadUseCase) *UnreadHandler {
+	return &UnreadHandler{
+		useCase: useCase,
+	}
+}
+
+// GetUnread is the handler for the GET /me/unread endpoint.
+func (h *UnreadHandler) GetUnread(c *fiber.Ctx) error {
+	counts, err := h.useCase.Counts(c.Context(), currentUserID(c))
+	if err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(counts)
+}
+
+// MarkRead is the handler for the POST /rooms/:id/read endpoint.
+func (h *UnreadHandler) MarkRead(c *fiber.Ctx) error {
+	if err := h.useCase.MarkRead(c.Context(), currentUserID(c), c.Params("id")); err != nil {
+		return errs.HandleFiberError(c, err)
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -1,27 +1,36 @@
 package infrastructures
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/valyala/fasthttp"
 )
 
-func NewFiber() *fiber.App {
+// NewFiber creates the Fiber app. Request bodies are limited to Fiber's default of 4MB, except on
+// the paths in bodyLimits, which accept bodies up to their own limit in bytes.
+func NewFiber(bodyLimits map[string]int) *fiber.App {
 	app := fiber.New(fiber.Config{
 		ReadTimeout:  2 * time.Minute, // Increased for WebSocket connections
 		WriteTimeout: 2 * time.Minute, // Increased for WebSocket connections
 		IdleTimeout:  5 * time.Minute, // How long to keep idle connections open
-		BodyLimit:    4 * 1024 * 1024, // 4MB body limit
 	})
 
+	// The limit is chosen once the headers are read, before the body is.
+	app.Server().HeaderReceived = func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		path, _, _ := strings.Cut(string(header.RequestURI()), "?")
+		return fasthttp.RequestConfig{MaxRequestBodySize: bodyLimits[path]}
+	}
+
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
 		AllowCredentials: false,
 		AllowOrigins:     "*",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		ExposeHeaders:    "Content-Length",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata",
+		ExposeHeaders:    "Content-Length, Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires",
 	}))
 
 	return app
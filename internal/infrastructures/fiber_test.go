@@ -0,0 +1,36 @@
+package infrastructures
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewFiberBodyLimits(t *testing.T) {
+	app := NewFiber(map[string]int{"/upload": 8 << 20})
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Post("/upload", ok)
+	app.Post("/other", ok)
+
+	body := strings.Repeat("x", 6<<20)
+	for _, path := range []string{"/upload", "/upload?name=a"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)), -1)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("POST %s: got status %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	// The server rejects the body while reading it, before any handler runs.
+	if _, err := app.Test(httptest.NewRequest(http.MethodPost, "/other", strings.NewReader(body)), -1); !errors.Is(err, fasthttp.ErrBodyTooLarge) {
+		t.Errorf("POST /other: got %v, want ErrBodyTooLarge", err)
+	}
+}
@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FileReferenceRepository defines the interface for content-addressed files and the number of messages
+// referencing them, so that a file uploaded many times is stored once and deleted with its last message.
+type FileReferenceRepository interface {
+	// Register records a newly stored file uploaded by a user under the metadata's file name, pinned until
+	// the given time. If the same content was registered concurrently, it returns the metadata of the file
+	// registered first instead.
+	Register(ctx context.Context, hash, userID string, metadata *entities.FileMetadata, pinnedUntil time.Time) (*entities.FileMetadata, error)
+	// Claim returns the metadata of the file with the given content hash for a user who uploaded the
+	// content again, records the user as one of its uploaders under the given file name and pins the file
+	// until the given time. Unknown files return nil.
+	Claim(ctx context.Context, hash, userID, fileName string, pinnedUntil time.Time) (*entities.FileMetadata, error)
+	// ClaimUploaded is like Claim, but only for users who uploaded the file before. It returns nil for anyone else.
+	ClaimUploaded(ctx context.Context, hash, userID, fileName string, pinnedUntil time.Time) (*entities.FileMetadata, error)
+	// Retain adds a reference to a file by a message of a user who uploaded it, and returns the file's
+	// metadata with the name the user last uploaded or claimed it under. Unknown files, and files that
+	// the user did not upload, return an error wrapping ErrNotFound.
+	Retain(ctx context.Context, hash, userID string) (*entities.FileMetadata, error)
+	// Release removes a reference to a file. It reports true when that was the last reference and the file
+	// is not pinned, in which case the file is forgotten and should be deleted.
+	Release(ctx context.Context, hash string) (bool, error)
+	// Forget removes the record of a file without references, so that its content can be deleted.
+	// It reports false if the file is still referenced or pinned; unknown files count as forgotten.
+	Forget(ctx context.Context, hash string) (bool, error)
+}
+
+// redisFileReferenceRepository is a Redis implementation of the FileReferenceRepository.
+// Each file is a hash holding its metadata as JSON, its reference count, the time in milliseconds until
+// which it is pinned and an "uploader:{userID}" field for each user who uploaded it, holding the file name
+// the user gave it, so that the name a user chose is not shown for the messages of another.
+type redisFileReferenceRepository struct {
+	client *redis.Client
+}
+
+// NewRedisFileReferenceRepository creates a new Redis file reference repository.
+func NewRedisFileReferenceRepository(client *redis.Client) FileReferenceRepository {
+	return &redisFileReferenceRepository{
+		client: client,
+	}
+}
+
+func fileReferenceKey(hash string) string {
+	return "file:sha256:" + hash
+}
+
+// pinFileScript is the part of the register and claim scripts that pins a file until ARGV[2],
+// unless it is already pinned for longer.
+const pinFileScript = `
+if tonumber(redis.call('HGET', KEYS[1], 'pinned_until') or '0') < tonumber(ARGV[2]) then
+	redis.call('HSET', KEYS[1], 'pinned_until', ARGV[2])
+end
+`
+
+// registerFileScript records a file and its uploader, or returns the file registered first.
+var registerFileScript = redis.NewScript(`
+local metadata = ARGV[3]
+if redis.call('HSETNX', KEYS[1], 'metadata', metadata) == 1 then
+	redis.call('HSET', KEYS[1], 'refs', 0)
+else
+	metadata = redis.call('HGET', KEYS[1], 'metadata')
+end
+redis.call('HSET', KEYS[1], 'uploader:' .. ARGV[1], ARGV[4])
+` + pinFileScript + `
+return metadata
+`)
+
+// claimFileScript pins a known file and records the file name ARGV[4] of the user. Unless ARGV[3] is set,
+// it records the user as an uploader; otherwise it only returns the file to its uploaders.
+var claimFileScript = redis.NewScript(`
+local metadata = redis.call('HGET', KEYS[1], 'metadata')
+if not metadata then
+	return false
+end
+if ARGV[3] == '1' then
+	if redis.call('HEXISTS', KEYS[1], 'uploader:' .. ARGV[1]) == 0 then
+		return false
+	end
+end
+redis.call('HSET', KEYS[1], 'uploader:' .. ARGV[1], ARGV[4])
+` + pinFileScript + `
+return metadata
+`)
+
+// retainFileScript increments the reference count of a known file uploaded by the user ARGV[1],
+// and returns its metadata and the user's file name.
+var retainFileScript = redis.NewScript(`
+local fileName = redis.call('HGET', KEYS[1], 'uploader:' .. ARGV[1])
+if not fileName then
+	return false
+end
+redis.call('HINCRBY', KEYS[1], 'refs', 1)
+return {redis.call('HGET', KEYS[1], 'metadata'), fileName}
+`)
+
+// releaseFileScript decrements the reference count and deletes the record with the last reference,
+// unless the file is pinned after ARGV[1].
+var releaseFileScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local refs = redis.call('HINCRBY', KEYS[1], 'refs', -1)
+if refs > 0 then
+	return 0
+end
+if refs < 0 then
+	redis.call('HSET', KEYS[1], 'refs', 0)
+end
+if tonumber(redis.call('HGET', KEYS[1], 'pinned_until') or '0') > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// forgetFileScript deletes the record of a file without references that is not pinned after ARGV[1].
+var forgetFileScript = redis.NewScript(`
+if tonumber(redis.call('HGET', KEYS[1], 'refs') or '0') > 0 then
+	return 0
+end
+if tonumber(redis.call('HGET', KEYS[1], 'pinned_until') or '0') > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// Register runs the register script.
+func (r *redisFileReferenceRepository) Register(ctx context.Context, hash, userID string, metadata *entities.FileMetadata, pinnedUntil time.Time) (*entities.FileMetadata, error) {
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := registerFileScript.Run(ctx, r.client, []string{fileReferenceKey(hash)}, userID, pinnedUntil.UnixMilli(), encoded, metadata.FileName).Text()
+	if err != nil {
+		return nil, err
+	}
+	return decodeFileMetadata(data)
+}
+
+// Claim runs the claim script.
+func (r *redisFileReferenceRepository) Claim(ctx context.Context, hash, userID, fileName string, pinnedUntil time.Time) (*entities.FileMetadata, error) {
+	return r.claim(ctx, hash, userID, fileName, pinnedUntil, false)
+}
+
+// ClaimUploaded runs the claim script for uploaders only.
+func (r *redisFileReferenceRepository) ClaimUploaded(ctx context.Context, hash, userID, fileName string, pinnedUntil time.Time) (*entities.FileMetadata, error) {
+	return r.claim(ctx, hash, userID, fileName, pinnedUntil, true)
+}
+
+func (r *redisFileReferenceRepository) claim(ctx context.Context, hash, userID, fileName string, pinnedUntil time.Time, uploadersOnly bool) (*entities.FileMetadata, error) {
+	data, err := claimFileScript.Run(ctx, r.client, []string{fileReferenceKey(hash)}, userID, pinnedUntil.UnixMilli(), uploadersOnly, fileName).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeFileMetadata(data)
+}
+
+// Retain runs the retain script.
+func (r *redisFileReferenceRepository) Retain(ctx context.Context, hash, userID string) (*entities.FileMetadata, error) {
+	values, err := retainFileScript.Run(ctx, r.client, []string{fileReferenceKey(hash)}, userID).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("file %s %w", hash, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := decodeFileMetadata(values[0])
+	if err != nil {
+		return nil, err
+	}
+	metadata.FileName = values[1]
+	return metadata, nil
+}
+
+// Release runs the release script.
+func (r *redisFileReferenceRepository) Release(ctx context.Context, hash string) (bool, error) {
+	result, err := releaseFileScript.Run(ctx, r.client, []string{fileReferenceKey(hash)}, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	if result < 0 {
+		return false, fmt.Errorf("file %s %w", hash, ErrNotFound)
+	}
+	return result == 1, nil
+}
+
+// Forget runs the forget script.
+func (r *redisFileReferenceRepository) Forget(ctx context.Context, hash string) (bool, error) {
+	result, err := forgetFileScript.Run(ctx, r.client, []string{fileReferenceKey(hash)}, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+func decodeFileMetadata(data string) (*entities.FileMetadata, error) {
+	var metadata entities.FileMetadata
+	if err := json.Unmarshal([]byte(data), &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFileReferencePinnedFileOutlivesLastReference(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisFileReferenceRepository(newTestRedis(t))
+	const hash = "ab12"
+	metadata := &entities.FileMetadata{URL: "/files/sha256/ab/ab12.png", SHA256: hash}
+
+	// Registered long ago, so the pin of the first upload has expired.
+	if _, err := repo.Register(ctx, hash, "user-1", metadata, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Retain(ctx, hash, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another user uploads the same content, then the only message referencing it is deleted.
+	claimed, err := repo.Claim(ctx, hash, "user-2", "copy.png", time.Now().Add(time.Hour))
+	if err != nil || claimed == nil || claimed.URL != metadata.URL {
+		t.Fatalf("claim: got %+v, %v, want the registered file", claimed, err)
+	}
+	if last, err := repo.Release(ctx, hash); err != nil || last {
+		t.Fatalf("release of a pinned file: got %v, %v, want the file kept", last, err)
+	}
+
+	// The new upload can still be posted, and its message is now the last reference.
+	if _, err := repo.Retain(ctx, hash, "user-2"); err != nil {
+		t.Fatalf("retain after release: %v", err)
+	}
+	if last, err := repo.Release(ctx, hash); err != nil || last {
+		t.Fatalf("release within the pin: got %v, %v, want the file kept", last, err)
+	}
+}
+
+func TestFileReferenceReleaseForgetsUnpinnedFile(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisFileReferenceRepository(newTestRedis(t))
+	const hash = "cd34"
+
+	if _, err := repo.Register(ctx, hash, "user-1", &entities.FileMetadata{SHA256: hash}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Retain(ctx, hash, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := repo.Release(ctx, hash); err != nil || !last {
+		t.Fatalf("release: got %v, %v, want the last reference", last, err)
+	}
+	if _, err := repo.Retain(ctx, hash, "user-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("retain of a forgotten file: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileReferenceClaimUploadedOnlyAnswersUploaders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisFileReferenceRepository(newTestRedis(t))
+	const hash = "ef56"
+	pinnedUntil := time.Now().Add(time.Hour)
+
+	if _, err := repo.Register(ctx, hash, "user-1", &entities.FileMetadata{SHA256: hash}, pinnedUntil); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, err := repo.ClaimUploaded(ctx, hash, "user-2", "copy.png", pinnedUntil); err != nil || claimed != nil {
+		t.Fatalf("claim by another user: got %+v, %v, want nothing", claimed, err)
+	}
+	if claimed, err := repo.ClaimUploaded(ctx, "unknown", "user-1", "copy.png", pinnedUntil); err != nil || claimed != nil {
+		t.Fatalf("claim of an unknown file: got %+v, %v, want nothing", claimed, err)
+	}
+	if claimed, err := repo.ClaimUploaded(ctx, hash, "user-1", "copy.png", pinnedUntil); err != nil || claimed == nil {
+		t.Fatalf("claim by the uploader: got %+v, %v, want the file", claimed, err)
+	}
+
+	// Uploading the content proves that the user has it.
+	if _, err := repo.Claim(ctx, hash, "user-2", "copy.png", pinnedUntil); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := repo.ClaimUploaded(ctx, hash, "user-2", "copy.png", pinnedUntil); err != nil || claimed == nil {
+		t.Fatalf("claim after uploading: got %+v, %v, want the file", claimed, err)
+	}
+}
+
+func TestFileReferenceForgetKeepsPinnedFile(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisFileReferenceRepository(newTestRedis(t))
+	const hash = "ab78"
+
+	if _, err := repo.Register(ctx, hash, "user-1", &entities.FileMetadata{SHA256: hash}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// The file is uploaded again after its grace period, but before the collector forgets it.
+	if _, err := repo.Claim(ctx, hash, "user-2", "copy.png", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if forgotten, err := repo.Forget(ctx, hash); err != nil || forgotten {
+		t.Fatalf("forget of a pinned file: got %v, %v, want the file kept", forgotten, err)
+	}
+	if _, err := repo.Retain(ctx, hash, "user-1"); err != nil {
+		t.Fatalf("retain after forget: %v", err)
+	}
+}
+
+func TestFileReferenceRetainOnlyByUploaders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisFileReferenceRepository(newTestRedis(t))
+	const hash = "cd90"
+
+	if _, err := repo.Register(ctx, hash, "user-1", &entities.FileMetadata{SHA256: hash}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// A user who only knows the hash cannot post the file, nor learn that it exists.
+	if _, err := repo.Retain(ctx, hash, "user-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("retain by another user: got %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Retain(ctx, "unknown", "user-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("retain of an unknown file: got %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Retain(ctx, hash, "user-1"); err != nil {
+		t.Fatalf("retain by the uploader: %v", err)
+	}
+}
+
+func TestFileReferenceRetainReturnsUploadersFileName(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisFileReferenceRepository(newTestRedis(t))
+	const hash = "fe12"
+	metadata := &entities.FileMetadata{URL: "/files/sha256/fe/fe12.pdf", FileName: "salaries.pdf", FileSize: 1234, SHA256: hash}
+
+	if _, err := repo.Register(ctx, hash, "user-1", metadata, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Claim(ctx, hash, "user-2", "report.pdf", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for userID, want := range map[string]string{"user-1": "salaries.pdf", "user-2": "report.pdf"} {
+		retained, err := repo.Retain(ctx, hash, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retained.FileName != want || retained.URL != metadata.URL || retained.FileSize != metadata.FileSize {
+			t.Errorf("retain by %s: got %+v, want %s with the stored file's metadata", userID, retained, want)
+		}
+	}
+}
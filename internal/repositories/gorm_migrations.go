@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+)
+
+// MigrateGorm creates or updates the tables of the GORM repositories.
+func MigrateGorm(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&userModel{},
+		&messageModel{},
+		&sanctionModel{},
+		&auditEntryModel{},
+	); err != nil {
+		return err
+	}
+
+	return migrateMessageSearchIndex(db)
+}
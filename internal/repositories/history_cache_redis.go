@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// HistoryCacheRepository defines the interface for caching the most recent messages of each room.
+type HistoryCacheRepository interface {
+	// Get returns the cached messages of a room, oldest first. It reports false on a cache miss.
+	Get(ctx context.Context, roomID string) ([]*entities.MessageResponse, bool, error)
+	// Set replaces the cached messages of a room.
+	Set(ctx context.Context, roomID string, messages []*entities.MessageResponse) error
+	// Append adds a new message to a cached room, dropping the oldest messages beyond the cache size.
+	// Rooms that are not cached are left alone, so that a partial history is never mistaken for a hit.
+	Append(ctx context.Context, roomID string, message *entities.MessageResponse) error
+	// Invalidate drops the cached messages of a room.
+	Invalidate(ctx context.Context, roomID string) error
+}
+
+// redisHistoryCacheRepository is a Redis implementation of the HistoryCacheRepository.
+// Each room is a list of JSON messages, or a single empty marker for a room without messages.
+// Lists expire after a TTL, which bounds how long a message stored while the cache was being
+// filled can be missing from it.
+type redisHistoryCacheRepository struct {
+	client *redis.Client
+	size   int64
+	ttl    time.Duration
+}
+
+// NewRedisHistoryCacheRepository creates a new Redis history cache holding up to size messages per room for ttl.
+func NewRedisHistoryCacheRepository(client *redis.Client, size int64, ttl time.Duration) HistoryCacheRepository {
+	return &redisHistoryCacheRepository{
+		client: client,
+		size:   size,
+		ttl:    ttl,
+	}
+}
+
+func historyCacheKey(roomID string) string {
+	return "history:" + roomID
+}
+
+// emptyHistoryMarker caches a room without messages, which would otherwise be an empty list that Redis deletes.
+const emptyHistoryMarker = ""
+
+// appendHistoryScript pushes to the list only if it exists, replacing the empty marker, and trims it to the cache size.
+var appendHistoryScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+if redis.call('LINDEX', KEYS[1], 0) == '' then redis.call('LPOP', KEYS[1]) end
+redis.call('RPUSH', KEYS[1], ARGV[1])
+redis.call('LTRIM', KEYS[1], -tonumber(ARGV[2]), -1)
+return 1
+`)
+
+// Get reads the whole list of the room.
+func (r *redisHistoryCacheRepository) Get(ctx context.Context, roomID string) ([]*entities.MessageResponse, bool, error) {
+	values, err := r.client.LRange(ctx, historyCacheKey(roomID), 0, -1).Result()
+	if err != nil || len(values) == 0 {
+		return nil, false, err
+	}
+
+	messages := make([]*entities.MessageResponse, 0, len(values))
+	for _, value := range values {
+		if value == emptyHistoryMarker {
+			continue
+		}
+		var message entities.MessageResponse
+		if err := json.Unmarshal([]byte(value), &message); err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, true, nil
+}
+
+// Set rewrites the list with the newest messages and refreshes its TTL.
+func (r *redisHistoryCacheRepository) Set(ctx context.Context, roomID string, messages []*entities.MessageResponse) error {
+	if int64(len(messages)) > r.size {
+		messages = messages[int64(len(messages))-r.size:]
+	}
+
+	values := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		values = append(values, data)
+	}
+	if len(values) == 0 {
+		values = append(values, emptyHistoryMarker)
+	}
+
+	key := historyCacheKey(roomID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.RPush(ctx, key, values...)
+		pipe.Expire(ctx, key, r.ttl)
+		return nil
+	})
+	return err
+}
+
+// Append pushes the message to the end of the list if the room is cached.
+func (r *redisHistoryCacheRepository) Append(ctx context.Context, roomID string, message *entities.MessageResponse) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return appendHistoryScript.Run(ctx, r.client, []string{historyCacheKey(roomID)}, data, r.size).Err()
+}
+
+// Invalidate deletes the list of the room.
+func (r *redisHistoryCacheRepository) Invalidate(ctx context.Context, roomID string) error {
+	return r.client.Del(ctx, historyCacheKey(roomID)).Err()
+}
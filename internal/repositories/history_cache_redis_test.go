@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHistoryCacheEmptyRoom(t *testing.T) {
+	ctx := context.Background()
+	cache := NewRedisHistoryCacheRepository(newTestRedis(t), 2, time.Minute)
+
+	if _, hit, err := cache.Get(ctx, "room-1"); err != nil || hit {
+		t.Fatalf("uncached room: got hit %v, %v, want a miss", hit, err)
+	}
+	if err := cache.Set(ctx, "room-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	messages, hit, err := cache.Get(ctx, "room-1")
+	if err != nil || !hit || len(messages) != 0 {
+		t.Fatalf("empty room: got %v, hit %v, %v, want a hit without messages", messages, hit, err)
+	}
+
+	for _, content := range []string{"one", "two", "three"} {
+		if err := cache.Append(ctx, "room-1", &entities.MessageResponse{Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages, hit, err = cache.Get(ctx, "room-1")
+	if err != nil || !hit || len(messages) != 2 || messages[0].Content != "two" || messages[1].Content != "three" {
+		t.Fatalf("after appends: got %v, hit %v, %v, want the two newest messages", messages, hit, err)
+	}
+}
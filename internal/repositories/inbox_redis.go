@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InboxRepository defines the interface for per-user queues of undelivered events.
+type InboxRepository interface {
+	// Push appends an item to the user's inbox, dropping the oldest items beyond maxItems.
+	// A maxItems of zero keeps every item.
+	Push(ctx context.Context, userID string, item *entities.InboxItem, maxItems int64) error
+	// FindByUser retrieves the unexpired items of the user's inbox, oldest first.
+	FindByUser(ctx context.Context, userID string) ([]*entities.InboxItem, error)
+	// Delete removes delivered items from the user's inbox.
+	Delete(ctx context.Context, userID string, itemIDs []string) error
+}
+
+// redisInboxRepository is a Redis implementation of the InboxRepository.
+// Item IDs are kept in a sorted set ordered by creation time, and items in a hash.
+// Both keys expire with the newest item, and older items are filtered by their own expiry.
+type redisInboxRepository struct {
+	client *redis.Client
+}
+
+// NewRedisInboxRepository creates a new Redis inbox repository.
+func NewRedisInboxRepository(client *redis.Client) InboxRepository {
+	return &redisInboxRepository{
+		client: client,
+	}
+}
+
+func inboxOrderKey(userID string) string {
+	return "inbox:" + userID
+}
+
+func inboxItemsKey(userID string) string {
+	return "inbox:" + userID + ":items"
+}
+
+// Push stores the item and trims the inbox to maxItems.
+func (r *redisInboxRepository) Push(ctx context.Context, userID string, item *entities.InboxItem, maxItems int64) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	orderKey, itemsKey := inboxOrderKey(userID), inboxItemsKey(userID)
+	ttl := time.Until(item.ExpiresAt)
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, orderKey, redis.Z{Score: float64(item.CreatedAt.UnixNano()), Member: item.ID})
+		pipe.HSet(ctx, itemsKey, item.ID, data)
+		pipe.Expire(ctx, orderKey, ttl)
+		pipe.Expire(ctx, itemsKey, ttl)
+		return nil
+	})
+	if err != nil || maxItems <= 0 {
+		return err
+	}
+
+	// Drop the oldest items if the inbox grew beyond its limit.
+	dropped, err := r.client.ZRange(ctx, orderKey, 0, -maxItems-1).Result()
+	if err != nil || len(dropped) == 0 {
+		return err
+	}
+	return r.Delete(ctx, userID, dropped)
+}
+
+// FindByUser reads the items in order and removes those that have expired.
+func (r *redisInboxRepository) FindByUser(ctx context.Context, userID string) ([]*entities.InboxItem, error) {
+	ids, err := r.client.ZRange(ctx, inboxOrderKey(userID), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	values, err := r.client.HMGet(ctx, inboxItemsKey(userID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var items []*entities.InboxItem
+	var expired []string
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+
+		var item entities.InboxItem
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return nil, err
+		}
+		if now.After(item.ExpiresAt) {
+			expired = append(expired, item.ID)
+			continue
+		}
+		items = append(items, &item)
+	}
+
+	if len(expired) > 0 {
+		if err := r.Delete(ctx, userID, expired); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Delete removes the items from both the order set and the item hash.
+func (r *redisInboxRepository) Delete(ctx context.Context, userID string, itemIDs []string) error {
+	if len(itemIDs) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(itemIDs))
+	for i, id := range itemIDs {
+		members[i] = id
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, inboxOrderKey(userID), members...)
+		pipe.HDel(ctx, inboxItemsKey(userID), itemIDs...)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MessageDedupeRepository defines the interface for remembering client-supplied message IDs,
+// so that a message retried by a client is only stored once.
+type MessageDedupeRepository interface {
+	// Reserve claims a client message ID of a user while its message is processed. The reservation lapses
+	// after the lease unless it is completed. If the ID was already claimed, it returns false along with the
+	// stored message, which is nil while the first attempt is still in progress.
+	Reserve(ctx context.Context, userID, clientMessageID string, lease time.Duration) (bool, *entities.MessageResponse, error)
+	// Complete records the message stored for a reserved client message ID for the whole dedupe window.
+	Complete(ctx context.Context, userID, clientMessageID string, message *entities.MessageResponse, window time.Duration) error
+	// Release forgets a reserved client message ID, so that a failed message can be retried.
+	Release(ctx context.Context, userID, clientMessageID string) error
+}
+
+// redisMessageDedupeRepository is a Redis implementation of the MessageDedupeRepository.
+// A reservation is an empty key, which is replaced by the stored message once it completes.
+type redisMessageDedupeRepository struct {
+	client *redis.Client
+}
+
+// NewRedisMessageDedupeRepository creates a new Redis message dedupe repository.
+func NewRedisMessageDedupeRepository(client *redis.Client) MessageDedupeRepository {
+	return &redisMessageDedupeRepository{
+		client: client,
+	}
+}
+
+func messageDedupeKey(userID, clientMessageID string) string {
+	return "dedupe:" + userID + ":" + clientMessageID
+}
+
+// Reserve sets the key only if it does not exist, otherwise it reads the stored message.
+func (r *redisMessageDedupeRepository) Reserve(ctx context.Context, userID, clientMessageID string, lease time.Duration) (bool, *entities.MessageResponse, error) {
+	key := messageDedupeKey(userID, clientMessageID)
+
+	reserved, err := r.client.SetNX(ctx, key, "", lease).Result()
+	if err != nil || reserved {
+		return reserved, nil, err
+	}
+
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) || (err == nil && len(data) == 0) {
+		// The reservation expired in between, or the first attempt is still in progress.
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	var message entities.MessageResponse
+	if err := json.Unmarshal(data, &message); err != nil {
+		return false, nil, err
+	}
+	return false, &message, nil
+}
+
+// Complete stores the message in place of the reservation for the rest of the window.
+func (r *redisMessageDedupeRepository) Complete(ctx context.Context, userID, clientMessageID string, message *entities.MessageResponse, window time.Duration) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, messageDedupeKey(userID, clientMessageID), data, window).Err()
+}
+
+// Release deletes the reservation.
+func (r *redisMessageDedupeRepository) Release(ctx context.Context, userID, clientMessageID string) error {
+	return r.client.Del(ctx, messageDedupeKey(userID, clientMessageID)).Err()
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMessageDedupeReservationLease(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	repo := NewRedisMessageDedupeRepository(client)
+	key := messageDedupeKey("user-1", "client-1")
+
+	reserved, _, err := repo.Reserve(ctx, "user-1", "client-1", 30*time.Second)
+	if err != nil || !reserved {
+		t.Fatalf("Reserve: got %v, %v, want a reservation", reserved, err)
+	}
+	if ttl := client.TTL(ctx, key).Val(); ttl <= 0 || ttl > 30*time.Second {
+		t.Fatalf("reservation TTL: got %s, want the lease", ttl)
+	}
+	reserved, stored, err := repo.Reserve(ctx, "user-1", "client-1", 30*time.Second)
+	if err != nil || reserved || stored != nil {
+		t.Fatalf("Reserve in progress: got %v, %+v, %v, want no reservation and no message", reserved, stored, err)
+	}
+
+	message := &entities.MessageResponse{RoomID: "room-1", Content: "hello"}
+	if err := repo.Complete(ctx, "user-1", "client-1", message, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := client.TTL(ctx, key).Val(); ttl <= time.Hour {
+		t.Fatalf("completed TTL: got %s, want the dedupe window", ttl)
+	}
+	reserved, stored, err = repo.Reserve(ctx, "user-1", "client-1", 30*time.Second)
+	if err != nil || reserved || stored == nil || stored.Content != "hello" {
+		t.Fatalf("Reserve after Complete: got %v, %+v, %v, want the stored message", reserved, stored, err)
+	}
+}
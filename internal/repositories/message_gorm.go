@@ -0,0 +1,305 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// messageSearchConfig is the text search configuration used for indexing and querying message content.
+const messageSearchConfig = "english"
+
+// messageModel is the GORM model of the messages table.
+// IDs are stored as the hex form of the ObjectID, which sorts in the same order.
+type messageModel struct {
+	ID              string                             `gorm:"primaryKey;size:24"`
+	RoomID          string                             `gorm:"size:64;not null;index:idx_messages_room_timestamp,priority:1"`
+	UserID          string                             `gorm:"size:64;not null"`
+	Content         string                             `gorm:"type:text;not null"`
+	Timestamp       time.Time                          `gorm:"not null;index:idx_messages_room_timestamp,priority:2"`
+	IsRead          bool                               `gorm:"not null;default:false"`
+	Type            string                             `gorm:"size:32;not null"`
+	Metadata        jsonbColumn[entities.FileMetadata] `gorm:"type:jsonb"`
+	Flags           jsonbColumn[[]string]              `gorm:"type:jsonb"`
+	EditedAt        *time.Time
+	ClientMessageID string `gorm:"size:64"`
+}
+
+func (messageModel) TableName() string {
+	return "messages"
+}
+
+func newMessageModel(message *entities.Message) *messageModel {
+	model := &messageModel{
+		ID:              message.ID.Hex(),
+		RoomID:          message.RoomID,
+		UserID:          message.UserID,
+		Content:         message.Content,
+		Timestamp:       message.Timestamp,
+		IsRead:          message.IsRead,
+		Type:            message.Type,
+		Metadata:        jsonbColumn[entities.FileMetadata]{Data: message.Metadata},
+		EditedAt:        message.EditedAt,
+		ClientMessageID: message.ClientMessageID,
+	}
+	if len(message.Flags) > 0 {
+		model.Flags.Data = &message.Flags
+	}
+	return model
+}
+
+func (m *messageModel) toEntity() (*entities.Message, error) {
+	id, err := primitive.ObjectIDFromHex(m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message ID %q: %w", m.ID, err)
+	}
+
+	message := &entities.Message{
+		ID:              id,
+		RoomID:          m.RoomID,
+		UserID:          m.UserID,
+		Content:         m.Content,
+		Timestamp:       m.Timestamp,
+		IsRead:          m.IsRead,
+		Type:            m.Type,
+		Metadata:        m.Metadata.Data,
+		EditedAt:        m.EditedAt,
+		ClientMessageID: m.ClientMessageID,
+	}
+	if m.Flags.Data != nil {
+		message.Flags = *m.Flags.Data
+	}
+	return message, nil
+}
+
+// jsonbColumn stores a value as JSON in a JSONB column. A nil value is stored as NULL.
+type jsonbColumn[T any] struct {
+	Data *T
+}
+
+// Value implements driver.Valuer.
+func (c jsonbColumn[T]) Value() (driver.Value, error) {
+	if c.Data == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(c.Data)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (c *jsonbColumn[T]) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		c.Data = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a JSONB column", src)
+	}
+
+	c.Data = new(T)
+	return json.Unmarshal(data, c.Data)
+}
+
+// gormMessageRepository is a GORM implementation of the MessageRepository for PostgreSQL.
+type gormMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewGormMessageRepository creates a new GORM message repository.
+// The schema is expected to be up to date, see MigrateGorm.
+func NewGormMessageRepository(db *gorm.DB) MessageRepository {
+	return &gormMessageRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new message.
+func (r *gormMessageRepository) Create(ctx context.Context, message *entities.Message) error {
+	return r.db.WithContext(ctx).Create(newMessageModel(message)).Error
+}
+
+// FindByID looks up a message by ID.
+func (r *gormMessageRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*entities.Message, error) {
+	var model messageModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Hex()).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	return model.toEntity()
+}
+
+// Update sets the editable columns of a message.
+func (r *gormMessageRepository) Update(ctx context.Context, message *entities.Message) error {
+	model := newMessageModel(message)
+	result := r.db.WithContext(ctx).
+		Model(&messageModel{}).
+		Where("id = ?", model.ID).
+		Updates(map[string]interface{}{
+			"content":   model.Content,
+			"flags":     model.Flags,
+			"edited_at": model.EditedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// Delete removes a message.
+func (r *gormMessageRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result := r.db.WithContext(ctx).Delete(&messageModel{}, "id = ?", id.Hex())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// FindByRoom retrieves all messages for a given room, sorted by timestamp.
+func (r *gormMessageRepository) FindByRoom(ctx context.Context, roomID string) ([]*entities.Message, error) {
+	var models []messageModel
+	err := r.db.WithContext(ctx).
+		Where("room_id = ?", roomID).
+		Order("timestamp ASC").
+		Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	return toMessageEntities(models)
+}
+
+// FindPage retrieves a page of messages for a room, newest first.
+func (r *gormMessageRepository) FindPage(ctx context.Context, roomID string, query MessageQuery) ([]*entities.Message, error) {
+	db := r.db.WithContext(ctx).Where("room_id = ?", roomID)
+
+	if !query.Since.IsZero() {
+		db = db.Where("timestamp >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("timestamp <= ?", query.Until)
+	}
+	if len(query.Types) > 0 {
+		db = db.Where("type IN ?", query.Types)
+	}
+	if query.Before != nil {
+		db = db.Where("(timestamp < ? OR (timestamp = ? AND id < ?))",
+			query.Before.Timestamp, query.Before.Timestamp, query.Before.ID.Hex())
+	}
+
+	var models []messageModel
+	err := db.Order("timestamp DESC").Order("id DESC").Limit(int(query.Limit)).Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	return toMessageEntities(models)
+}
+
+// Search runs a PostgreSQL full-text search over message content, sorted by rank.
+// The cursor is the number of results already returned.
+func (r *gormMessageRepository) Search(ctx context.Context, query string, roomIDs []string, limit int64, cursor string) ([]*entities.Message, string, error) {
+	var offset int64
+	if cursor != "" {
+		var err error
+		offset, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+		}
+	}
+
+	document := "to_tsvector('" + messageSearchConfig + "', content)"
+	tsQuery := "websearch_to_tsquery('" + messageSearchConfig + "', ?)"
+
+	db := r.db.WithContext(ctx).Where(document+" @@ "+tsQuery, query)
+	if len(roomIDs) > 0 {
+		db = db.Where("room_id IN ?", roomIDs)
+	}
+
+	var models []messageModel
+	err := db.
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "ts_rank(" + document + ", " + tsQuery + ") DESC, timestamp DESC",
+			Vars:               []interface{}{query},
+			WithoutParentheses: true,
+		}}).
+		Offset(int(offset)).
+		Limit(int(limit + 1)). // Fetch one extra result to know whether there is a next page.
+		Find(&models).Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if int64(len(models)) > limit {
+		models = models[:limit]
+		next = strconv.FormatInt(offset+limit, 10)
+	}
+
+	messages, err := toMessageEntities(models)
+	return messages, next, err
+}
+
+// fileMetadataBatchSize is the number of messages ForEachFileMetadata loads at a time.
+const fileMetadataBatchSize = 1000
+
+// ForEachFileMetadata loads the metadata of the messages that have any, in batches.
+func (r *gormMessageRepository) ForEachFileMetadata(ctx context.Context, fn func(*entities.FileMetadata) error) error {
+	var models []messageModel
+	return r.db.WithContext(ctx).
+		Select("id", "metadata").
+		Where("metadata IS NOT NULL").
+		FindInBatches(&models, fileMetadataBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, model := range models {
+				if model.Metadata.Data == nil {
+					continue
+				}
+				if err := fn(model.Metadata.Data); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// toMessageEntities converts a slice of models to entities.
+func toMessageEntities(models []messageModel) ([]*entities.Message, error) {
+	messages := make([]*entities.Message, 0, len(models))
+	for i := range models {
+		message, err := models[i].toEntity()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// migrateMessageSearchIndex creates the expression index used by full-text search,
+// which GORM cannot declare through struct tags.
+func migrateMessageSearchIndex(db *gorm.DB) error {
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_content_search ON messages USING GIN (to_tsvector('" +
+		messageSearchConfig + "', content))").Error
+}
@@ -0,0 +1,42 @@
+package repositories_test
+
+import (
+	"api-gateway/internal/repositories"
+	"api-gateway/internal/repositories/repotest"
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newTestGorm connects to the database at POSTGRES_TEST_DSN and migrates it,
+// skipping the test if it is not set.
+func newTestGorm(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connecting to PostgreSQL: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repositories.MigrateGorm(db); err != nil {
+		t.Fatalf("migrating PostgreSQL schema: %v", err)
+	}
+	return db
+}
+
+func TestGormMessageRepository(t *testing.T) {
+	db := newTestGorm(t)
+	repotest.TestMessageRepository(t, func(t *testing.T) repositories.MessageRepository {
+		return repositories.NewGormMessageRepository(db)
+	})
+}
@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryMessageRepository is an in-memory implementation of the MessageRepository
+// for local development and tests. Messages are copied in and out, so callers cannot
+// modify stored messages.
+type memoryMessageRepository struct {
+	mu       sync.RWMutex
+	messages map[primitive.ObjectID]*entities.Message
+}
+
+// NewMemoryMessageRepository creates a new, empty in-memory message repository.
+func NewMemoryMessageRepository() MessageRepository {
+	return &memoryMessageRepository{
+		messages: make(map[primitive.ObjectID]*entities.Message),
+	}
+}
+
+// Create stores a copy of the message.
+func (r *memoryMessageRepository) Create(ctx context.Context, message *entities.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.messages[message.ID]; ok {
+		return fmt.Errorf("message %s already exists", message.ID.Hex())
+	}
+	r.messages[message.ID] = copyMessage(message)
+	return nil
+}
+
+// FindByID returns a copy of the message.
+func (r *memoryMessageRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*entities.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	message, ok := r.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	return copyMessage(message), nil
+}
+
+// Update replaces the editable fields of the stored message.
+func (r *memoryMessageRepository) Update(ctx context.Context, message *entities.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.messages[message.ID]
+	if !ok {
+		return ErrMessageNotFound
+	}
+	updated := copyMessage(stored)
+	updated.Content = message.Content
+	updated.Flags = slices.Clone(message.Flags)
+	if message.EditedAt != nil {
+		editedAt := *message.EditedAt
+		updated.EditedAt = &editedAt
+	} else {
+		updated.EditedAt = nil
+	}
+	r.messages[message.ID] = updated
+	return nil
+}
+
+// Delete removes the message.
+func (r *memoryMessageRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.messages[id]; !ok {
+		return ErrMessageNotFound
+	}
+	delete(r.messages, id)
+	return nil
+}
+
+// FindByRoom returns all messages of the room, oldest first.
+func (r *memoryMessageRepository) FindByRoom(ctx context.Context, roomID string) ([]*entities.Message, error) {
+	messages := r.filter(func(m *entities.Message) bool { return m.RoomID == roomID })
+	sort.Slice(messages, func(i, j int) bool { return messageBefore(messages[i], messages[j]) })
+	return messages, nil
+}
+
+// FindPage returns a page of the room's messages, newest first.
+func (r *memoryMessageRepository) FindPage(ctx context.Context, roomID string, query MessageQuery) ([]*entities.Message, error) {
+	messages := r.filter(func(m *entities.Message) bool {
+		switch {
+		case m.RoomID != roomID:
+			return false
+		case !query.Since.IsZero() && m.Timestamp.Before(query.Since):
+			return false
+		case !query.Until.IsZero() && m.Timestamp.After(query.Until):
+			return false
+		case len(query.Types) > 0 && !slices.Contains(query.Types, m.Type):
+			return false
+		case query.Before != nil && !messageBefore(m, &entities.Message{Timestamp: query.Before.Timestamp, ID: query.Before.ID}):
+			return false
+		}
+		return true
+	})
+	sort.Slice(messages, func(i, j int) bool { return messageBefore(messages[j], messages[i]) })
+
+	if query.Limit > 0 && int64(len(messages)) > query.Limit {
+		messages = messages[:query.Limit]
+	}
+	return messages, nil
+}
+
+// Search returns the messages containing any of the query's words, ranked by the number of
+// matching words and then newest first. The cursor is the number of results already returned.
+func (r *memoryMessageRepository) Search(ctx context.Context, query string, roomIDs []string, limit int64, cursor string) ([]*entities.Message, string, error) {
+	var offset int64
+	if cursor != "" {
+		var err error
+		offset, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+		}
+	}
+
+	terms := searchWords(query)
+	scores := make(map[primitive.ObjectID]int)
+	messages := r.filter(func(m *entities.Message) bool {
+		if len(roomIDs) > 0 && !slices.Contains(roomIDs, m.RoomID) {
+			return false
+		}
+		words := searchWords(m.Content)
+		for _, term := range terms {
+			if slices.Contains(words, term) {
+				scores[m.ID]++
+			}
+		}
+		return scores[m.ID] > 0
+	})
+	sort.Slice(messages, func(i, j int) bool {
+		if scores[messages[i].ID] != scores[messages[j].ID] {
+			return scores[messages[i].ID] > scores[messages[j].ID]
+		}
+		return messageBefore(messages[j], messages[i])
+	})
+
+	if offset >= int64(len(messages)) {
+		return nil, "", nil
+	}
+	messages = messages[offset:]
+
+	var next string
+	if int64(len(messages)) > limit {
+		messages = messages[:limit]
+		next = strconv.FormatInt(offset+limit, 10)
+	}
+	return messages, next, nil
+}
+
+// ForEachFileMetadata calls fn with copies of the metadata of the messages that have any.
+func (r *memoryMessageRepository) ForEachFileMetadata(ctx context.Context, fn func(*entities.FileMetadata) error) error {
+	messages := r.filter(func(m *entities.Message) bool { return m.Metadata != nil })
+	for _, message := range messages {
+		if err := fn(message.Metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter returns copies of the messages matching the predicate.
+// The predicate is called with the read lock held.
+func (r *memoryMessageRepository) filter(match func(*entities.Message) bool) []*entities.Message {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var messages []*entities.Message
+	for _, message := range r.messages {
+		if match(message) {
+			messages = append(messages, copyMessage(message))
+		}
+	}
+	return messages
+}
+
+// messageBefore orders messages by timestamp, with the ID breaking ties.
+func messageBefore(a, b *entities.Message) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.ID.Hex() < b.ID.Hex()
+}
+
+// searchWords splits text into lower-cased words.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// copyMessage returns a deep copy of a message.
+func copyMessage(message *entities.Message) *entities.Message {
+	c := *message
+	c.Flags = slices.Clone(message.Flags)
+	if message.Metadata != nil {
+		metadata := *message.Metadata
+		metadata.Thumbnails = slices.Clone(message.Metadata.Thumbnails)
+		c.Metadata = &metadata
+	}
+	if message.EditedAt != nil {
+		editedAt := *message.EditedAt
+		c.EditedAt = &editedAt
+	}
+	return &c
+}
@@ -0,0 +1,13 @@
+package repositories_test
+
+import (
+	"api-gateway/internal/repositories"
+	"api-gateway/internal/repositories/repotest"
+	"testing"
+)
+
+func TestMemoryMessageRepository(t *testing.T) {
+	repotest.TestMessageRepository(t, func(t *testing.T) repositories.MessageRepository {
+		return repositories.NewMemoryMessageRepository()
+	})
+}
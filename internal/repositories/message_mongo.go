@@ -3,19 +3,61 @@ package repositories
 import (
 	"api-gateway/internal/entities"
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrMessageNotFound is returned when a message does not exist. It wraps ErrNotFound.
+var ErrMessageNotFound = fmt.Errorf("message %w", ErrNotFound)
+
+// ErrInvalidCursor is wrapped by the error Search returns for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
 // MessageRepository defines the interface for message data storage.
 type MessageRepository interface {
 	// Create stores a new message in the database.
 	Create(ctx context.Context, message *entities.Message) error
+	// FindByID retrieves a message by its ID, or returns ErrMessageNotFound.
+	FindByID(ctx context.Context, id primitive.ObjectID) (*entities.Message, error)
+	// Update replaces the content, flags and edit time of a message, or returns ErrMessageNotFound.
+	Update(ctx context.Context, message *entities.Message) error
+	// Delete removes a message, or returns ErrMessageNotFound.
+	Delete(ctx context.Context, id primitive.ObjectID) error
 	// FindByRoom retrieves all messages for a given room, sorted by timestamp.
 	FindByRoom(ctx context.Context, roomID string) ([]*entities.Message, error)
+	// FindPage retrieves up to query.Limit messages for a room matching the query, newest first.
+	FindPage(ctx context.Context, roomID string, query MessageQuery) ([]*entities.Message, error)
+	// Search retrieves up to limit messages matching a full-text query, best matches first.
+	// An empty roomIDs searches every room. The returned cursor fetches the next page and is empty on the last one.
+	// Malformed cursors return an error wrapping ErrInvalidCursor.
+	Search(ctx context.Context, query string, roomIDs []string, limit int64, cursor string) ([]*entities.Message, string, error)
+	// ForEachFileMetadata calls fn with the file metadata of every message that has any, in no particular order.
+	// It stops at the first error returned by fn.
+	ForEachFileMetadata(ctx context.Context, fn func(*entities.FileMetadata) error) error
+}
+
+// MessageQuery filters and paginates the messages of a room.
+type MessageQuery struct {
+	Since  time.Time      // Zero means no lower bound
+	Until  time.Time      // Zero means no upper bound
+	Types  []string       // Empty means all types
+	Before *MessageCursor // Only messages older than the cursor, for fetching the next page
+	Limit  int64
+}
+
+// MessageCursor identifies a position in a room's history.
+// Messages are ordered by timestamp, with the ID breaking ties.
+type MessageCursor struct {
+	Timestamp time.Time
+	ID        primitive.ObjectID
 }
 
 // mongoMessageRepository is a MongoDB implementation of the MessageRepository.
@@ -25,9 +67,22 @@ type mongoMessageRepository struct {
 
 // NewMongoMessageRepository creates a new MongoDB message repository.
 func NewMongoMessageRepository(db *mongo.Database) MessageRepository {
-	return &mongoMessageRepository{
+	repo := &mongoMessageRepository{
 		collection: db.Collection("messages"),
 	}
+	repo.ensureIndexes(context.Background())
+	return repo
+}
+
+// ensureIndexes creates the indexes used for history and search queries if they do not exist yet.
+func (r *mongoMessageRepository) ensureIndexes(ctx context.Context) {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "room_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "content", Value: "text"}}},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Printf("Failed to create message indexes: %v", err)
+	}
 }
 
 // Create inserts a new message into the MongoDB collection.
@@ -42,6 +97,48 @@ func (r *mongoMessageRepository) Create(ctx context.Context, message *entities.M
 	return nil
 }
 
+// FindByID retrieves a single message by its ID.
+func (r *mongoMessageRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*entities.Message, error) {
+	var message entities.Message
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&message)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// Update sets the editable fields of a message.
+func (r *mongoMessageRepository) Update(ctx context.Context, message *entities.Message) error {
+	update := bson.M{"$set": bson.M{
+		"content":   message.Content,
+		"flags":     message.Flags,
+		"edited_at": message.EditedAt,
+	}}
+	result, err := r.collection.UpdateByID(ctx, message.ID, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// Delete removes a message from the collection.
+func (r *mongoMessageRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
 // FindByRoom retrieves all messages for a given room, sorted by timestamp.
 func (r *mongoMessageRepository) FindByRoom(ctx context.Context, roomID string) ([]*entities.Message, error) {
 	filter := bson.M{"room_id": roomID}
@@ -60,3 +157,115 @@ func (r *mongoMessageRepository) FindByRoom(ctx context.Context, roomID string)
 
 	return messages, nil
 }
+
+// FindPage retrieves a page of messages for a room, newest first.
+func (r *mongoMessageRepository) FindPage(ctx context.Context, roomID string, query MessageQuery) ([]*entities.Message, error) {
+	filter := bson.M{"room_id": roomID}
+
+	timestamp := bson.M{}
+	if !query.Since.IsZero() {
+		timestamp["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		timestamp["$lte"] = query.Until
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+	if len(query.Types) > 0 {
+		filter["type"] = bson.M{"$in": query.Types}
+	}
+	if query.Before != nil {
+		filter["$or"] = bson.A{
+			bson.M{"timestamp": bson.M{"$lt": query.Before.Timestamp}},
+			bson.M{"timestamp": query.Before.Timestamp, "_id": bson.M{"$lt": query.Before.ID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(query.Limit)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*entities.Message
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Search runs a text search over message content, sorted by relevance.
+// The cursor is the number of results already returned.
+func (r *mongoMessageRepository) Search(ctx context.Context, query string, roomIDs []string, limit int64, cursor string) ([]*entities.Message, string, error) {
+	var offset int64
+	if cursor != "" {
+		var err error
+		offset, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+		}
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": query}}
+	if len(roomIDs) > 0 {
+		filter["room_id"] = bson.M{"$in": roomIDs}
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "timestamp", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit + 1) // Fetch one extra result to know whether there is a next page.
+
+	results, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer results.Close(ctx)
+
+	var messages []*entities.Message
+	if err = results.All(ctx, &messages); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if int64(len(messages)) > limit {
+		messages = messages[:limit]
+		next = strconv.FormatInt(offset+limit, 10)
+	}
+
+	return messages, next, nil
+}
+
+// ForEachFileMetadata streams the metadata of the messages that have any, without loading the rest of them.
+func (r *mongoMessageRepository) ForEachFileMetadata(ctx context.Context, fn func(*entities.FileMetadata) error) error {
+	filter := bson.M{"metadata": bson.M{"$ne": nil}}
+	opts := options.Find().SetProjection(bson.M{"metadata": 1})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var message entities.Message
+		if err := cursor.Decode(&message); err != nil {
+			return err
+		}
+		if message.Metadata == nil {
+			continue
+		}
+		if err := fn(message.Metadata); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,38 @@
+package repositories_test
+
+import (
+	"api-gateway/internal/repositories"
+	"api-gateway/internal/repositories/repotest"
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestMongo connects to the database at MONGO_TEST_URI, skipping the test if it is not set.
+func newTestMongo(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	if err := client.Ping(context.Background(), nil); err != nil {
+		t.Fatalf("pinging MongoDB: %v", err)
+	}
+	return client.Database("chat_test")
+}
+
+func TestMongoMessageRepository(t *testing.T) {
+	db := newTestMongo(t)
+	repotest.TestMessageRepository(t, func(t *testing.T) repositories.MessageRepository {
+		return repositories.NewMongoMessageRepository(db)
+	})
+}
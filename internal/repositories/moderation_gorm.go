@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sanctionModel is the GORM model of the sanctions table.
+// A user has at most one sanction of each type per room.
+type sanctionModel struct {
+	RoomID    string `gorm:"primaryKey;size:64"`
+	UserID    string `gorm:"primaryKey;size:64"`
+	Type      string `gorm:"primaryKey;size:16"`
+	Reason    string `gorm:"type:text"`
+	CreatedBy string `gorm:"size:64;not null"`
+	CreatedAt time.Time
+	ExpiresAt *time.Time // NULL for sanctions that never expire
+}
+
+func (sanctionModel) TableName() string {
+	return "sanctions"
+}
+
+func newSanctionModel(sanction *entities.Sanction) *sanctionModel {
+	model := &sanctionModel{
+		RoomID:    sanction.RoomID,
+		UserID:    sanction.UserID,
+		Type:      string(sanction.Type),
+		Reason:    sanction.Reason,
+		CreatedBy: sanction.CreatedBy,
+		CreatedAt: sanction.CreatedAt,
+	}
+	if !sanction.ExpiresAt.IsZero() {
+		model.ExpiresAt = &sanction.ExpiresAt
+	}
+	return model
+}
+
+func (m *sanctionModel) toEntity() *entities.Sanction {
+	sanction := &entities.Sanction{
+		RoomID:    m.RoomID,
+		UserID:    m.UserID,
+		Type:      entities.SanctionType(m.Type),
+		Reason:    m.Reason,
+		CreatedBy: m.CreatedBy,
+		CreatedAt: m.CreatedAt,
+	}
+	if m.ExpiresAt != nil {
+		sanction.ExpiresAt = *m.ExpiresAt
+	}
+	return sanction
+}
+
+// auditEntryModel is the GORM model of the moderation audit log table.
+// IDs are stored as the hex form of the ObjectID, like message IDs.
+type auditEntryModel struct {
+	ID           string    `gorm:"primaryKey;size:24"`
+	Action       string    `gorm:"size:16;not null"`
+	RoomID       string    `gorm:"size:64;not null;index:idx_moderation_audit_log_room_timestamp,priority:1"`
+	ActorUserID  string    `gorm:"size:64;not null"`
+	TargetUserID string    `gorm:"size:64;not null"`
+	MessageID    string    `gorm:"size:24"`
+	Reason       string    `gorm:"type:text"`
+	Duration     string    `gorm:"size:32"`
+	Timestamp    time.Time `gorm:"not null;index:idx_moderation_audit_log_room_timestamp,priority:2"`
+}
+
+func (auditEntryModel) TableName() string {
+	return "moderation_audit_log"
+}
+
+func newAuditEntryModel(entry *entities.AuditEntry) *auditEntryModel {
+	id := entry.ID
+	if id.IsZero() {
+		id = primitive.NewObjectID()
+	}
+	return &auditEntryModel{
+		ID:           id.Hex(),
+		Action:       string(entry.Action),
+		RoomID:       entry.RoomID,
+		ActorUserID:  entry.ActorUserID,
+		TargetUserID: entry.TargetUserID,
+		MessageID:    entry.MessageID,
+		Reason:       entry.Reason,
+		Duration:     entry.Duration,
+		Timestamp:    entry.Timestamp,
+	}
+}
+
+func (m *auditEntryModel) toEntity() (*entities.AuditEntry, error) {
+	id, err := primitive.ObjectIDFromHex(m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit entry ID %q: %w", m.ID, err)
+	}
+	return &entities.AuditEntry{
+		ID:           id,
+		Action:       entities.ModerationAction(m.Action),
+		RoomID:       m.RoomID,
+		ActorUserID:  m.ActorUserID,
+		TargetUserID: m.TargetUserID,
+		MessageID:    m.MessageID,
+		Reason:       m.Reason,
+		Duration:     m.Duration,
+		Timestamp:    m.Timestamp,
+	}, nil
+}
+
+// gormModerationRepository is a GORM implementation of the ModerationRepository for PostgreSQL.
+type gormModerationRepository struct {
+	db *gorm.DB
+}
+
+// NewGormModerationRepository creates a new GORM moderation repository.
+// The schema is expected to be up to date, see MigrateGorm.
+func NewGormModerationRepository(db *gorm.DB) ModerationRepository {
+	return &gormModerationRepository{
+		db: db,
+	}
+}
+
+// UpsertSanction inserts the sanction, replacing any existing sanction of the same type for the user in the room.
+func (r *gormModerationRepository) UpsertSanction(ctx context.Context, sanction *entities.Sanction) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(newSanctionModel(sanction)).Error
+}
+
+// FindActiveSanction looks up a sanction and ignores it if it has already expired.
+func (r *gormModerationRepository) FindActiveSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) (*entities.Sanction, error) {
+	var model sanctionModel
+	err := r.db.WithContext(ctx).
+		First(&model, "room_id = ? AND user_id = ? AND type = ?", roomID, userID, string(sanctionType)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sanction := model.toEntity()
+	if !sanction.IsActive(time.Now()) {
+		return nil, nil
+	}
+	return sanction, nil
+}
+
+// DeleteSanction removes a sanction from the table.
+func (r *gormModerationRepository) DeleteSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) error {
+	return r.db.WithContext(ctx).
+		Delete(&sanctionModel{}, "room_id = ? AND user_id = ? AND type = ?", roomID, userID, string(sanctionType)).Error
+}
+
+// CreateAuditEntry inserts a new entry into the audit log table.
+func (r *gormModerationRepository) CreateAuditEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	return r.db.WithContext(ctx).Create(newAuditEntryModel(entry)).Error
+}
+
+// FindAuditLog retrieves the most recent audit entries for a room, newest first.
+func (r *gormModerationRepository) FindAuditLog(ctx context.Context, roomID string, limit int64) ([]*entities.AuditEntry, error) {
+	var models []auditEntryModel
+	err := r.db.WithContext(ctx).
+		Where("room_id = ?", roomID).
+		Order("timestamp DESC").
+		Limit(int(limit)).
+		Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*entities.AuditEntry, 0, len(models))
+	for i := range models {
+		entry, err := models[i].toEntity()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ModerationRepository defines the interface for storing sanctions and the moderation audit log.
+type ModerationRepository interface {
+	// UpsertSanction creates or replaces the sanction of the same type for a user in a room.
+	UpsertSanction(ctx context.Context, sanction *entities.Sanction) error
+	// FindActiveSanction returns the active sanction of the given type, or nil if there is none.
+	FindActiveSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) (*entities.Sanction, error)
+	// DeleteSanction lifts a sanction of the given type for a user in a room.
+	DeleteSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) error
+	// CreateAuditEntry appends an entry to the moderation audit log.
+	CreateAuditEntry(ctx context.Context, entry *entities.AuditEntry) error
+	// FindAuditLog retrieves the most recent audit entries for a room, newest first.
+	FindAuditLog(ctx context.Context, roomID string, limit int64) ([]*entities.AuditEntry, error)
+}
+
+// mongoModerationRepository is a MongoDB implementation of the ModerationRepository.
+type mongoModerationRepository struct {
+	sanctions *mongo.Collection
+	auditLog  *mongo.Collection
+}
+
+// NewMongoModerationRepository creates a new MongoDB moderation repository.
+func NewMongoModerationRepository(db *mongo.Database) ModerationRepository {
+	return &mongoModerationRepository{
+		sanctions: db.Collection("sanctions"),
+		auditLog:  db.Collection("moderation_audit_log"),
+	}
+}
+
+// UpsertSanction replaces any existing sanction of the same type for the user in the room.
+func (r *mongoModerationRepository) UpsertSanction(ctx context.Context, sanction *entities.Sanction) error {
+	filter := bson.M{"room_id": sanction.RoomID, "user_id": sanction.UserID, "type": sanction.Type}
+	opts := options.Replace().SetUpsert(true)
+
+	_, err := r.sanctions.ReplaceOne(ctx, filter, sanction, opts)
+	return err
+}
+
+// FindActiveSanction looks up a sanction and ignores it if it has already expired.
+func (r *mongoModerationRepository) FindActiveSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) (*entities.Sanction, error) {
+	filter := bson.M{"room_id": roomID, "user_id": userID, "type": sanctionType}
+
+	var sanction entities.Sanction
+	if err := r.sanctions.FindOne(ctx, filter).Decode(&sanction); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !sanction.IsActive(time.Now()) {
+		return nil, nil
+	}
+
+	return &sanction, nil
+}
+
+// DeleteSanction removes a sanction from the collection.
+func (r *mongoModerationRepository) DeleteSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) error {
+	filter := bson.M{"room_id": roomID, "user_id": userID, "type": sanctionType}
+
+	_, err := r.sanctions.DeleteOne(ctx, filter)
+	return err
+}
+
+// CreateAuditEntry inserts a new entry into the audit log collection.
+func (r *mongoModerationRepository) CreateAuditEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	_, err := r.auditLog.InsertOne(ctx, entry)
+	return err
+}
+
+// FindAuditLog retrieves the most recent audit entries for a room, newest first.
+func (r *mongoModerationRepository) FindAuditLog(ctx context.Context, roomID string, limit int64) ([]*entities.AuditEntry, error) {
+	filter := bson.M{"room_id": roomID}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(limit)
+
+	cursor, err := r.auditLog.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*entities.AuditEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,21 @@
+package repositories_test
+
+import (
+	"api-gateway/internal/repositories"
+	"api-gateway/internal/repositories/repotest"
+	"testing"
+)
+
+func TestMongoModerationRepository(t *testing.T) {
+	db := newTestMongo(t)
+	repotest.TestModerationRepository(t, func(t *testing.T) repositories.ModerationRepository {
+		return repositories.NewMongoModerationRepository(db)
+	})
+}
+
+func TestGormModerationRepository(t *testing.T) {
+	db := newTestGorm(t)
+	repotest.TestModerationRepository(t, func(t *testing.T) repositories.ModerationRepository {
+		return repositories.NewGormModerationRepository(db)
+	})
+}
@@ -0,0 +1,312 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PresenceRepository defines the interface for tracking user connections across all nodes.
+// Status transitions are atomic, so that exactly one node observes each change.
+type PresenceRepository interface {
+	// Connect records a new connection of a user to a room. It reports whether the user came online.
+	Connect(ctx context.Context, userID, roomID string) (bool, error)
+	// Disconnect records a closed connection. It reports whether the user went offline.
+	Disconnect(ctx context.Context, userID, roomID string) (bool, error)
+	// Touch records activity of a connected user. It reports whether the user came back from away.
+	Touch(ctx context.Context, userID string) (bool, error)
+	// MarkAwayIfIdle marks an online user as away if they have not been active since the given time.
+	// It reports whether the status changed.
+	MarkAwayIfIdle(ctx context.Context, userID string, idleSince time.Time) (bool, error)
+	// FindOnlineUsers returns the IDs of all connected users.
+	FindOnlineUsers(ctx context.Context) ([]string, error)
+	// FindRoomUsers returns the IDs of the users connected to a room.
+	FindRoomUsers(ctx context.Context, roomID string) ([]string, error)
+	// FindUserRooms returns the IDs of the rooms a user is connected to.
+	FindUserRooms(ctx context.Context, userID string) ([]string, error)
+	// FindByUserIDs returns the presence of each user, keyed by user ID.
+	// Users who were never seen are reported as offline.
+	FindByUserIDs(ctx context.Context, userIDs []string) (map[string]*entities.Presence, error)
+	// Heartbeat keeps the connections of this node alive. It must be called more often than the node TTL.
+	Heartbeat(ctx context.Context) error
+	// ReapDeadNodes closes the connections of the nodes that stopped sending heartbeats,
+	// and returns them so that the resulting changes can be broadcast.
+	ReapDeadNodes(ctx context.Context) ([]ReapedConnection, error)
+}
+
+// ReapedConnection is a connection of a dead node that was closed on its behalf.
+type ReapedConnection struct {
+	UserID      string
+	RoomID      string
+	WentOffline bool // The user has no connections left
+}
+
+// redisPresenceRepository is a Redis implementation of the PresenceRepository.
+// Each user has a hash with their connection count, status and timestamps, and
+// connection counts are also kept per room and per user's rooms.
+// Every node also records its own connections, so that the counters can be corrected
+// when a node dies without closing them, once its liveness key expires.
+type redisPresenceRepository struct {
+	client  *redis.Client
+	nodeID  string
+	nodeTTL time.Duration
+}
+
+// NewRedisPresenceRepository creates a new Redis presence repository for the node with the given ID,
+// which must be unique across the cluster. The node is considered dead if it sends no heartbeat within nodeTTL.
+func NewRedisPresenceRepository(client *redis.Client, nodeID string, nodeTTL time.Duration) PresenceRepository {
+	return &redisPresenceRepository{
+		client:  client,
+		nodeID:  nodeID,
+		nodeTTL: nodeTTL,
+	}
+}
+
+const (
+	presenceOnlineKey = "presence:online"
+	presenceNodesKey  = "presence:nodes"
+)
+
+// Key prefixes, also passed to the reap script, which builds the keys of the reaped users.
+const (
+	presenceUserPrefix      = "presence:user:"
+	presenceRoomPrefix      = "presence:room:"
+	presenceUserRoomsPrefix = "presence:user-rooms:"
+)
+
+func presenceUserKey(userID string) string {
+	return presenceUserPrefix + userID
+}
+
+func presenceRoomKey(roomID string) string {
+	return presenceRoomPrefix + roomID
+}
+
+func presenceUserRoomsKey(userID string) string {
+	return presenceUserRoomsPrefix + userID
+}
+
+// presenceNodeKey holds the connection counts of a node, keyed by user and room.
+func presenceNodeKey(nodeID string) string {
+	return "presence:node:" + nodeID
+}
+
+// presenceNodeAliveKey expires when the node stops sending heartbeats.
+func presenceNodeAliveKey(nodeID string) string {
+	return "presence:node:" + nodeID + ":alive"
+}
+
+// presenceConnectionField identifies the connections of a user to a room in a node's hash.
+func presenceConnectionField(userID, roomID string) string {
+	return userID + "\x00" + roomID
+}
+
+var connectScript = redis.NewScript(`
+redis.call('HINCRBY', KEYS[5], ARGV[4], 1)
+redis.call('SET', KEYS[6], 1, 'PX', ARGV[6])
+redis.call('SADD', KEYS[7], ARGV[5])
+redis.call('HINCRBY', KEYS[1], 'connections', 1)
+redis.call('HSET', KEYS[1], 'last_active', ARGV[3])
+redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+redis.call('HINCRBY', KEYS[3], ARGV[2], 1)
+redis.call('SADD', KEYS[4], ARGV[1])
+local previous = redis.call('HGET', KEYS[1], 'status')
+redis.call('HSET', KEYS[1], 'status', 'online')
+if previous == 'online' then return 0 end
+return 1
+`)
+
+// disconnectScript ignores connections that were already closed by a reap of this node.
+var disconnectScript = redis.NewScript(`
+local own = redis.call('HINCRBY', KEYS[5], ARGV[4], -1)
+if own <= 0 then redis.call('HDEL', KEYS[5], ARGV[4]) end
+if own < 0 then return 0 end
+local connections = redis.call('HINCRBY', KEYS[1], 'connections', -1)
+if redis.call('HINCRBY', KEYS[2], ARGV[1], -1) <= 0 then redis.call('HDEL', KEYS[2], ARGV[1]) end
+if redis.call('HINCRBY', KEYS[3], ARGV[2], -1) <= 0 then redis.call('HDEL', KEYS[3], ARGV[2]) end
+if connections > 0 then return 0 end
+redis.call('HSET', KEYS[1], 'connections', 0, 'status', 'offline', 'last_seen', ARGV[3])
+redis.call('SREM', KEYS[4], ARGV[1])
+return 1
+`)
+
+var heartbeatScript = redis.NewScript(`
+redis.call('SET', KEYS[1], 1, 'PX', ARGV[2])
+redis.call('SADD', KEYS[2], ARGV[1])
+return 1
+`)
+
+// reapNodeScript subtracts the connections of a dead node from the counters, marking users
+// without connections left offline, and forgets the node. It returns the reaped connections
+// as triples of user ID, room ID and whether the user went offline. Nodes still alive are left alone,
+// and a concurrent reap of the same node finds nothing left to do.
+var reapNodeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return {} end
+local entries = redis.call('HGETALL', KEYS[2])
+local reaped = {}
+for i = 1, #entries, 2 do
+	local field, count = entries[i], tonumber(entries[i + 1])
+	local sep = string.find(field, '\0', 1, true)
+	if sep and count > 0 then
+		local userID, roomID = string.sub(field, 1, sep - 1), string.sub(field, sep + 1)
+		local userKey = ARGV[3] .. userID
+		local roomKey = ARGV[4] .. roomID
+		local userRoomsKey = ARGV[5] .. userID
+		local connections = redis.call('HINCRBY', userKey, 'connections', -count)
+		if redis.call('HINCRBY', roomKey, userID, -count) <= 0 then redis.call('HDEL', roomKey, userID) end
+		if redis.call('HINCRBY', userRoomsKey, roomID, -count) <= 0 then redis.call('HDEL', userRoomsKey, roomID) end
+		local offline = 0
+		if connections <= 0 and redis.call('HGET', userKey, 'status') ~= 'offline' then
+			redis.call('HSET', userKey, 'connections', 0, 'status', 'offline', 'last_seen', ARGV[2])
+			redis.call('SREM', KEYS[4], userID)
+			offline = 1
+		end
+		table.insert(reaped, userID)
+		table.insert(reaped, roomID)
+		table.insert(reaped, offline)
+	end
+end
+redis.call('DEL', KEYS[2])
+redis.call('SREM', KEYS[3], ARGV[1])
+return reaped
+`)
+
+var touchScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], 'last_active', ARGV[1])
+if redis.call('HGET', KEYS[1], 'status') ~= 'away' then return 0 end
+redis.call('HSET', KEYS[1], 'status', 'online')
+return 1
+`)
+
+var markAwayScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') ~= 'online' then return 0 end
+if tonumber(redis.call('HGET', KEYS[1], 'last_active') or '0') >= tonumber(ARGV[1]) then return 0 end
+redis.call('HSET', KEYS[1], 'status', 'away')
+return 1
+`)
+
+// connectionKeys returns the keys of the connect and disconnect scripts.
+func (r *redisPresenceRepository) connectionKeys(userID, roomID string) []string {
+	return []string{
+		presenceUserKey(userID), presenceRoomKey(roomID), presenceUserRoomsKey(userID), presenceOnlineKey,
+		presenceNodeKey(r.nodeID), presenceNodeAliveKey(r.nodeID), presenceNodesKey,
+	}
+}
+
+// Connect increments the user's connection counters and marks them online.
+func (r *redisPresenceRepository) Connect(ctx context.Context, userID, roomID string) (bool, error) {
+	changed, err := connectScript.Run(ctx, r.client, r.connectionKeys(userID, roomID),
+		userID, roomID, time.Now().UnixMilli(), presenceConnectionField(userID, roomID), r.nodeID, r.nodeTTL.Milliseconds(),
+	).Int()
+	return changed == 1, err
+}
+
+// Disconnect decrements the user's connection counters and marks them offline after their last connection.
+func (r *redisPresenceRepository) Disconnect(ctx context.Context, userID, roomID string) (bool, error) {
+	changed, err := disconnectScript.Run(ctx, r.client, r.connectionKeys(userID, roomID),
+		userID, roomID, time.Now().UnixMilli(), presenceConnectionField(userID, roomID),
+	).Int()
+	return changed == 1, err
+}
+
+// Heartbeat refreshes the liveness key of this node and registers it for reaping.
+func (r *redisPresenceRepository) Heartbeat(ctx context.Context) error {
+	keys := []string{presenceNodeAliveKey(r.nodeID), presenceNodesKey}
+	return heartbeatScript.Run(ctx, r.client, keys, r.nodeID, r.nodeTTL.Milliseconds()).Err()
+}
+
+// ReapDeadNodes runs the reap script for every registered node other than this one.
+func (r *redisPresenceRepository) ReapDeadNodes(ctx context.Context) ([]ReapedConnection, error) {
+	nodeIDs, err := r.client.SMembers(ctx, presenceNodesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var reaped []ReapedConnection
+	for _, nodeID := range nodeIDs {
+		if nodeID == r.nodeID {
+			continue
+		}
+		keys := []string{presenceNodeAliveKey(nodeID), presenceNodeKey(nodeID), presenceNodesKey, presenceOnlineKey}
+		result, err := reapNodeScript.Run(ctx, r.client, keys,
+			nodeID, time.Now().UnixMilli(), presenceUserPrefix, presenceRoomPrefix, presenceUserRoomsPrefix,
+		).Slice()
+		if err != nil {
+			return reaped, err
+		}
+		for i := 0; i+2 < len(result); i += 3 {
+			userID, _ := result[i].(string)
+			roomID, _ := result[i+1].(string)
+			offline, _ := result[i+2].(int64)
+			reaped = append(reaped, ReapedConnection{UserID: userID, RoomID: roomID, WentOffline: offline == 1})
+		}
+	}
+	return reaped, nil
+}
+
+// Touch updates the user's last activity and brings them back online from away.
+func (r *redisPresenceRepository) Touch(ctx context.Context, userID string) (bool, error) {
+	changed, err := touchScript.Run(ctx, r.client, []string{presenceUserKey(userID)}, time.Now().UnixMilli()).Int()
+	return changed == 1, err
+}
+
+// MarkAwayIfIdle marks the user away if their last activity is older than idleSince.
+func (r *redisPresenceRepository) MarkAwayIfIdle(ctx context.Context, userID string, idleSince time.Time) (bool, error) {
+	changed, err := markAwayScript.Run(ctx, r.client, []string{presenceUserKey(userID)}, idleSince.UnixMilli()).Int()
+	return changed == 1, err
+}
+
+// FindOnlineUsers returns the set of connected users.
+func (r *redisPresenceRepository) FindOnlineUsers(ctx context.Context) ([]string, error) {
+	return r.client.SMembers(ctx, presenceOnlineKey).Result()
+}
+
+// FindRoomUsers returns the users with open connections to the room.
+func (r *redisPresenceRepository) FindRoomUsers(ctx context.Context, roomID string) ([]string, error) {
+	return r.client.HKeys(ctx, presenceRoomKey(roomID)).Result()
+}
+
+// FindUserRooms returns the rooms the user has open connections to.
+func (r *redisPresenceRepository) FindUserRooms(ctx context.Context, userID string) ([]string, error) {
+	return r.client.HKeys(ctx, presenceUserRoomsKey(userID)).Result()
+}
+
+// FindByUserIDs reads the presence hash of every user in a single pipeline.
+func (r *redisPresenceRepository) FindByUserIDs(ctx context.Context, userIDs []string) (map[string]*entities.Presence, error) {
+	pipe := r.client.Pipeline()
+	cmds := make(map[string]*redis.MapStringStringCmd, len(userIDs))
+	for _, userID := range userIDs {
+		cmds[userID] = pipe.HGetAll(ctx, presenceUserKey(userID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	presences := make(map[string]*entities.Presence, len(cmds))
+	for userID, cmd := range cmds {
+		values := cmd.Val()
+		presence := &entities.Presence{
+			UserID:     userID,
+			Status:     entities.PresenceStatus(values["status"]),
+			LastActive: parseUnixMilli(values["last_active"]),
+			LastSeen:   parseUnixMilli(values["last_seen"]),
+		}
+		if presence.Status == "" {
+			presence.Status = entities.StatusOffline
+		}
+		presences[userID] = presence
+	}
+	return presences, nil
+}
+
+// parseUnixMilli parses a millisecond timestamp, returning the zero time if it is missing.
+func parseUnixMilli(value string) time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPresenceReapDeadNode(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	dead := NewRedisPresenceRepository(client, "node-a", time.Minute)
+	live := NewRedisPresenceRepository(client, "node-b", time.Minute)
+
+	// user-1 is connected through both nodes, user-2 only through the node that dies.
+	for _, connect := range []struct {
+		repo           PresenceRepository
+		userID, roomID string
+	}{
+		{dead, "user-1", "room-1"},
+		{live, "user-1", "room-2"},
+		{dead, "user-2", "room-1"},
+	} {
+		if _, err := connect.repo.Connect(ctx, connect.userID, connect.roomID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reaped, err := live.ReapDeadNodes(ctx)
+	if err != nil || len(reaped) != 0 {
+		t.Fatalf("reaping a live node: got %+v, %v, want nothing", reaped, err)
+	}
+
+	// The node stops sending heartbeats.
+	if err := client.Del(ctx, presenceNodeAliveKey("node-a")).Err(); err != nil {
+		t.Fatal(err)
+	}
+	reaped, err = live.ReapDeadNodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := make(map[string]bool)
+	for _, connection := range reaped {
+		if connection.RoomID != "room-1" {
+			t.Errorf("reaped a connection to %s, want only room-1", connection.RoomID)
+		}
+		offline[connection.UserID] = connection.WentOffline
+	}
+	if len(reaped) != 2 || offline["user-1"] || !offline["user-2"] {
+		t.Fatalf("got %+v, want user-1 still online and user-2 offline", reaped)
+	}
+
+	online, _ := live.FindOnlineUsers(ctx)
+	if len(online) != 1 || online[0] != "user-1" {
+		t.Fatalf("online users: got %v, want [user-1]", online)
+	}
+	roomUsers, _ := live.FindRoomUsers(ctx, "room-1")
+	if len(roomUsers) != 0 {
+		t.Fatalf("users of room-1: got %v, want none", roomUsers)
+	}
+	presences, _ := live.FindByUserIDs(ctx, []string{"user-2"})
+	if presences["user-2"].Status != entities.StatusOffline || presences["user-2"].LastSeen.IsZero() {
+		t.Fatalf("presence of user-2: got %+v, want offline with a last seen time", presences["user-2"])
+	}
+
+	// A second reap finds nothing, and a late disconnect from the dead node changes nothing.
+	if reaped, err := live.ReapDeadNodes(ctx); err != nil || len(reaped) != 0 {
+		t.Fatalf("second reap: got %+v, %v, want nothing", reaped, err)
+	}
+	if wentOffline, err := dead.Disconnect(ctx, "user-1", "room-1"); err != nil || wentOffline {
+		t.Fatalf("late disconnect: got %v, %v, want no change", wentOffline, err)
+	}
+	rooms, _ := live.FindUserRooms(ctx, "user-1")
+	if len(rooms) != 1 || rooms[0] != "room-2" {
+		t.Fatalf("rooms of user-1: got %v, want [room-2]", rooms)
+	}
+}
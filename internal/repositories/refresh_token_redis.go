@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrRefreshTokenNotFound is returned when a refresh token is unknown or expired.
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented again.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+// RefreshTokenRepository defines the interface for server-side refresh token storage.
+type RefreshTokenRepository interface {
+	// Create stores a refresh token until it expires.
+	Create(ctx context.Context, token *entities.RefreshToken) error
+	// Consume atomically removes a refresh token and returns it, so that it can be used only once.
+	// A token that was already consumed returns ErrRefreshTokenReused along with the token record.
+	Consume(ctx context.Context, tokenHash string) (*entities.RefreshToken, error)
+	// RevokeFamily invalidates every token issued from the same login.
+	RevokeFamily(ctx context.Context, familyID string, ttl time.Duration) error
+	// IsFamilyRevoked reports whether the token family was revoked.
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+// redisRefreshTokenRepository is a Redis implementation of the RefreshTokenRepository.
+// Consumed tokens are remembered until their original expiry to detect reuse.
+type redisRefreshTokenRepository struct {
+	client *redis.Client
+}
+
+// NewRedisRefreshTokenRepository creates a new Redis refresh token repository.
+func NewRedisRefreshTokenRepository(client *redis.Client) RefreshTokenRepository {
+	return &redisRefreshTokenRepository{
+		client: client,
+	}
+}
+
+func refreshTokenKey(tokenHash string) string {
+	return "refresh:" + tokenHash
+}
+
+func usedRefreshTokenKey(tokenHash string) string {
+	return "refresh:used:" + tokenHash
+}
+
+func revokedFamilyKey(familyID string) string {
+	return "refresh:revoked:" + familyID
+}
+
+// Create stores the token record with a TTL matching its expiry.
+func (r *redisRefreshTokenRepository) Create(ctx context.Context, token *entities.RefreshToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, refreshTokenKey(token.TokenHash), data, time.Until(token.ExpiresAt)).Err()
+}
+
+// consumeRefreshTokenScript swaps the token record for the reuse marker in one step, keeping its expiry,
+// so that a concurrent replay finds either the record or the marker. It returns the record and whether it
+// was consumed now, or nil for unknown tokens.
+var consumeRefreshTokenScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if data then
+	redis.call('RENAME', KEYS[1], KEYS[2])
+	return {1, data}
+end
+local used = redis.call('GET', KEYS[2])
+if used then
+	return {0, used}
+end
+return false
+`)
+
+// Consume runs the consume script.
+func (r *redisRefreshTokenRepository) Consume(ctx context.Context, tokenHash string) (*entities.RefreshToken, error) {
+	result, err := consumeRefreshTokenScript.Run(ctx, r.client,
+		[]string{refreshTokenKey(tokenHash), usedRefreshTokenKey(tokenHash)},
+	).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	consumed, _ := result[0].(int64)
+	data, _ := result[1].(string)
+	var token entities.RefreshToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return nil, err
+	}
+	if consumed != 1 {
+		return &token, ErrRefreshTokenReused
+	}
+	return &token, nil
+}
+
+// RevokeFamily marks the family as revoked for the given duration, which should cover
+// the lifetime of the longest-lived token in the family.
+func (r *redisRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, ttl time.Duration) error {
+	return r.client.Set(ctx, revokedFamilyKey(familyID), 1, ttl).Err()
+}
+
+// IsFamilyRevoked checks for the revocation marker of the family.
+func (r *redisRefreshTokenRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	n, err := r.client.Exists(ctx, revokedFamilyKey(familyID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
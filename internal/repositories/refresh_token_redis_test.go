@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis starts an in-process Redis server for a test.
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRefreshTokenConsumeDetectsReuse(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisRefreshTokenRepository(newTestRedis(t))
+	token := &entities.RefreshToken{TokenHash: "hash", UserID: "user-1", FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repo.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	consumed, err := repo.Consume(ctx, "hash")
+	if err != nil || consumed.FamilyID != "family" {
+		t.Fatalf("first Consume: got %+v, %v", consumed, err)
+	}
+	reused, err := repo.Consume(ctx, "hash")
+	if !errors.Is(err, ErrRefreshTokenReused) || reused == nil || reused.FamilyID != "family" {
+		t.Fatalf("second Consume: got %+v, %v, want the token and ErrRefreshTokenReused", reused, err)
+	}
+	if _, err := repo.Consume(ctx, "unknown"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Fatalf("unknown token: got %v, want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func TestRefreshTokenConcurrentConsume(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisRefreshTokenRepository(newTestRedis(t))
+	token := &entities.RefreshToken{TokenHash: "hash", UserID: "user-1", FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repo.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	const replays = 10
+	results := make(chan error, replays)
+	var wg sync.WaitGroup
+	for i := 0; i < replays; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Consume(ctx, "hash")
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var succeeded, reused int
+	for err := range results {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrRefreshTokenReused):
+			reused++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 || reused != replays-1 {
+		t.Fatalf("got %d successful and %d reused consumes, want 1 and %d", succeeded, reused, replays-1)
+	}
+}
@@ -0,0 +1,342 @@
+package repotest
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestMessageRepository runs the MessageRepository contract against the repositories
+// returned by newRepo, which is called once per subtest.
+func TestMessageRepository(t *testing.T, newRepo func(t *testing.T) repositories.MessageRepository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repositories.MessageRepository)
+	}{
+		{"FindByRoomOrdersByTimestamp", testFindByRoomOrdersByTimestamp},
+		{"RoomIsolation", testRoomIsolation},
+		{"NotFound", testMessageNotFound},
+		{"UpdateAndDelete", testUpdateAndDelete},
+		{"FindPagePaginates", testFindPagePaginates},
+		{"FindPageFilters", testFindPageFilters},
+		{"Search", testSearch},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ForEachFileMetadata", testForEachFileMetadata},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+// baseTime is truncated to milliseconds, the coarsest precision of the supported backends.
+var baseTime = time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+// newMessage builds a text message posted offset after baseTime.
+func newMessage(roomID, content string, offset time.Duration) *entities.Message {
+	return &entities.Message{
+		ID:        primitive.NewObjectID(),
+		RoomID:    roomID,
+		UserID:    "user-1",
+		Type:      "text",
+		Content:   content,
+		Timestamp: baseTime.Add(offset),
+	}
+}
+
+// createMessages stores the messages in the given order.
+func createMessages(t *testing.T, repo repositories.MessageRepository, messages ...*entities.Message) {
+	t.Helper()
+	for _, message := range messages {
+		mustNotError(t, repo.Create(context.Background(), message), "Create")
+	}
+}
+
+// assertMessageIDs checks that the messages have the expected IDs in the expected order.
+func assertMessageIDs(t *testing.T, got []*entities.Message, want ...*entities.Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("message %d: got %s (%q), want %s (%q)", i, got[i].ID.Hex(), got[i].Content, want[i].ID.Hex(), want[i].Content)
+		}
+	}
+}
+
+func testFindByRoomOrdersByTimestamp(t *testing.T, repo repositories.MessageRepository) {
+	roomID := uniqueID("room")
+	second := newMessage(roomID, "second", 2*time.Second)
+	first := newMessage(roomID, "first", time.Second)
+	third := newMessage(roomID, "third", 3*time.Second)
+	createMessages(t, repo, second, third, first)
+
+	messages, err := repo.FindByRoom(context.Background(), roomID)
+	mustNotError(t, err, "FindByRoom")
+	assertMessageIDs(t, messages, first, second, third)
+
+	if !messages[0].Timestamp.Equal(first.Timestamp) {
+		t.Errorf("timestamp: got %v, want %v", messages[0].Timestamp, first.Timestamp)
+	}
+	if messages[0].Content != "first" || messages[0].RoomID != roomID || messages[0].Type != "text" {
+		t.Errorf("stored message does not round-trip: %+v", messages[0])
+	}
+}
+
+func testRoomIsolation(t *testing.T, repo repositories.MessageRepository) {
+	ctx := context.Background()
+	roomA, roomB := uniqueID("room"), uniqueID("room")
+	inA := newMessage(roomA, "in room a", time.Second)
+	inB := newMessage(roomB, "in room b", time.Second)
+	createMessages(t, repo, inA, inB)
+
+	messages, err := repo.FindByRoom(ctx, roomA)
+	mustNotError(t, err, "FindByRoom")
+	assertMessageIDs(t, messages, inA)
+
+	messages, err = repo.FindPage(ctx, roomB, repositories.MessageQuery{Limit: 10})
+	mustNotError(t, err, "FindPage")
+	assertMessageIDs(t, messages, inB)
+
+	messages, err = repo.FindByRoom(ctx, uniqueID("room"))
+	mustNotError(t, err, "FindByRoom")
+	if len(messages) != 0 {
+		t.Fatalf("got %d messages for an empty room, want none", len(messages))
+	}
+}
+
+func testMessageNotFound(t *testing.T, repo repositories.MessageRepository) {
+	ctx := context.Background()
+	missing := newMessage(uniqueID("room"), "missing", 0)
+
+	if _, err := repo.FindByID(ctx, missing.ID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("FindByID: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Update(ctx, missing); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Update: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, missing.ID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdateAndDelete(t *testing.T, repo repositories.MessageRepository) {
+	ctx := context.Background()
+	message := newMessage(uniqueID("room"), "original", 0)
+	message.Metadata = &entities.FileMetadata{
+		URL:        "http://files/a.png",
+		FileName:   "a.png",
+		FileSize:   42,
+		MIMEType:   "image/png",
+		Thumbnails: []entities.Thumbnail{{URL: "http://files/a_256.png", Width: 256, Height: 128}},
+	}
+	createMessages(t, repo, message)
+
+	editedAt := baseTime.Add(time.Minute)
+	message.Content = "edited"
+	message.Flags = []string{"wordlist: matched a blocked word"}
+	message.EditedAt = &editedAt
+	mustNotError(t, repo.Update(ctx, message), "Update")
+
+	stored, err := repo.FindByID(ctx, message.ID)
+	mustNotError(t, err, "FindByID")
+	if stored.Content != "edited" || len(stored.Flags) != 1 || stored.EditedAt == nil || !stored.EditedAt.Equal(editedAt) {
+		t.Errorf("update was not stored: %+v", stored)
+	}
+	if !reflect.DeepEqual(stored.Metadata, message.Metadata) {
+		t.Errorf("metadata: got %+v, want %+v", stored.Metadata, message.Metadata)
+	}
+
+	mustNotError(t, repo.Delete(ctx, message.ID), "Delete")
+	if _, err := repo.FindByID(ctx, message.ID); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("FindByID after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func testFindPagePaginates(t *testing.T, repo repositories.MessageRepository) {
+	ctx := context.Background()
+	roomID := uniqueID("room")
+
+	// Several messages share a timestamp, so that the ID has to break ties.
+	var created []*entities.Message
+	for i := 0; i < 7; i++ {
+		message := newMessage(roomID, fmt.Sprintf("message %d", i), time.Duration(i/2)*time.Second)
+		created = append(created, message)
+	}
+	createMessages(t, repo, created...)
+
+	var pages [][]*entities.Message
+	query := repositories.MessageQuery{Limit: 3}
+	for {
+		page, err := repo.FindPage(ctx, roomID, query)
+		mustNotError(t, err, "FindPage")
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		if len(pages) > len(created) {
+			t.Fatal("pagination does not terminate")
+		}
+
+		oldest := page[len(page)-1]
+		query.Before = &repositories.MessageCursor{Timestamp: oldest.Timestamp, ID: oldest.ID}
+	}
+
+	if len(pages) != 3 || len(pages[0]) != 3 || len(pages[2]) != 1 {
+		t.Fatalf("got pages of sizes %v, want [3 3 1]", pageSizes(pages))
+	}
+
+	var all []*entities.Message
+	for _, page := range pages {
+		all = append(all, page...)
+	}
+	seen := make(map[primitive.ObjectID]bool)
+	for i, message := range all {
+		if seen[message.ID] {
+			t.Fatalf("message %s returned twice", message.ID.Hex())
+		}
+		seen[message.ID] = true
+
+		if i > 0 {
+			prev := all[i-1]
+			if message.Timestamp.After(prev.Timestamp) ||
+				(message.Timestamp.Equal(prev.Timestamp) && message.ID.Hex() > prev.ID.Hex()) {
+				t.Fatalf("messages are not ordered newest first at index %d", i)
+			}
+		}
+	}
+}
+
+func pageSizes(pages [][]*entities.Message) []int {
+	sizes := make([]int, len(pages))
+	for i, page := range pages {
+		sizes[i] = len(page)
+	}
+	return sizes
+}
+
+func testFindPageFilters(t *testing.T, repo repositories.MessageRepository) {
+	ctx := context.Background()
+	roomID := uniqueID("room")
+	early := newMessage(roomID, "early", time.Second)
+	middle := newMessage(roomID, "middle", 2*time.Second)
+	middleFile := newMessage(roomID, "middle file", 2*time.Second)
+	middleFile.Type = "file"
+	late := newMessage(roomID, "late", 3*time.Second)
+	createMessages(t, repo, early, middle, middleFile, late)
+
+	messages, err := repo.FindPage(ctx, roomID, repositories.MessageQuery{
+		Since: middle.Timestamp,
+		Until: middle.Timestamp,
+		Types: []string{"text"},
+		Limit: 10,
+	})
+	mustNotError(t, err, "FindPage")
+	assertMessageIDs(t, messages, middle)
+
+	messages, err = repo.FindPage(ctx, roomID, repositories.MessageQuery{Since: middle.Timestamp, Limit: 10})
+	mustNotError(t, err, "FindPage")
+	if len(messages) != 3 || messages[0].ID != late.ID {
+		t.Fatalf("since filter: got %d messages, want 3 starting with the latest", len(messages))
+	}
+}
+
+func testSearch(t *testing.T, repo repositories.MessageRepository) {
+	ctx := context.Background()
+	word := "zebra" + uniqueID("x")[2:10]
+	roomA, roomB := uniqueID("room"), uniqueID("room")
+	match := newMessage(roomA, "the "+word+" crossed the road", time.Second)
+	otherRoom := newMessage(roomB, "another "+word+" elsewhere", 2*time.Second)
+	noMatch := newMessage(roomA, "nothing to see here", 3*time.Second)
+	createMessages(t, repo, match, otherRoom, noMatch)
+
+	messages, _, err := repo.Search(ctx, word, []string{roomA}, 10, "")
+	mustNotError(t, err, "Search")
+	assertMessageIDs(t, messages, match)
+
+	messages, next, err := repo.Search(ctx, word, []string{roomA, roomB}, 1, "")
+	mustNotError(t, err, "Search")
+	if len(messages) != 1 || next == "" {
+		t.Fatalf("first page: got %d messages and cursor %q, want 1 message and a cursor", len(messages), next)
+	}
+	rest, next, err := repo.Search(ctx, word, []string{roomA, roomB}, 1, next)
+	mustNotError(t, err, "Search")
+	if len(rest) != 1 || next != "" || rest[0].ID == messages[0].ID {
+		t.Fatalf("second page: got %d messages and cursor %q, want the other message and no cursor", len(rest), next)
+	}
+
+	if _, _, err := repo.Search(ctx, word, nil, 1, "not-a-cursor"); !errors.Is(err, repositories.ErrInvalidCursor) {
+		t.Errorf("invalid cursor: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, repo repositories.MessageRepository) {
+	const writers = 20
+	roomID := uniqueID("room")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Create(context.Background(), newMessage(roomID, fmt.Sprintf("message %d", i), time.Duration(i)*time.Millisecond))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		mustNotError(t, err, "Create")
+	}
+
+	messages, err := repo.FindByRoom(context.Background(), roomID)
+	mustNotError(t, err, "FindByRoom")
+	if len(messages) != writers {
+		t.Fatalf("got %d messages, want %d", len(messages), writers)
+	}
+}
+
+func testForEachFileMetadata(t *testing.T, repo repositories.MessageRepository) {
+	roomID := uniqueID("room")
+	url := "http://files/" + uniqueID("file") + ".png"
+	file := newMessage(roomID, "file", time.Second)
+	file.Type = "file"
+	file.Metadata = &entities.FileMetadata{
+		URL:        url,
+		FileName:   "a.png",
+		FileSize:   42,
+		MIMEType:   "image/png",
+		Thumbnails: []entities.Thumbnail{{URL: "http://files/a_256.png", Width: 256, Height: 128}},
+	}
+	createMessages(t, repo, file, newMessage(roomID, "text", 2*time.Second))
+
+	var found []*entities.FileMetadata
+	err := repo.ForEachFileMetadata(context.Background(), func(metadata *entities.FileMetadata) error {
+		if metadata.URL == url {
+			found = append(found, metadata)
+		}
+		return nil
+	})
+	mustNotError(t, err, "ForEachFileMetadata")
+	if len(found) != 1 || !reflect.DeepEqual(found[0], file.Metadata) {
+		t.Fatalf("got %+v, want the metadata of the file message", found)
+	}
+
+	stop := errors.New("stop")
+	err = repo.ForEachFileMetadata(context.Background(), func(*entities.FileMetadata) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("got %v, want the error returned by the callback", err)
+	}
+}
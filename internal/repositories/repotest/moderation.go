@@ -0,0 +1,123 @@
+package repotest
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestModerationRepository runs the ModerationRepository contract against the repositories
+// returned by newRepo, which is called once per subtest.
+func TestModerationRepository(t *testing.T, newRepo func(t *testing.T) repositories.ModerationRepository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repositories.ModerationRepository)
+	}{
+		{"UpsertReplacesSanction", testUpsertReplacesSanction},
+		{"ExpiredSanctionIsInactive", testExpiredSanctionIsInactive},
+		{"DeleteSanction", testDeleteSanction},
+		{"AuditLogNewestFirst", testAuditLogNewestFirst},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+// newSanction builds a sanction of a unique user in a unique room.
+func newSanction(sanctionType entities.SanctionType, expiresAt time.Time) *entities.Sanction {
+	return &entities.Sanction{
+		RoomID:    uniqueID("room"),
+		UserID:    uniqueID("user"),
+		Type:      sanctionType,
+		Reason:    "spam",
+		CreatedBy: uniqueID("admin"),
+		CreatedAt: baseTime,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func testUpsertReplacesSanction(t *testing.T, repo repositories.ModerationRepository) {
+	ctx := context.Background()
+	sanction := newSanction(entities.SanctionMute, time.Now().Add(time.Hour).Truncate(time.Millisecond))
+	mustNotError(t, repo.UpsertSanction(ctx, sanction), "UpsertSanction")
+
+	replaced := *sanction
+	replaced.Reason = "flooding"
+	replaced.ExpiresAt = time.Time{}
+	mustNotError(t, repo.UpsertSanction(ctx, &replaced), "UpsertSanction again")
+
+	found, err := repo.FindActiveSanction(ctx, sanction.RoomID, sanction.UserID, entities.SanctionMute)
+	mustNotError(t, err, "FindActiveSanction")
+	if found == nil || found.Reason != "flooding" || !found.ExpiresAt.IsZero() {
+		t.Fatalf("FindActiveSanction: got %+v, want the replacing sanction without expiry", found)
+	}
+
+	other, err := repo.FindActiveSanction(ctx, sanction.RoomID, sanction.UserID, entities.SanctionBan)
+	mustNotError(t, err, "FindActiveSanction of another type")
+	if other != nil {
+		t.Errorf("FindActiveSanction of another type: got %+v, want nil", other)
+	}
+}
+
+func testExpiredSanctionIsInactive(t *testing.T, repo repositories.ModerationRepository) {
+	ctx := context.Background()
+	sanction := newSanction(entities.SanctionBan, time.Now().Add(-time.Minute))
+	mustNotError(t, repo.UpsertSanction(ctx, sanction), "UpsertSanction")
+
+	found, err := repo.FindActiveSanction(ctx, sanction.RoomID, sanction.UserID, entities.SanctionBan)
+	mustNotError(t, err, "FindActiveSanction")
+	if found != nil {
+		t.Errorf("FindActiveSanction: got %+v, want nil for an expired sanction", found)
+	}
+}
+
+func testDeleteSanction(t *testing.T, repo repositories.ModerationRepository) {
+	ctx := context.Background()
+	sanction := newSanction(entities.SanctionBan, time.Time{})
+	mustNotError(t, repo.UpsertSanction(ctx, sanction), "UpsertSanction")
+	mustNotError(t, repo.DeleteSanction(ctx, sanction.RoomID, sanction.UserID, entities.SanctionBan), "DeleteSanction")
+
+	found, err := repo.FindActiveSanction(ctx, sanction.RoomID, sanction.UserID, entities.SanctionBan)
+	mustNotError(t, err, "FindActiveSanction")
+	if found != nil {
+		t.Errorf("FindActiveSanction after delete: got %+v, want nil", found)
+	}
+	mustNotError(t, repo.DeleteSanction(ctx, sanction.RoomID, sanction.UserID, entities.SanctionBan), "DeleteSanction of a missing sanction")
+}
+
+func testAuditLogNewestFirst(t *testing.T, repo repositories.ModerationRepository) {
+	ctx := context.Background()
+	roomID := uniqueID("room")
+	var entries []*entities.AuditEntry
+	for i, action := range []entities.ModerationAction{entities.ActionMute, entities.ActionUnmute, entities.ActionBan} {
+		entry := &entities.AuditEntry{
+			ID:           primitive.NewObjectID(),
+			Action:       action,
+			RoomID:       roomID,
+			ActorUserID:  uniqueID("admin"),
+			TargetUserID: uniqueID("user"),
+			Timestamp:    baseTime.Add(time.Duration(i) * time.Second),
+		}
+		mustNotError(t, repo.CreateAuditEntry(ctx, entry), "CreateAuditEntry")
+		entries = append(entries, entry)
+	}
+	mustNotError(t, repo.CreateAuditEntry(ctx, &entities.AuditEntry{
+		ID: primitive.NewObjectID(), Action: entities.ActionKick, RoomID: uniqueID("room"), Timestamp: baseTime,
+	}), "CreateAuditEntry in another room")
+
+	got, err := repo.FindAuditLog(ctx, roomID, 2)
+	mustNotError(t, err, "FindAuditLog")
+	if len(got) != 2 || got[0].ID != entries[2].ID || got[1].ID != entries[1].ID {
+		t.Fatalf("FindAuditLog: got %d entries, want the 2 newest of the room, newest first", len(got))
+	}
+	if got[0].Action != entities.ActionBan || !got[0].Timestamp.Equal(entries[2].Timestamp) {
+		t.Errorf("FindAuditLog: got %+v, want %+v", got[0], entries[2])
+	}
+}
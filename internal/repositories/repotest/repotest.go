@@ -0,0 +1,36 @@
+// Package repotest provides conformance tests for repository implementations.
+//
+// Each backend proves it honours the repository contracts by running the suites
+// from its own tests, for example:
+//
+//	func TestMongoMessageRepository(t *testing.T) {
+//		repotest.TestMessageRepository(t, func(t *testing.T) repositories.MessageRepository {
+//			return repositories.NewMongoMessageRepository(db)
+//		})
+//	}
+//
+// Backends that need a database are skipped unless MONGO_TEST_URI or POSTGRES_TEST_DSN is set.
+//
+// The suites only create records with unique IDs, rooms and usernames, so they can run
+// against a shared database without cleaning it up first.
+package repotest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// uniqueID returns a random identifier with the given prefix.
+func uniqueID(prefix string) string {
+	return prefix + "-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+}
+
+// mustNotError fails the test immediately if err is not nil.
+func mustNotError(t *testing.T, err error, action string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", action, err)
+	}
+}
@@ -0,0 +1,140 @@
+package repotest
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// TestUserRepository runs the UserRepository contract against the repositories
+// returned by newRepo, which is called once per subtest.
+func TestUserRepository(t *testing.T, newRepo func(t *testing.T) repositories.UserRepository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repositories.UserRepository)
+	}{
+		{"CreateAndFind", testCreateAndFindUser},
+		{"NotFound", testUserNotFound},
+		{"DuplicateUsername", testDuplicateUsername},
+		{"FindByIDsSkipsUnknown", testFindByIDsSkipsUnknown},
+		{"ConcurrentCreates", testConcurrentUserCreates},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+// newUser builds a user with a unique ID and username.
+func newUser() *entities.User {
+	return &entities.User{
+		ID:           uniqueID("user"),
+		Username:     uniqueID("name"),
+		Role:         entities.RoleUser,
+		PasswordHash: "$2a$10$hash",
+	}
+}
+
+func testCreateAndFindUser(t *testing.T, repo repositories.UserRepository) {
+	ctx := context.Background()
+	user := newUser()
+	mustNotError(t, repo.Create(ctx, user), "Create")
+
+	byID, err := repo.FindByID(ctx, user.ID)
+	mustNotError(t, err, "FindByID")
+	if *byID != *user {
+		t.Errorf("FindByID: got %+v, want %+v", byID, user)
+	}
+
+	byName, err := repo.FindByUsername(ctx, user.Username)
+	mustNotError(t, err, "FindByUsername")
+	if *byName != *user {
+		t.Errorf("FindByUsername: got %+v, want %+v", byName, user)
+	}
+}
+
+func testUserNotFound(t *testing.T, repo repositories.UserRepository) {
+	ctx := context.Background()
+
+	if _, err := repo.FindByID(ctx, uniqueID("user")); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("FindByID: got %v, want ErrNotFound", err)
+	}
+	if _, err := repo.FindByUsername(ctx, uniqueID("name")); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("FindByUsername: got %v, want ErrNotFound", err)
+	}
+}
+
+func testDuplicateUsername(t *testing.T, repo repositories.UserRepository) {
+	ctx := context.Background()
+	user := newUser()
+	mustNotError(t, repo.Create(ctx, user), "Create")
+
+	sameName := newUser()
+	sameName.Username = user.Username
+	if err := repo.Create(ctx, sameName); !errors.Is(err, repositories.ErrUsernameTaken) {
+		t.Errorf("Create with a taken username: got %v, want ErrUsernameTaken", err)
+	}
+
+	sameID := newUser()
+	sameID.ID = user.ID
+	if err := repo.Create(ctx, sameID); !errors.Is(err, repositories.ErrUsernameTaken) {
+		t.Errorf("Create with a taken ID: got %v, want ErrUsernameTaken", err)
+	}
+}
+
+func testFindByIDsSkipsUnknown(t *testing.T, repo repositories.UserRepository) {
+	ctx := context.Background()
+	first, second := newUser(), newUser()
+	mustNotError(t, repo.Create(ctx, first), "Create")
+	mustNotError(t, repo.Create(ctx, second), "Create")
+
+	users, err := repo.FindByIDs(ctx, []string{first.ID, uniqueID("user"), second.ID})
+	mustNotError(t, err, "FindByIDs")
+	if len(users) != 2 {
+		t.Fatalf("got %d users, want 2", len(users))
+	}
+
+	users, err = repo.FindByIDs(ctx, nil)
+	mustNotError(t, err, "FindByIDs")
+	if len(users) != 0 {
+		t.Fatalf("got %d users for no IDs, want none", len(users))
+	}
+}
+
+func testConcurrentUserCreates(t *testing.T, repo repositories.UserRepository) {
+	const writers = 20
+	ctx := context.Background()
+	username := uniqueID("name")
+
+	var wg sync.WaitGroup
+	results := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user := newUser()
+			user.Username = username
+			results <- repo.Create(ctx, user)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	created := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, repositories.ErrUsernameTaken):
+			t.Errorf("Create: got %v, want nil or ErrUsernameTaken", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d concurrent creates with the same username succeeded, want exactly 1", created)
+	}
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RoomMemberRepository defines the interface for tracking which users belong to a room.
+type RoomMemberRepository interface {
+	// AddMember records that a user has joined a room.
+	AddMember(ctx context.Context, roomID, userID string) error
+	// FindMembers retrieves the IDs of all members of a room.
+	FindMembers(ctx context.Context, roomID string) ([]string, error)
+	// CountMembers returns the number of members of a room.
+	CountMembers(ctx context.Context, roomID string) (int64, error)
+}
+
+// redisRoomMemberRepository is a Redis implementation of the RoomMemberRepository.
+// The members of each room are stored in a set.
+type redisRoomMemberRepository struct {
+	client *redis.Client
+}
+
+// NewRedisRoomMemberRepository creates a new Redis room member repository.
+func NewRedisRoomMemberRepository(client *redis.Client) RoomMemberRepository {
+	return &redisRoomMemberRepository{
+		client: client,
+	}
+}
+
+func roomMembersKey(roomID string) string {
+	return "room:" + roomID + ":members"
+}
+
+// AddMember adds the user to the room's member set.
+func (r *redisRoomMemberRepository) AddMember(ctx context.Context, roomID, userID string) error {
+	return r.client.SAdd(ctx, roomMembersKey(roomID), userID).Err()
+}
+
+// FindMembers returns the room's member set.
+func (r *redisRoomMemberRepository) FindMembers(ctx context.Context, roomID string) ([]string, error) {
+	return r.client.SMembers(ctx, roomMembersKey(roomID)).Result()
+}
+
+// CountMembers returns the size of the room's member set.
+func (r *redisRoomMemberRepository) CountMembers(ctx context.Context, roomID string) (int64, error) {
+	return r.client.SCard(ctx, roomMembersKey(roomID)).Result()
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StorageUsageRepository defines the interface for the bytes each user uploaded, in total and per room,
+// within the current quota period.
+type StorageUsageRepository interface {
+	// Add charges an upload of size bytes to a user, and to a room if roomID is not empty. A quota of zero is unlimited.
+	// It reports false without changes if the upload would take the user beyond the quota, and returns the user's usage.
+	// The usage is reset period after the first upload charged to it, or never if period is zero.
+	Add(ctx context.Context, userID, roomID string, size, quota int64, period time.Duration) (bool, int64, error)
+	// Subtract refunds an upload charged in the current period. Nothing happens if the period is over.
+	Subtract(ctx context.Context, userID, roomID string, size int64) error
+	// Find returns a user's usage, which is empty if the user uploaded nothing in the current period.
+	Find(ctx context.Context, userID string) (*entities.StorageUsage, error)
+}
+
+// redisStorageUsageRepository is a Redis implementation of the StorageUsageRepository.
+// Each user's usage is a hash of the total bytes and files, and of the bytes per room, which expires with the period.
+type redisStorageUsageRepository struct {
+	client *redis.Client
+}
+
+// NewRedisStorageUsageRepository creates a new Redis storage usage repository.
+func NewRedisStorageUsageRepository(client *redis.Client) StorageUsageRepository {
+	return &redisStorageUsageRepository{
+		client: client,
+	}
+}
+
+func storageUsageKey(userID string) string {
+	return "storage:user:" + userID
+}
+
+// storageRoomFieldPrefix prefixes the fields of a usage hash holding the bytes of a room.
+const storageRoomFieldPrefix = "room:"
+
+// addStorageScript charges an upload unless it exceeds the quota, and starts the period of new usage hashes.
+var addStorageScript = redis.NewScript(`
+local used = tonumber(redis.call('HGET', KEYS[1], 'bytes') or '0')
+local size = tonumber(ARGV[1])
+local quota = tonumber(ARGV[2])
+if quota > 0 and used + size > quota then
+	return {0, used}
+end
+local new = redis.call('EXISTS', KEYS[1]) == 0
+redis.call('HINCRBY', KEYS[1], 'bytes', size)
+redis.call('HINCRBY', KEYS[1], 'files', 1)
+if ARGV[4] ~= '' then
+	redis.call('HINCRBY', KEYS[1], ARGV[4], size)
+end
+if new and tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return {1, used + size}
+`)
+
+// subtractStorageScript refunds an upload, unless the usage hash expired, so that it is never recreated without an expiry.
+var subtractStorageScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HINCRBY', KEYS[1], 'bytes', -tonumber(ARGV[1]))
+redis.call('HINCRBY', KEYS[1], 'files', -1)
+if ARGV[2] ~= '' then
+	redis.call('HINCRBY', KEYS[1], ARGV[2], -tonumber(ARGV[1]))
+end
+return 1
+`)
+
+// storageRoomField returns the hash field of a room's bytes, or an empty string without a room.
+func storageRoomField(roomID string) string {
+	if roomID == "" {
+		return ""
+	}
+	return storageRoomFieldPrefix + roomID
+}
+
+// Add runs the add script.
+func (r *redisStorageUsageRepository) Add(ctx context.Context, userID, roomID string, size, quota int64, period time.Duration) (bool, int64, error) {
+	result, err := addStorageScript.Run(ctx, r.client, []string{storageUsageKey(userID)},
+		size, quota, period.Milliseconds(), storageRoomField(roomID),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, result[1], nil
+}
+
+// Subtract runs the subtract script.
+func (r *redisStorageUsageRepository) Subtract(ctx context.Context, userID, roomID string, size int64) error {
+	return subtractStorageScript.Run(ctx, r.client, []string{storageUsageKey(userID)}, size, storageRoomField(roomID)).Err()
+}
+
+// Find reads the user's usage hash and its expiry.
+func (r *redisStorageUsageRepository) Find(ctx context.Context, userID string) (*entities.StorageUsage, error) {
+	key := storageUsageKey(userID)
+	pipe := r.client.Pipeline()
+	fieldsCmd := pipe.HGetAll(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	usage := &entities.StorageUsage{
+		UserID: userID,
+		Rooms:  make(map[string]int64),
+	}
+	for field, value := range fieldsCmd.Val() {
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch {
+		case field == "bytes":
+			usage.Bytes = n
+		case field == "files":
+			usage.Files = n
+		case strings.HasPrefix(field, storageRoomFieldPrefix) && n > 0:
+			usage.Rooms[strings.TrimPrefix(field, storageRoomFieldPrefix)] = n
+		}
+	}
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		resetsAt := time.Now().Add(ttl)
+		usage.ResetsAt = &resetsAt
+	}
+	return usage, nil
+}
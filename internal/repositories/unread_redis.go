@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UnreadRepository defines the interface for per-user, per-room unread message counters.
+type UnreadRepository interface {
+	// Increment adds one unread message in a room for each of the given users and returns their new counts.
+	Increment(ctx context.Context, roomID string, userIDs []string) (map[string]int64, error)
+	// Reset marks all messages in a room as read for a user.
+	Reset(ctx context.Context, userID, roomID string) error
+	// FindByUser retrieves the unread counts of a user, keyed by room ID. Rooms without unread messages are omitted.
+	FindByUser(ctx context.Context, userID string) (map[string]int64, error)
+}
+
+// redisUnreadRepository is a Redis implementation of the UnreadRepository.
+// The counters of each user are stored in a hash keyed by room ID.
+type redisUnreadRepository struct {
+	client *redis.Client
+}
+
+// NewRedisUnreadRepository creates a new Redis unread counter repository.
+func NewRedisUnreadRepository(client *redis.Client) UnreadRepository {
+	return &redisUnreadRepository{
+		client: client,
+	}
+}
+
+func unreadKey(userID string) string {
+	return "unread:" + userID
+}
+
+// Increment increments the room counter of every user in a single pipeline.
+func (r *redisUnreadRepository) Increment(ctx context.Context, roomID string, userIDs []string) (map[string]int64, error) {
+	pipe := r.client.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(userIDs))
+	for _, userID := range userIDs {
+		cmds[userID] = pipe.HIncrBy(ctx, unreadKey(userID), roomID, 1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(cmds))
+	for userID, cmd := range cmds {
+		counts[userID] = cmd.Val()
+	}
+	return counts, nil
+}
+
+// Reset removes the room counter of the user.
+func (r *redisUnreadRepository) Reset(ctx context.Context, userID, roomID string) error {
+	return r.client.HDel(ctx, unreadKey(userID), roomID).Err()
+}
+
+// FindByUser returns all room counters of the user.
+func (r *redisUnreadRepository) FindByUser(ctx context.Context, userID string) (map[string]int64, error) {
+	values, err := r.client.HGetAll(ctx, unreadKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(values))
+	for roomID, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		counts[roomID] = count
+	}
+	return counts, nil
+}
@@ -0,0 +1,291 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUploadNotFound is returned for unknown or expired resumable uploads.
+var ErrUploadNotFound = fmt.Errorf("upload %w", ErrNotFound)
+
+// ResumableUploadRepository defines the interface for tracking the state of resumable uploads.
+// The uploaded chunks themselves are kept in the file storage.
+type ResumableUploadRepository interface {
+	// Create stores a new upload until its ExpiresAt, and records that its length is charged
+	// to the user's quota until it is completed, deleted or released after expiring.
+	Create(ctx context.Context, upload *entities.ResumableUpload) error
+	// FindByID retrieves an upload. Unknown and expired uploads return ErrUploadNotFound.
+	FindByID(ctx context.Context, id string) (*entities.ResumableUpload, error)
+	// AppendChunk records a chunk received at offset and moves the upload's offset and expiry forward.
+	// It reports false without changes if the upload's offset is no longer offset.
+	AppendChunk(ctx context.Context, id string, offset, newOffset int64, chunkKey string, expiresAt time.Time) (bool, error)
+	// StartCompletion claims the completion of an upload. It reports false if it was already claimed.
+	StartCompletion(ctx context.Context, id string) (bool, error)
+	// CancelCompletion releases the claim of a completion that failed, so that it can be retried.
+	CancelCompletion(ctx context.Context, id string) error
+	// Complete records the stored file of an upload, which is remembered until expiresAt.
+	// The stored file keeps the upload's charge.
+	Complete(ctx context.Context, id string, result *entities.FileMetadata, expiresAt time.Time) error
+	// Delete removes an upload. It reports true if the upload was still charged, in which case the caller refunds it.
+	Delete(ctx context.Context, id string) (bool, error)
+	// ReleaseExpired removes the charges of uploads that expired before now without being completed or deleted,
+	// and returns those uploads with their UserID, RoomID and Length, so that the caller refunds them.
+	ReleaseExpired(ctx context.Context, now time.Time) ([]*entities.ResumableUpload, error)
+}
+
+// redisResumableUploadRepository is a Redis implementation of the ResumableUploadRepository.
+// An upload is a hash, and its chunk keys are a list beside it. Both expire with the upload, so the
+// charges of uploads are kept apart: a hash of each upload's charge as JSON, and a sorted set of the
+// charged uploads by expiry time.
+type redisResumableUploadRepository struct {
+	client *redis.Client
+}
+
+// NewRedisResumableUploadRepository creates a new Redis resumable upload repository.
+func NewRedisResumableUploadRepository(client *redis.Client) ResumableUploadRepository {
+	return &redisResumableUploadRepository{
+		client: client,
+	}
+}
+
+func uploadKey(id string) string {
+	return "upload:" + id
+}
+
+func uploadChunksKey(id string) string {
+	return "upload:" + id + ":chunks"
+}
+
+const (
+	uploadChargesKey  = "uploads:charges"
+	uploadExpiriesKey = "uploads:expiries"
+)
+
+// releaseExpiredBatchSize is the number of expired uploads ReleaseExpired releases per script run.
+const releaseExpiredBatchSize = 100
+
+// uploadCharge is the part of an upload needed to refund it once the upload itself has expired.
+type uploadCharge struct {
+	UserID string `json:"userId"`
+	RoomID string `json:"roomId"`
+	Length int64  `json:"length"`
+}
+
+// appendChunkScript appends a chunk only if the upload still has the expected offset,
+// so that concurrent requests for the same offset cannot both succeed.
+var appendChunkScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+if redis.call('HGET', KEYS[1], 'offset') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'offset', ARGV[2], 'expires_at', ARGV[4])
+redis.call('RPUSH', KEYS[2], ARGV[3])
+redis.call('PEXPIREAT', KEYS[1], ARGV[4])
+redis.call('PEXPIREAT', KEYS[2], ARGV[4])
+redis.call('ZADD', KEYS[3], 'XX', ARGV[4], ARGV[5])
+return 1
+`)
+
+// deleteUploadScript deletes an upload and its charge, reporting whether it was still charged.
+var deleteUploadScript = redis.NewScript(`
+redis.call('DEL', KEYS[1], KEYS[2])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return redis.call('ZREM', KEYS[4], ARGV[1])
+`)
+
+// releaseExpiredScript removes the charges of up to ARGV[2] uploads that expired before ARGV[1].
+// It returns the number of uploads it found, followed by the ID and charge of each.
+var releaseExpiredScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[1], 'LIMIT', 0, ARGV[2])
+local charges = {tostring(#ids)}
+for _, id in ipairs(ids) do
+	local charge = redis.call('HGET', KEYS[1], id)
+	if charge then
+		table.insert(charges, id)
+		table.insert(charges, charge)
+	end
+	redis.call('HDEL', KEYS[1], id)
+	redis.call('ZREM', KEYS[2], id)
+end
+return charges
+`)
+
+// startCompletionScript sets the completing field only if the upload has not expired,
+// so that the hash is never recreated without an expiry.
+var startCompletionScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+return redis.call('HSETNX', KEYS[1], 'completing', 1)
+`)
+
+// Create stores the upload's fields in a hash that expires with the upload, and its charge.
+func (r *redisResumableUploadRepository) Create(ctx context.Context, upload *entities.ResumableUpload) error {
+	key := uploadKey(upload.ID)
+	charge, err := json.Marshal(uploadCharge{UserID: upload.UserID, RoomID: upload.RoomID, Length: upload.Length})
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", upload.UserID,
+		"room_id", upload.RoomID,
+		"file_name", upload.FileName,
+		"length", upload.Length,
+		"offset", upload.Offset,
+		"created_at", upload.CreatedAt.UnixMilli(),
+		"expires_at", upload.ExpiresAt.UnixMilli(),
+	)
+	pipe.PExpireAt(ctx, key, upload.ExpiresAt)
+	pipe.HSet(ctx, uploadChargesKey, upload.ID, charge)
+	pipe.ZAdd(ctx, uploadExpiriesKey, redis.Z{Score: float64(upload.ExpiresAt.UnixMilli()), Member: upload.ID})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// FindByID reads the upload's hash and chunk list.
+func (r *redisResumableUploadRepository) FindByID(ctx context.Context, id string) (*entities.ResumableUpload, error) {
+	pipe := r.client.Pipeline()
+	fieldsCmd := pipe.HGetAll(ctx, uploadKey(id))
+	chunksCmd := pipe.LRange(ctx, uploadChunksKey(id), 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	fields := fieldsCmd.Val()
+	if len(fields) == 0 {
+		return nil, ErrUploadNotFound
+	}
+
+	upload := &entities.ResumableUpload{
+		ID:       id,
+		UserID:   fields["user_id"],
+		RoomID:   fields["room_id"],
+		FileName: fields["file_name"],
+		Chunks:   chunksCmd.Val(),
+	}
+	var err error
+	if upload.Length, err = strconv.ParseInt(fields["length"], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid length of upload %s: %w", id, err)
+	}
+	if upload.Offset, err = strconv.ParseInt(fields["offset"], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid offset of upload %s: %w", id, err)
+	}
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	upload.CreatedAt = time.UnixMilli(createdAt)
+	expiresAt, _ := strconv.ParseInt(fields["expires_at"], 10, 64)
+	upload.ExpiresAt = time.UnixMilli(expiresAt)
+
+	if result := fields["result"]; result != "" {
+		upload.Result = &entities.FileMetadata{}
+		if err := json.Unmarshal([]byte(result), upload.Result); err != nil {
+			return nil, err
+		}
+	}
+	return upload, nil
+}
+
+// AppendChunk runs the compare-and-set script.
+func (r *redisResumableUploadRepository) AppendChunk(ctx context.Context, id string, offset, newOffset int64, chunkKey string, expiresAt time.Time) (bool, error) {
+	result, err := appendChunkScript.Run(ctx, r.client,
+		[]string{uploadKey(id), uploadChunksKey(id), uploadExpiriesKey},
+		offset, newOffset, chunkKey, expiresAt.UnixMilli(), id,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	if result < 0 {
+		return false, ErrUploadNotFound
+	}
+	return result == 1, nil
+}
+
+// StartCompletion sets the completing field if it is not set.
+func (r *redisResumableUploadRepository) StartCompletion(ctx context.Context, id string) (bool, error) {
+	result, err := startCompletionScript.Run(ctx, r.client, []string{uploadKey(id)}).Int()
+	if err != nil {
+		return false, err
+	}
+	if result < 0 {
+		return false, ErrUploadNotFound
+	}
+	return result == 1, nil
+}
+
+// CancelCompletion deletes the completing field.
+func (r *redisResumableUploadRepository) CancelCompletion(ctx context.Context, id string) error {
+	return r.client.HDel(ctx, uploadKey(id), "completing").Err()
+}
+
+// Complete stores the result as JSON. The chunks are gone by then, so their list is deleted,
+// and the upload's charge is no longer tracked.
+func (r *redisResumableUploadRepository) Complete(ctx context.Context, id string, result *entities.FileMetadata, expiresAt time.Time) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, uploadKey(id), "result", data, "expires_at", expiresAt.UnixMilli())
+	pipe.PExpireAt(ctx, uploadKey(id), expiresAt)
+	pipe.Del(ctx, uploadChunksKey(id))
+	pipe.HDel(ctx, uploadChargesKey, id)
+	pipe.ZRem(ctx, uploadExpiriesKey, id)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Delete runs the delete script.
+func (r *redisResumableUploadRepository) Delete(ctx context.Context, id string) (bool, error) {
+	removed, err := deleteUploadScript.Run(ctx, r.client,
+		[]string{uploadKey(id), uploadChunksKey(id), uploadChargesKey, uploadExpiriesKey},
+		id,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return removed == 1, nil
+}
+
+// ReleaseExpired runs the release script in batches until no expired upload is left.
+func (r *redisResumableUploadRepository) ReleaseExpired(ctx context.Context, now time.Time) ([]*entities.ResumableUpload, error) {
+	var released []*entities.ResumableUpload
+	for {
+		values, err := releaseExpiredScript.Run(ctx, r.client,
+			[]string{uploadChargesKey, uploadExpiriesKey},
+			now.UnixMilli(), releaseExpiredBatchSize,
+		).StringSlice()
+		if err != nil {
+			return released, err
+		}
+
+		if len(values) == 0 {
+			return released, nil
+		}
+		found, _ := strconv.Atoi(values[0])
+		for i := 1; i+1 < len(values); i += 2 {
+			var charge uploadCharge
+			if err := json.Unmarshal([]byte(values[i+1]), &charge); err != nil {
+				return released, fmt.Errorf("invalid charge of upload %s: %w", values[i], err)
+			}
+			released = append(released, &entities.ResumableUpload{
+				ID:     values[i],
+				UserID: charge.UserID,
+				RoomID: charge.RoomID,
+				Length: charge.Length,
+			})
+		}
+		if found < releaseExpiredBatchSize {
+			return released, nil
+		}
+	}
+}
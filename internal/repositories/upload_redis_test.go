@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResumableUploadChargeIsReleasedOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRedisResumableUploadRepository(newTestRedis(t))
+	now := time.Now()
+
+	for _, upload := range []*entities.ResumableUpload{
+		{ID: "deleted", UserID: "user-1", Length: 10, ExpiresAt: now.Add(time.Hour)},
+		{ID: "completed", UserID: "user-1", Length: 20, ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired", UserID: "user-2", RoomID: "room-1", Length: 30, ExpiresAt: now.Add(time.Hour)},
+	} {
+		if err := repo.Create(ctx, upload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if charged, err := repo.Delete(ctx, "deleted"); err != nil || !charged {
+		t.Fatalf("delete: got %v, %v, want a charged upload", charged, err)
+	}
+	if charged, err := repo.Delete(ctx, "deleted"); err != nil || charged {
+		t.Fatalf("second delete: got %v, %v, want no charge", charged, err)
+	}
+
+	if err := repo.Complete(ctx, "completed", &entities.FileMetadata{URL: "/files/a.png"}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if charged, err := repo.Delete(ctx, "completed"); err != nil || charged {
+		t.Fatalf("delete of a completed upload: got %v, %v, want no charge", charged, err)
+	}
+
+	// A chunk moves the expiry forward, so the upload is only released after it.
+	if _, err := repo.AppendChunk(ctx, "expired", 0, 5, "tus/expired/0", now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if released, err := repo.ReleaseExpired(ctx, now.Add(90*time.Minute)); err != nil || len(released) != 0 {
+		t.Fatalf("release before expiry: got %+v, %v, want nothing", released, err)
+	}
+	released, err := repo.ReleaseExpired(ctx, now.Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || released[0].ID != "expired" || released[0].UserID != "user-2" ||
+		released[0].RoomID != "room-1" || released[0].Length != 30 {
+		t.Fatalf("release after expiry: got %+v, want the expired upload", released)
+	}
+	if released, err := repo.ReleaseExpired(ctx, now.Add(3*time.Hour)); err != nil || len(released) != 0 {
+		t.Fatalf("second release: got %+v, %v, want nothing", released, err)
+	}
+}
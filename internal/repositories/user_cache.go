@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// cachedUserRepository wraps a UserRepository with an in-memory LRU cache of users by ID.
+// Entries expire after a TTL, so that changes made on other nodes are picked up eventually.
+// Roles can change outside of the application, so checks of a user's role must not go through the cache.
+type cachedUserRepository struct {
+	next    UserRepository
+	size    int
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+}
+
+type userCacheEntry struct {
+	user      *entities.User
+	expiresAt time.Time
+}
+
+// NewCachedUserRepository creates a UserRepository that caches up to size users for ttl.
+// Lookups by username are not cached, since they are used to check credentials.
+func NewCachedUserRepository(next UserRepository, size int, ttl time.Duration) UserRepository {
+	return &cachedUserRepository{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// FindByID returns the cached user, or loads and caches it.
+func (r *cachedUserRepository) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	if user, ok := r.get(id); ok {
+		return user, nil
+	}
+
+	user, err := r.next.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.put(user)
+	return user, nil
+}
+
+// FindByUsername always reads from the underlying repository.
+func (r *cachedUserRepository) FindByUsername(ctx context.Context, username string) (*entities.User, error) {
+	return r.next.FindByUsername(ctx, username)
+}
+
+// FindByIDs returns the cached users and loads the rest in a single batch.
+func (r *cachedUserRepository) FindByIDs(ctx context.Context, ids []string) ([]*entities.User, error) {
+	users := make([]*entities.User, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		if user, ok := r.get(id); ok {
+			users = append(users, user)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	loaded, err := r.next.FindByIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range loaded {
+		r.put(user)
+	}
+	return append(users, loaded...), nil
+}
+
+// Create stores the user and drops any stale entry with the same ID.
+func (r *cachedUserRepository) Create(ctx context.Context, user *entities.User) error {
+	if err := r.next.Create(ctx, user); err != nil {
+		return err
+	}
+	r.remove(user.ID)
+	return nil
+}
+
+// get returns an unexpired cached user and marks it as recently used.
+func (r *cachedUserRepository) get(id string) (*entities.User, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*userCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		r.order.Remove(element)
+		delete(r.entries, id)
+		return nil, false
+	}
+	r.order.MoveToFront(element)
+	return entry.user, true
+}
+
+// put caches a user, evicting the least recently used user if the cache is full.
+func (r *cachedUserRepository) put(user *entities.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &userCacheEntry{user: user, expiresAt: time.Now().Add(r.ttl)}
+	if element, ok := r.entries[user.ID]; ok {
+		element.Value = entry
+		r.order.MoveToFront(element)
+		return
+	}
+
+	r.entries[user.ID] = r.order.PushFront(entry)
+	for r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*userCacheEntry).user.ID)
+	}
+}
+
+// remove drops a user from the cache.
+func (r *cachedUserRepository) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.entries[id]; ok {
+		r.order.Remove(element)
+		delete(r.entries, id)
+	}
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"api-gateway/internal/entities"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// userModel is the GORM model of the users table.
+type userModel struct {
+	ID           string `gorm:"primaryKey;size:64"`
+	Username     string `gorm:"uniqueIndex;size:64;not null"`
+	Role         string `gorm:"size:16;not null;default:user"`
+	PasswordHash string `gorm:"size:255"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (userModel) TableName() string {
+	return "users"
+}
+
+func (m *userModel) toEntity() *entities.User {
+	return &entities.User{
+		ID:           m.ID,
+		Username:     m.Username,
+		Role:         entities.UserRole(m.Role),
+		PasswordHash: m.PasswordHash,
+	}
+}
+
+// gormUserRepository is a GORM implementation of the UserRepository.
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewGormUserRepository creates a new GORM user repository.
+// The schema is expected to be up to date, see MigrateGorm.
+func NewGormUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{
+		db: db,
+	}
+}
+
+// FindByID looks up a user by ID.
+func (r *gormUserRepository) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	var model userModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user with ID '%s' %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+	return model.toEntity(), nil
+}
+
+// FindByUsername looks up a user by username.
+func (r *gormUserRepository) FindByUsername(ctx context.Context, username string) (*entities.User, error) {
+	var model userModel
+	if err := r.db.WithContext(ctx).First(&model, "username = ?", username).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user with username '%s' %w", username, ErrNotFound)
+		}
+		return nil, err
+	}
+	return model.toEntity(), nil
+}
+
+// FindByIDs looks up several users by ID in a single query.
+func (r *gormUserRepository) FindByIDs(ctx context.Context, ids []string) ([]*entities.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var models []userModel
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	users := make([]*entities.User, len(models))
+	for i := range models {
+		users[i] = models[i].toEntity()
+	}
+	return users, nil
+}
+
+// Create inserts a new user, returning ErrUsernameTaken if the ID or username already exists.
+func (r *gormUserRepository) Create(ctx context.Context, user *entities.User) error {
+	model := userModel{
+		ID:           user.ID,
+		Username:     user.Username,
+		Role:         string(user.Role),
+		PasswordHash: user.PasswordHash,
+	}
+
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUsernameTaken
+	}
+	return nil
+}
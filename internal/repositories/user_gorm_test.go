@@ -0,0 +1,14 @@
+package repositories_test
+
+import (
+	"api-gateway/internal/repositories"
+	"api-gateway/internal/repositories/repotest"
+	"testing"
+)
+
+func TestGormUserRepository(t *testing.T) {
+	db := newTestGorm(t)
+	repotest.TestUserRepository(t, func(t *testing.T) repositories.UserRepository {
+		return repositories.NewGormUserRepository(db)
+	})
+}
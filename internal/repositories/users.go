@@ -3,7 +3,16 @@ package repositories
 import (
 	"api-gateway/internal/entities"
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNotFound is wrapped by the errors repositories return when a record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrUsernameTaken is returned by UserRepository.Create when the username or ID already exists.
+	ErrUsernameTaken = errors.New("username is already taken")
 )
 
 // Sample users for mock data.
@@ -15,14 +24,19 @@ var (
 
 // UserRepository defines the interface for user data storage.
 type UserRepository interface {
-	// FindByID retrieves a user by their unique ID.
+	// FindByID retrieves a user by their unique ID. Unknown IDs return an error wrapping ErrNotFound.
 	FindByID(ctx context.Context, id string) (*entities.User, error)
-	// FindByUsername retrieves a user by their username.
+	// FindByUsername retrieves a user by their username. Unknown usernames return an error wrapping ErrNotFound.
 	FindByUsername(ctx context.Context, username string) (*entities.User, error)
+	// FindByIDs retrieves the users with the given IDs. Unknown IDs are skipped.
+	FindByIDs(ctx context.Context, ids []string) ([]*entities.User, error)
+	// Create stores a new user.
+	Create(ctx context.Context, user *entities.User) error
 }
 
 // MockUserRepository is an in-memory implementation of UserRepository for testing.
 type MockUserRepository struct {
+	mu    sync.RWMutex
 	users map[string]*entities.User
 }
 
@@ -39,18 +53,55 @@ func NewMockUserRepository() UserRepository {
 
 // FindByID looks up a user by ID in the mock repository.
 func (r *MockUserRepository) FindByID(ctx context.Context, id string) (*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	if user, ok := r.users[id]; ok {
 		return user, nil
 	}
-	return nil, fmt.Errorf("user with ID '%s' not found", id)
+	return nil, fmt.Errorf("user with ID '%s' %w", id, ErrNotFound)
 }
 
 // FindByUsername looks up a user by username in the mock repository.
 func (r *MockUserRepository) FindByUsername(ctx context.Context, username string) (*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	for _, user := range r.users {
 		if user.Username == username {
 			return user, nil
 		}
 	}
-	return nil, fmt.Errorf("user with username '%s' not found", username)
+	return nil, fmt.Errorf("user with username '%s' %w", username, ErrNotFound)
+}
+
+// FindByIDs looks up several users by ID in the mock repository.
+func (r *MockUserRepository) FindByIDs(ctx context.Context, ids []string) ([]*entities.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []*entities.User
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// Create adds a user to the mock repository.
+func (r *MockUserRepository) Create(ctx context.Context, user *entities.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return ErrUsernameTaken
+	}
+	for _, existing := range r.users {
+		if existing.Username == user.Username {
+			return ErrUsernameTaken
+		}
+	}
+	r.users[user.ID] = user
+	return nil
 }
@@ -0,0 +1,20 @@
+package repositories_test
+
+import (
+	"api-gateway/internal/repositories"
+	"api-gateway/internal/repositories/repotest"
+	"testing"
+	"time"
+)
+
+func TestMockUserRepository(t *testing.T) {
+	repotest.TestUserRepository(t, func(t *testing.T) repositories.UserRepository {
+		return repositories.NewMockUserRepository()
+	})
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	repotest.TestUserRepository(t, func(t *testing.T) repositories.UserRepository {
+		return repositories.NewCachedUserRepository(repositories.NewMockUserRepository(), 100, time.Minute)
+	})
+}
@@ -0,0 +1,71 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/pkg/filestorage"
+	"api-gateway/pkg/urlsigner"
+	"log"
+	"time"
+)
+
+// AttachmentSigner issues the expiring URLs through which the files attached to messages are downloaded.
+// Messages store the permanent URLs returned by the file storage; they are only signed when sent to clients.
+type AttachmentSigner interface {
+	// SignMetadata returns a copy of the file metadata whose URLs are signed for a room and the user
+	// they are sent to. URLs that were not produced by the file storage are left as they are.
+	SignMetadata(metadata *entities.FileMetadata, roomID, userID string) *entities.FileMetadata
+}
+
+type attachmentSigner struct {
+	signer      *urlsigner.Signer
+	fileStorage filestorage.FileStorage
+	downloadURL string
+	ttl         time.Duration
+}
+
+// NewAttachmentSigner creates a new AttachmentSigner.
+// Signed URLs point to the download endpoint at downloadURL and are valid for ttl.
+func NewAttachmentSigner(signer *urlsigner.Signer, fileStorage filestorage.FileStorage, downloadURL string, ttl time.Duration) AttachmentSigner {
+	return &attachmentSigner{
+		signer:      signer,
+		fileStorage: fileStorage,
+		downloadURL: downloadURL,
+		ttl:         ttl,
+	}
+}
+
+// SignMetadata signs the URLs of the file and its thumbnails.
+func (s *attachmentSigner) SignMetadata(metadata *entities.FileMetadata, roomID, userID string) *entities.FileMetadata {
+	// Expiries are rounded, so that a file sent repeatedly keeps the same URL and stays in browser caches.
+	claims := urlsigner.Claims{
+		UserID:    userID,
+		RoomID:    roomID,
+		ExpiresAt: time.Now().Add(s.ttl).Truncate(time.Minute),
+	}
+
+	signed := *metadata
+	signed.URL = s.signURL(metadata.URL, claims)
+	if metadata.Thumbnails != nil {
+		signed.Thumbnails = make([]entities.Thumbnail, len(metadata.Thumbnails))
+		for i, thumbnail := range metadata.Thumbnails {
+			thumbnail.URL = s.signURL(thumbnail.URL, claims)
+			signed.Thumbnails[i] = thumbnail
+		}
+	}
+	return &signed
+}
+
+// signURL signs the download URL of a stored file.
+func (s *attachmentSigner) signURL(fileURL string, claims urlsigner.Claims) string {
+	key, ok := s.fileStorage.KeyFromURL(fileURL)
+	if !ok {
+		return fileURL
+	}
+
+	signed, err := s.signer.Sign(s.downloadURL+"/"+key, claims)
+	if err != nil {
+		log.Printf("Failed to sign URL of file %s: %v", key, err)
+		return fileURL
+	}
+	return signed
+}
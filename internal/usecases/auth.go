@@ -0,0 +1,221 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
+	"api-gateway/pkg/token"
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything beyond 72 bytes
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// TokenPair is the DTO returned after a successful login or token refresh.
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int64  `json:"expiresIn"` // Lifetime of the access token in seconds
+}
+
+// AuthUseCase defines the business logic for registering users and issuing credentials.
+type AuthUseCase interface {
+	// Register creates a new user with the default role.
+	Register(ctx context.Context, username, password string) (*entities.User, error)
+
+	// Login verifies a user's password and issues a new token pair.
+	Login(ctx context.Context, username, password string) (*TokenPair, error)
+
+	// Refresh exchanges a refresh token for a new token pair. Each refresh token can only be used once.
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+
+	// Logout revokes the refresh token and every token rotated from the same login.
+	Logout(ctx context.Context, refreshToken string) error
+
+	// Authenticate verifies an access token and returns the ID of its user.
+	Authenticate(ctx context.Context, accessToken string) (string, error)
+
+	// RequireAdmin returns a forbidden error unless the user currently holds the admin role.
+	RequireAdmin(ctx context.Context, userID string) error
+}
+
+type authUseCase struct {
+	userRepo    repositories.UserRepository
+	tokenRepo   repositories.RefreshTokenRepository
+	tokens      *token.Manager
+	refreshTTL  time.Duration
+	dummyHashed []byte
+}
+
+// NewAuthUseCase creates a new AuthUseCase. Roles are read from userRepo, which should not be cached.
+func NewAuthUseCase(
+	userRepo repositories.UserRepository,
+	tokenRepo repositories.RefreshTokenRepository,
+	tokens *token.Manager,
+	refreshTTL time.Duration,
+) AuthUseCase {
+	// A hash to compare against when the user does not exist, so that
+	// the response time does not reveal which usernames are registered.
+	dummyHashed, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+	return &authUseCase{
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		tokens:      tokens,
+		refreshTTL:  refreshTTL,
+		dummyHashed: dummyHashed,
+	}
+}
+
+// Register validates the credentials, hashes the password and stores the user.
+func (uc *authUseCase) Register(ctx context.Context, username, password string) (*entities.User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, errs.NewBadRequestError("username must be 3-32 letters, digits, '_', '.' or '-'")
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, errs.NewBadRequestError("password must be between 8 and 72 bytes")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &entities.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		Role:         entities.RoleUser,
+		PasswordHash: string(hash),
+	}
+	if err := uc.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, repositories.ErrUsernameTaken) {
+			return nil, errs.NewBadRequestError(err.Error())
+		}
+		log.Printf("Failed to create user %s: %v", username, err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Login checks the password and starts a new refresh token family.
+func (uc *authUseCase) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	user, err := uc.userRepo.FindByUsername(ctx, username)
+	if err != nil || user.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(uc.dummyHashed, []byte(password))
+		return nil, errs.NewUnauthorizedError("invalid username or password")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, errs.NewUnauthorizedError("invalid username or password")
+	}
+
+	return uc.issueTokens(ctx, user, uuid.New().String())
+}
+
+// Refresh rotates a refresh token. Presenting an already rotated token revokes the whole family,
+// since it means the token was stolen by someone else.
+func (uc *authUseCase) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	record, err := uc.tokenRepo.Consume(ctx, token.HashRefreshToken(refreshToken))
+	switch {
+	case errors.Is(err, repositories.ErrRefreshTokenReused):
+		log.Printf("Refresh token reuse detected for user %s, revoking its family", record.UserID)
+		if err := uc.tokenRepo.RevokeFamily(ctx, record.FamilyID, uc.refreshTTL); err != nil {
+			log.Printf("Failed to revoke refresh token family: %v", err)
+		}
+		return nil, errs.NewUnauthorizedError("invalid refresh token")
+	case errors.Is(err, repositories.ErrRefreshTokenNotFound):
+		return nil, errs.NewUnauthorizedError("invalid refresh token")
+	case err != nil:
+		return nil, err
+	}
+
+	revoked, err := uc.tokenRepo.IsFamilyRevoked(ctx, record.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errs.NewUnauthorizedError("invalid refresh token")
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, record.UserID)
+	if err != nil {
+		return nil, errs.NewUnauthorizedError("invalid refresh token")
+	}
+
+	return uc.issueTokens(ctx, user, record.FamilyID)
+}
+
+// Logout revokes the family of the refresh token. Unknown tokens are ignored.
+func (uc *authUseCase) Logout(ctx context.Context, refreshToken string) error {
+	record, err := uc.tokenRepo.Consume(ctx, token.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return nil
+		}
+		if !errors.Is(err, repositories.ErrRefreshTokenReused) {
+			return err
+		}
+	}
+
+	return uc.tokenRepo.RevokeFamily(ctx, record.FamilyID, uc.refreshTTL)
+}
+
+// Authenticate verifies an access token and returns the ID of its user.
+func (uc *authUseCase) Authenticate(ctx context.Context, accessToken string) (string, error) {
+	claims, err := uc.tokens.ParseAccessToken(accessToken)
+	if err != nil {
+		return "", errs.NewUnauthorizedError(err.Error())
+	}
+	return claims.Subject, nil
+}
+
+// RequireAdmin reads the role from the user record rather than the token, so that a demotion applies
+// at once as long as the user repository is not cached.
+func (uc *authUseCase) RequireAdmin(ctx context.Context, userID string) error {
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil || user.Role != entities.AdminRole {
+		return errs.NewForbiddenError("admin role required")
+	}
+	return nil
+}
+
+// issueTokens creates an access token and a refresh token in the given family.
+func (uc *authUseCase) issueTokens(ctx context.Context, user *entities.User, familyID string) (*TokenPair, error) {
+	accessToken, _, err := uc.tokens.IssueAccessToken(user.ID, string(user.Role))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := token.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	record := &entities.RefreshToken{
+		TokenHash: token.HashRefreshToken(refreshToken),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(uc.refreshTTL),
+	}
+	if err := uc.tokenRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(uc.tokens.AccessTTL().Seconds()),
+	}, nil
+}
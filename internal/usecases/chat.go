@@ -3,9 +3,17 @@ package usecases
 import (
 	"api-gateway/internal/entities"
 	"api-gateway/internal/repositories"
+	"api-gateway/pkg/contentfilter"
+	"api-gateway/pkg/errs"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,6 +23,9 @@ import (
 // messages without being aware of the underlying transport (e.g., WebSocket).
 type ChatBroadcaster interface {
 	BroadcastToRoom(roomID string, message []byte)
+	// BroadcastToRoomRecipients sends a message to a room, passing each recipient's copy
+	// through ChatUseCase.SignForRecipient.
+	BroadcastToRoomRecipients(roomID string, message []byte)
 	SendMessage(clientID string, message []byte) error
 }
 
@@ -30,75 +41,325 @@ type ChatUseCase interface {
 
 	// ProcessMessage handles an incoming message from a user, saves it, and broadcasts it.
 	ProcessMessage(ctx context.Context, userID, roomID string, message []byte) error
+
+	// PostMessage saves and broadcasts a message sent without a WebSocket connection.
+	PostMessage(ctx context.Context, userID, roomID string, message IncomingMessage) (*entities.MessageResponse, error)
+
+	// GetMessages returns a page of a room's history, oldest first.
+	GetMessages(ctx context.Context, userID, roomID string, query HistoryQuery) (*MessagePage, error)
+
+	// SearchMessages runs a full-text search over the rooms the user may read.
+	SearchMessages(ctx context.Context, userID string, query SearchQuery) (*SearchPage, error)
+
+	// EditMessage replaces the content of a text message. Only its author may edit it.
+	EditMessage(ctx context.Context, userID, roomID, messageID, content string) (*entities.MessageResponse, error)
+
+	// DeleteMessage deletes a message. Its author and admins may delete it.
+	DeleteMessage(ctx context.Context, userID, roomID, messageID string) error
+
+	// SignForRecipient returns the copy of a broadcast message sent to one user of the room,
+	// with its files signed for that user.
+	SignForRecipient(userID, roomID string, message []byte) []byte
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// historyCacheMetrics counts the hits, misses and errors of the history cache, published at /debug/vars.
+var historyCacheMetrics = expvar.NewMap("history_cache")
+
+// HistoryQuery filters and paginates a room's history.
+type HistoryQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Types  []string
+	Limit  int
+	Cursor string // The NextCursor of the previous page; empty for the most recent page
+}
+
+// MessagePage is the DTO returned for a page of a room's history.
+type MessagePage struct {
+	Messages   []*entities.MessageResponse `json:"messages"`
+	NextCursor string                      `json:"nextCursor,omitempty"` // Fetches older messages; empty on the last page
 }
 
 type chatUseCase struct {
-	userRepo    repositories.UserRepository
-	messageRepo repositories.MessageRepository
-	broadcaster ChatBroadcaster
+	userRepo      repositories.UserRepository
+	roleRepo      repositories.UserRepository // Where roles are checked; defaults to userRepo
+	messageRepo   repositories.MessageRepository
+	broadcaster   ChatBroadcaster
+	moderation    ModerationUseCase
+	validator     MessageValidator
+	contentFilter *contentfilter.Pipeline
+	unread        UnreadUseCase
+	presence      PresenceUseCase
+	inbox         InboxUseCase
+	dedupeRepo    repositories.MessageDedupeRepository
+	dedupeWindow  time.Duration
+	historyCache  repositories.HistoryCacheRepository
+	historySize   int
+	attachments   AttachmentSigner
+	files         FileUploadUseCase
+}
+
+// SearchQuery is a full-text search request.
+// Only admins may omit RoomIDs to search every room.
+type SearchQuery struct {
+	Query   string
+	RoomIDs []string
+	Limit   int
+	Cursor  string
+}
+
+// SearchResult is the DTO for a single search match.
+type SearchResult struct {
+	Message *entities.MessageResponse `json:"message"`
+	Snippet string                    `json:"snippet"` // HTML-escaped, with matches wrapped in <mark> tags
+}
+
+// SearchPage is the DTO returned for a page of search results.
+type SearchPage struct {
+	Results    []*SearchResult `json:"results"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+// ChatOption is a functional option for configuring the chat use case.
+type ChatOption func(*chatUseCase)
+
+// WithModeration enables moderation commands and mute/ban enforcement.
+func WithModeration(moderation ModerationUseCase) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.moderation = moderation
+	}
+}
+
+// WithRoleRepository checks the roles of users against roleRepo instead of the user repository.
+// Given an uncached repository, a demotion applies at once even if user names are cached.
+func WithRoleRepository(roleRepo repositories.UserRepository) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.roleRepo = roleRepo
+	}
+}
+
+// WithMessageValidator validates and normalizes incoming messages before they are stored.
+func WithMessageValidator(validator MessageValidator) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.validator = validator
+	}
+}
+
+// WithContentFilter runs incoming message content through a content filter pipeline before it is stored.
+func WithContentFilter(pipeline *contentfilter.Pipeline) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.contentFilter = pipeline
+	}
+}
+
+// WithUnreadCounter tracks unread messages for room members as messages are stored.
+func WithUnreadCounter(unread UnreadUseCase) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.unread = unread
+	}
+}
+
+// WithPresence tracks user connections and activity for presence.
+func WithPresence(presence PresenceUseCase) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.presence = presence
+	}
+}
+
+// WithInbox lets clients acknowledge events delivered from their offline inbox.
+func WithInbox(inbox InboxUseCase) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.inbox = inbox
+	}
+}
+
+// WithDeduplication stores a message only once per client message ID and user within the window,
+// so that clients can safely retry sends.
+func WithDeduplication(dedupeRepo repositories.MessageDedupeRepository, window time.Duration) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.dedupeRepo = dedupeRepo
+		uc.dedupeWindow = window
+	}
+}
+
+// WithHistoryCache serves the most recent historySize messages of a room from a cache
+// when a user connects, instead of reading the whole history from the message repository.
+func WithHistoryCache(cache repositories.HistoryCacheRepository, historySize int) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.historyCache = cache
+		uc.historySize = historySize
+	}
+}
+
+// WithAttachmentSigner sends the files attached to messages with signed, expiring URLs.
+// Messages sent to a user are signed for that user, and broadcasts for their room.
+func WithAttachmentSigner(attachments AttachmentSigner) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.attachments = attachments
+	}
+}
+
+// WithFileReferences counts the messages referencing each uploaded file,
+// so that files are deleted along with the last message sharing them.
+// The metadata of file messages is then taken from the stored files.
+func WithFileReferences(files FileUploadUseCase) ChatOption {
+	return func(uc *chatUseCase) {
+		uc.files = files
+	}
 }
 
 func NewChatUseCase(
 	userRepo repositories.UserRepository,
 	messageRepo repositories.MessageRepository,
 	broadcaster ChatBroadcaster,
+	opts ...ChatOption,
 ) ChatUseCase {
-	return &chatUseCase{
+	uc := &chatUseCase{
 		userRepo:    userRepo,
+		roleRepo:    userRepo,
 		messageRepo: messageRepo,
 		broadcaster: broadcaster,
 	}
+
+	for _, opt := range opts {
+		opt(uc)
+	}
+
+	return uc
 }
 
+// deletedUsername is shown as the author of messages whose user no longer exists.
+const deletedUsername = "Deleted user"
+
 // toMessageResponse converts a message entity to a message DTO, enriching it with user details.
 func (uc *chatUseCase) toMessageResponse(ctx context.Context, msg *entities.Message) (*entities.MessageResponse, error) {
-	var user *entities.User
-	var err error
-
-	// Handle system messages, which don't have a user in the repository.
-	if msg.UserID == "system" {
-		user = &entities.User{ID: "system", Username: "System"}
-	} else {
-		user, err = uc.userRepo.FindByID(ctx, msg.UserID)
+	dtos, err := uc.toMessageResponses(ctx, []*entities.Message{msg})
+	if err != nil {
+		return nil, err
+	}
+	return dtos[0], nil
+}
+
+// toMessageResponses converts message entities to message DTOs, looking up all authors in a single batch.
+// Messages from users that no longer exist are attributed to a placeholder user.
+func (uc *chatUseCase) toMessageResponses(ctx context.Context, messages []*entities.Message) ([]*entities.MessageResponse, error) {
+	var userIDs []string
+	seen := make(map[string]bool)
+	for _, msg := range messages {
+		// System messages don't have a user in the repository.
+		if msg.UserID != "system" && !seen[msg.UserID] {
+			seen[msg.UserID] = true
+			userIDs = append(userIDs, msg.UserID)
+		}
+	}
+
+	users := map[string]*entities.User{
+		"system": {ID: "system", Username: "System"},
+	}
+	if len(userIDs) > 0 {
+		found, err := uc.userRepo.FindByIDs(ctx, userIDs)
 		if err != nil {
 			return nil, err
 		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	dtos := make([]*entities.MessageResponse, 0, len(messages))
+	for _, msg := range messages {
+		user, ok := users[msg.UserID]
+		if !ok {
+			user = &entities.User{ID: msg.UserID, Username: deletedUsername}
+		}
+
+		dtos = append(dtos, &entities.MessageResponse{
+			ID:              msg.ID,
+			Event:           "message",
+			RoomID:          msg.RoomID,
+			UserID:          msg.UserID,
+			Username:        user.Username,
+			UserRole:        user.Role,
+			Content:         msg.Content,
+			Timestamp:       msg.Timestamp,
+			IsRead:          msg.IsRead,
+			Type:            msg.Type,
+			Metadata:        msg.Metadata,
+			EditedAt:        msg.EditedAt,
+			ClientMessageID: msg.ClientMessageID,
+		})
+	}
+	return dtos, nil
+}
+
+// signAttachment returns a copy of the message whose file URLs are signed for its room and the user,
+// or for every member of its room if userID is empty. Messages without files are returned as they are.
+func (uc *chatUseCase) signAttachment(dto *entities.MessageResponse, userID string) *entities.MessageResponse {
+	if uc.attachments == nil || dto.Metadata == nil {
+		return dto
+	}
+	signed := *dto
+	signed.Metadata = uc.attachments.SignMetadata(dto.Metadata, dto.RoomID, userID)
+	return &signed
+}
+
+// signAttachments signs the file URLs of messages sent to a user.
+// The DTOs may be shared with the history cache, so they are copied rather than changed.
+func (uc *chatUseCase) signAttachments(dtos []*entities.MessageResponse, userID string) []*entities.MessageResponse {
+	if uc.attachments == nil {
+		return dtos
+	}
+	signed := make([]*entities.MessageResponse, len(dtos))
+	for i, dto := range dtos {
+		signed[i] = uc.signAttachment(dto, userID)
 	}
+	return signed
+}
 
-	return &entities.MessageResponse{
-		ID:        msg.ID,
-		Event:     "message",
-		RoomID:    msg.RoomID,
-		UserID:    msg.UserID,
-		Username:  user.Username,
-		UserRole:  user.Role,
-		Content:   msg.Content,
-		Timestamp: msg.Timestamp,
-		IsRead:    msg.IsRead,
-		Type:      msg.Type,
-		Metadata:  msg.Metadata,
-	}, nil
+// broadcastMessage sends a message to its room. Messages with files are signed for each recipient,
+// so that their URLs stop working for a user who is banned from the room.
+func (uc *chatUseCase) broadcastMessage(roomID string, dto *entities.MessageResponse) error {
+	payload, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	if uc.attachments != nil && dto.Metadata != nil {
+		uc.broadcaster.BroadcastToRoomRecipients(roomID, payload)
+		return nil
+	}
+	uc.broadcaster.BroadcastToRoom(roomID, payload)
+	return nil
+}
+
+// SignForRecipient signs the files of a message broadcast by broadcastMessage for a user.
+func (uc *chatUseCase) SignForRecipient(userID, roomID string, message []byte) []byte {
+	var dto entities.MessageResponse
+	if err := json.Unmarshal(message, &dto); err != nil || dto.Metadata == nil {
+		return message
+	}
+	payload, err := json.Marshal(uc.signAttachment(&dto, userID))
+	if err != nil {
+		log.Printf("Failed to marshal message DTO: %v", err)
+		return message
+	}
+	return payload
 }
 
 // UserConnected handles new client connections.
 func (uc *chatUseCase) UserConnected(ctx context.Context, userID, roomID string) ([]*entities.MessageResponse, error) {
 	log.Printf("User %s connected to room %s", userID, roomID)
 
-	messages, err := uc.messageRepo.FindByRoom(ctx, roomID)
+	history, err := uc.recentHistory(ctx, roomID)
 	if err != nil {
 		log.Printf("Failed to retrieve chat history for room %s: %v", roomID, err)
-		return nil, nil
-	}
-
-	var history []*entities.MessageResponse
-	for _, msg := range messages {
-		dto, err := uc.toMessageResponse(ctx, msg)
-		if err != nil {
-			log.Printf("Failed to convert message to DTO: %v", err)
-			continue
-		}
-		history = append(history, dto)
+		history = nil
 	}
+	history = uc.signAttachments(history, userID)
 
 	// Notify others that a user has joined.
 	user, err := uc.userRepo.FindByID(ctx, userID)
@@ -107,6 +368,18 @@ func (uc *chatUseCase) UserConnected(ctx context.Context, userID, roomID string)
 		return history, err
 	}
 
+	if uc.unread != nil {
+		if err := uc.unread.RoomJoined(ctx, userID, roomID); err != nil {
+			log.Printf("Failed to reset unread count of user %s in room %s: %v", userID, roomID, err)
+		}
+	}
+
+	if uc.presence != nil {
+		if err := uc.presence.Connected(ctx, userID, roomID); err != nil {
+			log.Printf("Failed to record presence of user %s in room %s: %v", userID, roomID, err)
+		}
+	}
+
 	joinMsg := &entities.MessageResponse{
 		ID:        primitive.NewObjectID(),
 		Event:     "user-joined",
@@ -122,8 +395,67 @@ func (uc *chatUseCase) UserConnected(ctx context.Context, userID, roomID string)
 	return history, nil
 }
 
+// recentHistory returns the history sent to connecting users, oldest first.
+// With a history cache, only the most recent messages are returned, and a cache miss refills the cache.
+func (uc *chatUseCase) recentHistory(ctx context.Context, roomID string) ([]*entities.MessageResponse, error) {
+	if uc.historyCache == nil {
+		messages, err := uc.messageRepo.FindByRoom(ctx, roomID)
+		if err != nil {
+			return nil, err
+		}
+		return uc.toMessageResponses(ctx, messages)
+	}
+
+	history, hit, err := uc.historyCache.Get(ctx, roomID)
+	if err != nil {
+		historyCacheMetrics.Add("errors", 1)
+		log.Printf("Failed to read history cache of room %s: %v", roomID, err)
+	}
+	if hit {
+		historyCacheMetrics.Add("hits", 1)
+		return history, nil
+	}
+	historyCacheMetrics.Add("misses", 1)
+
+	messages, err := uc.messageRepo.FindPage(ctx, roomID, repositories.MessageQuery{Limit: int64(uc.historySize)})
+	if err != nil {
+		return nil, err
+	}
+	// The repository returns the newest messages first.
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	history, err = uc.toMessageResponses(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.historyCache.Set(ctx, roomID, history); err != nil {
+		historyCacheMetrics.Add("errors", 1)
+		log.Printf("Failed to fill history cache of room %s: %v", roomID, err)
+	}
+	return history, nil
+}
+
+// invalidateHistory drops the cached history of a room after it changed.
+func (uc *chatUseCase) invalidateHistory(ctx context.Context, roomID string) {
+	if uc.historyCache == nil {
+		return
+	}
+	if err := uc.historyCache.Invalidate(ctx, roomID); err != nil {
+		historyCacheMetrics.Add("errors", 1)
+		log.Printf("Failed to invalidate history cache of room %s: %v", roomID, err)
+	}
+}
+
 // UserDisconnected handles client disconnections.
 func (uc *chatUseCase) UserDisconnected(ctx context.Context, userID, roomID string) error {
+	if uc.presence != nil {
+		if err := uc.presence.Disconnected(ctx, userID, roomID); err != nil {
+			log.Printf("Failed to record disconnect of user %s from room %s: %v", userID, roomID, err)
+		}
+	}
+
 	user, err := uc.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		log.Printf("Could not find user %s: %v", userID, err)
@@ -145,21 +477,413 @@ func (uc *chatUseCase) UserDisconnected(ctx context.Context, userID, roomID stri
 	return nil
 }
 
+const (
+	// messageTypeModeration marks an incoming message as a moderation command rather than chat content.
+	messageTypeModeration = "moderation"
+	// messageTypeRead marks the room as read for the sender.
+	messageTypeRead = "read"
+	// messageTypeInboxAck acknowledges events delivered from the sender's offline inbox.
+	messageTypeInboxAck = "inbox-ack"
+
+	// maxClientMessageIDLength is the longest client message ID accepted for deduplication.
+	maxClientMessageIDLength = 64
+
+	// dedupeReservationLease bounds how long a client message ID stays blocked while its first
+	// attempt is processed, so that a node crashing mid-attempt does not block retries for the whole window.
+	dedupeReservationLease = 30 * time.Second
+)
+
 // IncomingMessage represents the structure of a message received from a client.
 type IncomingMessage struct {
+	ClientMessageID string `json:"clientMessageId,omitempty"` // Optional; retries with the same ID are stored once
+
 	Type     string                 `json:"type"`
 	Content  string                 `json:"content,omitempty"`
 	Metadata *entities.FileMetadata `json:"metadata,omitempty"`
+	Command  *ModerationCommand     `json:"command,omitempty"`  // For moderation messages
+	InboxIDs []string               `json:"inboxIds,omitempty"` // For inbox acknowledgements
 }
 
 // ProcessMessage handles incoming chat messages.
+// Messages with a client message ID are answered with an ack or nack event; other rejected
+// messages are answered with an error event.
 func (uc *chatUseCase) ProcessMessage(ctx context.Context, userID, roomID string, rawMessage []byte) error {
+	if uc.presence != nil {
+		if err := uc.presence.Activity(ctx, userID); err != nil {
+			log.Printf("Failed to record activity of user %s: %v", userID, err)
+		}
+	}
+
 	var incomingMsg IncomingMessage
 	if err := json.Unmarshal(rawMessage, &incomingMsg); err != nil {
 		log.Printf("Failed to unmarshal incoming message: %v", err)
+		err = errs.NewBadRequestError("message must be a valid JSON object")
+		uc.sendError(userID, err)
 		return err
 	}
 
+	err := uc.processMessage(ctx, userID, roomID, &incomingMsg)
+	if err != nil {
+		if incomingMsg.ClientMessageID != "" {
+			uc.sendNack(userID, incomingMsg.ClientMessageID, err)
+		} else {
+			uc.sendError(userID, err)
+		}
+	}
+	return err
+}
+
+func (uc *chatUseCase) processMessage(ctx context.Context, userID, roomID string, incomingMsg *IncomingMessage) error {
+	if incomingMsg.Type == messageTypeModeration {
+		if uc.moderation == nil || incomingMsg.Command == nil {
+			return errs.NewBadRequestError("invalid moderation command")
+		}
+		return uc.moderation.Execute(ctx, userID, roomID, *incomingMsg.Command)
+	}
+
+	if incomingMsg.Type == messageTypeRead {
+		if uc.unread == nil {
+			return errs.NewBadRequestError("unread tracking is not enabled")
+		}
+		return uc.unread.MarkRead(ctx, userID, roomID)
+	}
+
+	if incomingMsg.Type == messageTypeInboxAck {
+		if uc.inbox == nil {
+			return errs.NewBadRequestError("offline inbox is not enabled")
+		}
+		return uc.inbox.Ack(ctx, userID, incomingMsg.InboxIDs)
+	}
+
+	dto, err := uc.createMessage(ctx, userID, roomID, incomingMsg)
+	if err != nil {
+		return err
+	}
+	if incomingMsg.ClientMessageID != "" {
+		uc.sendAck(userID, dto)
+	}
+	return nil
+}
+
+// PostMessage stores and broadcasts a message sent over REST.
+func (uc *chatUseCase) PostMessage(ctx context.Context, userID, roomID string, incomingMsg IncomingMessage) (*entities.MessageResponse, error) {
+	switch incomingMsg.Type {
+	case messageTypeModeration:
+		return nil, errs.NewBadRequestError("moderation commands must be sent to the moderation endpoints")
+	case messageTypeRead:
+		return nil, errs.NewBadRequestError("rooms must be marked as read through the read endpoint")
+	case messageTypeInboxAck:
+		return nil, errs.NewBadRequestError("inbox events can only be acknowledged over WebSocket")
+	}
+
+	dto, err := uc.createMessage(ctx, userID, roomID, &incomingMsg)
+	if err != nil {
+		return nil, err
+	}
+	return uc.signAttachment(dto, userID), nil
+}
+
+// GetMessages returns a page of a room's history, oldest first.
+// Pages are fetched from the most recent messages backwards using the returned cursor.
+func (uc *chatUseCase) GetMessages(ctx context.Context, userID, roomID string, query HistoryQuery) (*MessagePage, error) {
+	if uc.moderation != nil {
+		if err := uc.moderation.CheckCanJoin(ctx, userID, roomID); err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultPageSize
+	}
+	if query.Limit > maxPageSize {
+		query.Limit = maxPageSize
+	}
+
+	repoQuery := repositories.MessageQuery{
+		Since: query.Since,
+		Until: query.Until,
+		Types: query.Types,
+		Limit: int64(query.Limit),
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, errs.NewBadRequestError("invalid cursor")
+		}
+		repoQuery.Before = cursor
+	}
+
+	messages, err := uc.messageRepo.FindPage(ctx, roomID, repoQuery)
+	if err != nil {
+		log.Printf("Failed to retrieve messages for room %s: %v", roomID, err)
+		return nil, err
+	}
+
+	page := &MessagePage{}
+	if len(messages) == query.Limit {
+		oldest := messages[len(messages)-1]
+		page.NextCursor = encodeCursor(&repositories.MessageCursor{Timestamp: oldest.Timestamp, ID: oldest.ID})
+	}
+
+	// The repository returns the newest messages first; the page is returned in chronological order.
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	page.Messages, err = uc.toMessageResponses(ctx, messages)
+	if err != nil {
+		log.Printf("Failed to convert messages of room %s: %v", roomID, err)
+		return nil, err
+	}
+	page.Messages = uc.signAttachments(page.Messages, userID)
+
+	return page, nil
+}
+
+// SearchMessages runs a full-text search over the given rooms, skipping rooms the user is banned from.
+func (uc *chatUseCase) SearchMessages(ctx context.Context, userID string, query SearchQuery) (*SearchPage, error) {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, errs.NewBadRequestError("search query must not be empty")
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultPageSize
+	}
+	if query.Limit > maxPageSize {
+		query.Limit = maxPageSize
+	}
+
+	user, err := uc.roleRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, errs.NewForbiddenError("unknown user")
+	}
+
+	var roomIDs []string
+	for _, roomID := range query.RoomIDs {
+		if uc.moderation != nil && uc.moderation.CheckCanJoin(ctx, userID, roomID) != nil {
+			continue
+		}
+		roomIDs = append(roomIDs, roomID)
+	}
+	if len(roomIDs) == 0 {
+		if len(query.RoomIDs) > 0 || user.Role != entities.AdminRole {
+			return nil, errs.NewBadRequestError("at least one readable room is required")
+		}
+	}
+
+	messages, next, err := uc.messageRepo.Search(ctx, query.Query, roomIDs, int64(query.Limit), query.Cursor)
+	if errors.Is(err, repositories.ErrInvalidCursor) {
+		return nil, errs.NewBadRequestError("invalid cursor")
+	}
+	if err != nil {
+		log.Printf("Failed to search messages: %v", err)
+		return nil, err
+	}
+
+	dtos, err := uc.toMessageResponses(ctx, messages)
+	if err != nil {
+		log.Printf("Failed to convert search results: %v", err)
+		return nil, err
+	}
+	dtos = uc.signAttachments(dtos, userID)
+
+	terms := searchTerms(query.Query)
+	page := &SearchPage{Results: make([]*SearchResult, 0, len(dtos)), NextCursor: next}
+	for i, dto := range dtos {
+		page.Results = append(page.Results, &SearchResult{
+			Message: dto,
+			Snippet: highlightSnippet(messages[i].Content, terms),
+		})
+	}
+
+	return page, nil
+}
+
+// EditMessage checks and filters the new content like a new message, then stores and broadcasts the edited message.
+func (uc *chatUseCase) EditMessage(ctx context.Context, userID, roomID, messageID, content string) (*entities.MessageResponse, error) {
+	msg, err := uc.findRoomMessage(ctx, roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.UserID != userID {
+		return nil, errs.NewForbiddenError("only the author can edit a message")
+	}
+	if msg.Type != messageTypeText {
+		return nil, errs.NewBadRequestError("only text messages can be edited")
+	}
+
+	incomingMsg := &IncomingMessage{Type: msg.Type, Content: content}
+	flagReasons, err := uc.checkMessage(ctx, userID, roomID, incomingMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now()
+	msg.Content = incomingMsg.Content
+	msg.Flags = flagReasons
+	msg.EditedAt = &editedAt
+	if err := uc.messageRepo.Update(ctx, msg); err != nil {
+		if errors.Is(err, repositories.ErrMessageNotFound) {
+			return nil, errs.NewNotFoundError(err.Error())
+		}
+		log.Printf("Failed to update message %s: %v", messageID, err)
+		return nil, err
+	}
+	uc.invalidateHistory(ctx, roomID)
+
+	if len(msg.Flags) > 0 && uc.moderation != nil {
+		if err := uc.moderation.FlagMessage(ctx, msg); err != nil {
+			log.Printf("Failed to flag message %s for review: %v", msg.ID.Hex(), err)
+		}
+	}
+
+	dto, err := uc.toMessageResponse(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	dto.Event = "message-edited"
+	if err := uc.broadcastMessage(roomID, dto); err != nil {
+		log.Printf("Failed to marshal message DTO: %v", err)
+	}
+
+	return dto, nil
+}
+
+// DeleteMessage deletes a message and notifies the room.
+func (uc *chatUseCase) DeleteMessage(ctx context.Context, userID, roomID, messageID string) error {
+	msg, err := uc.findRoomMessage(ctx, roomID, messageID)
+	if err != nil {
+		return err
+	}
+	if msg.UserID != userID {
+		user, err := uc.roleRepo.FindByID(ctx, userID)
+		if err != nil || user.Role != entities.AdminRole {
+			return errs.NewForbiddenError("only the author or an admin can delete a message")
+		}
+	}
+
+	if err := uc.messageRepo.Delete(ctx, msg.ID); err != nil {
+		if errors.Is(err, repositories.ErrMessageNotFound) {
+			return errs.NewNotFoundError(err.Error())
+		}
+		log.Printf("Failed to delete message %s: %v", messageID, err)
+		return err
+	}
+	uc.invalidateHistory(ctx, roomID)
+
+	if msg.Metadata != nil && uc.files != nil {
+		if err := uc.files.ReleaseFile(ctx, msg.Metadata.URL); err != nil {
+			log.Printf("Failed to release file of message %s: %v", msg.ID.Hex(), err)
+		}
+	}
+
+	event := &entities.MessageDeletedResponse{
+		Event:     "message-deleted",
+		RoomID:    roomID,
+		MessageID: msg.ID,
+		Timestamp: time.Now(),
+	}
+	payload, _ := json.Marshal(event)
+	uc.broadcaster.BroadcastToRoom(roomID, payload)
+
+	return nil
+}
+
+// findRoomMessage looks up a message by its hex ID, returning not found if it belongs to another room.
+func (uc *chatUseCase) findRoomMessage(ctx context.Context, roomID, messageID string) (*entities.Message, error) {
+	id, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, errs.NewBadRequestError("invalid message ID")
+	}
+
+	msg, err := uc.messageRepo.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrMessageNotFound) || (err == nil && msg.RoomID != roomID) {
+		return nil, errs.NewNotFoundError(repositories.ErrMessageNotFound.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// encodeCursor serializes a cursor into an opaque string for clients.
+func encodeCursor(cursor *repositories.MessageCursor) string {
+	raw := strconv.FormatInt(cursor.Timestamp.UnixNano(), 10) + ":" + cursor.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor.
+func decodeCursor(value string) (*repositories.MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	nanos, hexID, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, errors.New("malformed cursor")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repositories.MessageCursor{Timestamp: time.Unix(0, unixNano).UTC(), ID: id}, nil
+}
+
+// createMessage stores and broadcasts a chat message, deduplicating it by its client message ID.
+// Retries of a message that was already stored return the stored message without broadcasting it again.
+func (uc *chatUseCase) createMessage(ctx context.Context, userID, roomID string, incomingMsg *IncomingMessage) (*entities.MessageResponse, error) {
+	if incomingMsg.ClientMessageID == "" || uc.dedupeRepo == nil {
+		return uc.storeMessage(ctx, userID, roomID, incomingMsg)
+	}
+
+	clientMessageID := incomingMsg.ClientMessageID
+	if len(clientMessageID) > maxClientMessageIDLength || strings.ContainsAny(clientMessageID, " \t\r\n") {
+		return nil, errs.NewBadRequestError(fmt.Sprintf("clientMessageId must be at most %d bytes without whitespace", maxClientMessageIDLength))
+	}
+
+	reserved, stored, err := uc.dedupeRepo.Reserve(ctx, userID, clientMessageID, dedupeReservationLease)
+	if err != nil {
+		log.Printf("Failed to reserve client message ID %s of user %s: %v", clientMessageID, userID, err)
+		return nil, err
+	}
+	if !reserved {
+		if stored == nil {
+			return nil, errs.NewConflictError("a message with this clientMessageId is still being processed")
+		}
+		if stored.RoomID != roomID {
+			return nil, errs.NewConflictError("clientMessageId was already used in another room")
+		}
+		log.Printf("Ignoring retried message %s from user %s", clientMessageID, userID)
+		return stored, nil
+	}
+
+	dto, err := uc.storeMessage(ctx, userID, roomID, incomingMsg)
+	if err != nil {
+		// Forget the ID, so that the client can retry after fixing the message.
+		if err := uc.dedupeRepo.Release(ctx, userID, clientMessageID); err != nil {
+			log.Printf("Failed to release client message ID %s of user %s: %v", clientMessageID, userID, err)
+		}
+		return nil, err
+	}
+
+	if err := uc.dedupeRepo.Complete(ctx, userID, clientMessageID, dto, uc.dedupeWindow); err != nil {
+		log.Printf("Failed to record client message ID %s of user %s: %v", clientMessageID, userID, err)
+	}
+	return dto, nil
+}
+
+// storeMessage checks, filters and stores a chat message, then broadcasts it to the room.
+func (uc *chatUseCase) storeMessage(ctx context.Context, userID, roomID string, incomingMsg *IncomingMessage) (*entities.MessageResponse, error) {
+	flagReasons, err := uc.checkMessage(ctx, userID, roomID, incomingMsg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a new message and store it.
 	msg := &entities.Message{
 		ID:        primitive.NewObjectID(),
@@ -170,25 +894,185 @@ func (uc *chatUseCase) ProcessMessage(ctx context.Context, userID, roomID string
 		Metadata:  incomingMsg.Metadata, // For file messages
 		Timestamp: time.Now(),
 		IsRead:    false,
+		Flags:     flagReasons,
+
+		ClientMessageID: incomingMsg.ClientMessageID,
 	}
 
-	if err := uc.messageRepo.Create(ctx, msg); err != nil {
-		log.Printf("Failed to save message to database: %v", err)
-		return err
+	// The file is referenced first, so that a message is never stored for a file that was deleted.
+	// Its metadata describes the stored file, rather than what the client sent.
+	retained := msg.Metadata != nil && uc.files != nil
+	if retained {
+		metadata, err := uc.files.RetainFile(ctx, userID, msg.Metadata.URL)
+		if err != nil {
+			log.Printf("Failed to reference file of message %s: %v", msg.ID.Hex(), err)
+			return nil, err
+		}
+		msg.Metadata = metadata
+	}
+
+	if err := uc.saveMessage(ctx, msg); err != nil {
+		if retained {
+			if err := uc.files.ReleaseFile(ctx, msg.Metadata.URL); err != nil {
+				log.Printf("Failed to release file of message %s: %v", msg.ID.Hex(), err)
+			}
+		}
+		return nil, err
+	}
+
+	if len(msg.Flags) > 0 && uc.moderation != nil {
+		if err := uc.moderation.FlagMessage(ctx, msg); err != nil {
+			log.Printf("Failed to flag message %s for review: %v", msg.ID.Hex(), err)
+		}
+	}
+
+	if uc.unread != nil {
+		if err := uc.unread.MessageStored(ctx, msg); err != nil {
+			log.Printf("Failed to update unread counts for room %s: %v", roomID, err)
+		}
 	}
 
 	dto, err := uc.toMessageResponse(ctx, msg)
 	if err != nil {
 		log.Printf("Failed to create message DTO: %v", err)
-		return err
+		return nil, err
 	}
 
-	payload, err := json.Marshal(dto)
-	if err != nil {
+	if err := uc.broadcastMessage(roomID, dto); err != nil {
 		log.Printf("Failed to marshal message DTO: %v", err)
-		return err
+		return nil, err
 	}
 
-	uc.broadcaster.BroadcastToRoom(roomID, payload)
+	if uc.historyCache != nil {
+		if err := uc.historyCache.Append(ctx, roomID, dto); err != nil {
+			historyCacheMetrics.Add("errors", 1)
+			log.Printf("Failed to append message to history cache of room %s: %v", roomID, err)
+			uc.invalidateHistory(ctx, roomID)
+		}
+	}
+	return dto, nil
+}
+
+// saveMessage runs the name of the message's file through the content filters, as it is shown beside
+// the attachment, then saves the message.
+func (uc *chatUseCase) saveMessage(ctx context.Context, msg *entities.Message) error {
+	if msg.Metadata != nil && uc.contentFilter != nil {
+		fileName, fileFlags, err := uc.filterText(ctx, msg.UserID, msg.RoomID, msg.Metadata.FileName, " in the file name")
+		if err != nil {
+			return err
+		}
+		msg.Metadata.FileName = fileName
+		msg.Flags = append(msg.Flags, fileFlags...)
+	}
+
+	if err := uc.messageRepo.Create(ctx, msg); err != nil {
+		log.Printf("Failed to save message to database: %v", err)
+		return err
+	}
 	return nil
 }
+
+// checkMessage enforces moderation, validates the message and runs its content through the content filters.
+// It returns the reasons the message was flagged for review.
+func (uc *chatUseCase) checkMessage(ctx context.Context, userID, roomID string, incomingMsg *IncomingMessage) ([]string, error) {
+	if uc.moderation != nil {
+		if err := uc.moderation.CheckCanPost(ctx, userID, roomID); err != nil {
+			log.Printf("Rejected message from user %s in room %s: %v", userID, roomID, err)
+			return nil, err
+		}
+	}
+
+	if uc.validator != nil {
+		if err := uc.validator.Validate(incomingMsg); err != nil {
+			log.Printf("Rejected invalid message from user %s in room %s: %v", userID, roomID, err)
+			return nil, err
+		}
+	}
+
+	if uc.contentFilter == nil {
+		return nil, nil
+	}
+	content, flagReasons, err := uc.filterText(ctx, userID, roomID, incomingMsg.Content, "")
+	if err != nil {
+		return nil, err
+	}
+	incomingMsg.Content = content
+	return flagReasons, nil
+}
+
+// filterText runs text through the content filters and returns it with its rewrites applied, along with
+// the reasons it was flagged for review. The suffix tells flag reasons for different parts of a message apart.
+func (uc *chatUseCase) filterText(ctx context.Context, userID, roomID, text, suffix string) (string, []string, error) {
+	outcome, err := uc.contentFilter.Run(ctx, text)
+	if err != nil {
+		log.Printf("Failed to filter message content: %v", err)
+		return "", nil, err
+	}
+	if outcome.Rejected {
+		log.Printf("Content filter rejected message from user %s in room %s: %s%s", userID, roomID, outcome.Reason, suffix)
+		return "", nil, errs.NewBadRequestError(outcome.Reason + suffix)
+	}
+
+	var flagReasons []string
+	for _, flag := range outcome.Flags {
+		flagReasons = append(flagReasons, flag.Filter+": "+flag.Reason+suffix)
+	}
+	return outcome.Content, flagReasons, nil
+}
+
+// sendAck confirms to the sender that their message was stored.
+func (uc *chatUseCase) sendAck(userID string, dto *entities.MessageResponse) {
+	ack := &entities.MessageAckResponse{
+		Event:           "ack",
+		ClientMessageID: dto.ClientMessageID,
+		MessageID:       dto.ID,
+		RoomID:          dto.RoomID,
+		Timestamp:       dto.Timestamp,
+	}
+	payload, _ := json.Marshal(ack)
+	if err := uc.broadcaster.SendMessage(userID, payload); err != nil {
+		log.Printf("Failed to send ack to user %s: %v", userID, err)
+	}
+}
+
+// sendNack reports a rejected message with a client message ID back to the sender.
+func (uc *chatUseCase) sendNack(userID, clientMessageID string, err error) {
+	customErr := toCustomError(err)
+	nack := &entities.MessageNackResponse{
+		Event:           "nack",
+		ClientMessageID: clientMessageID,
+		Code:            customErr.Code,
+		Message:         customErr.Message,
+		Timestamp:       time.Now(),
+	}
+	payload, _ := json.Marshal(nack)
+	if err := uc.broadcaster.SendMessage(userID, payload); err != nil {
+		log.Printf("Failed to send nack to user %s: %v", userID, err)
+	}
+}
+
+// sendError reports a failed request back to the user as an error event.
+// Errors that are not a CustomError are reported without their details.
+func (uc *chatUseCase) sendError(userID string, err error) {
+	customErr := toCustomError(err)
+
+	errMsg := &entities.ErrorResponse{
+		Event:     "error",
+		Code:      customErr.Code,
+		Message:   customErr.Message,
+		Timestamp: time.Now(),
+	}
+	payload, _ := json.Marshal(errMsg)
+	if err := uc.broadcaster.SendMessage(userID, payload); err != nil {
+		log.Printf("Failed to send error event to user %s: %v", userID, err)
+	}
+}
+
+// toCustomError returns the CustomError wrapped in err, hiding the details of any other error.
+func toCustomError(err error) errs.CustomError {
+	var customErr errs.CustomError
+	if !errors.As(err, &customErr) {
+		customErr = errs.NewUnexpectedError().(errs.CustomError)
+	}
+	return customErr
+}
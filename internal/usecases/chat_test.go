@@ -0,0 +1,262 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/contentfilter"
+	"api-gateway/pkg/filestorage"
+	"api-gateway/pkg/urlsigner"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countingUserRepository counts the batch lookups of a UserRepository.
+type countingUserRepository struct {
+	repositories.UserRepository
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (r *countingUserRepository) FindByIDs(ctx context.Context, ids []string) ([]*entities.User, error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, slices.Clone(ids))
+	r.mu.Unlock()
+	return r.UserRepository.FindByIDs(ctx, ids)
+}
+
+func TestToMessageResponses(t *testing.T) {
+	userRepo := &countingUserRepository{UserRepository: repositories.NewMockUserRepository()}
+	uc := NewChatUseCase(userRepo, repositories.NewMemoryMessageRepository(), newFakeBroadcaster()).(*chatUseCase)
+
+	messages := []*entities.Message{
+		{ID: primitive.NewObjectID(), UserID: "user-1", Content: "first"},
+		{ID: primitive.NewObjectID(), UserID: "user-deleted", Content: "second"},
+		{ID: primitive.NewObjectID(), UserID: "system", Content: "third"},
+		{ID: primitive.NewObjectID(), UserID: "user-1", Content: "fourth"},
+		{ID: primitive.NewObjectID(), UserID: "user-2", Content: "fifth"},
+	}
+	dtos, err := uc.toMessageResponses(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Authors are looked up once each, in a single batch, and never for system messages.
+	if len(userRepo.batches) != 1 || !slices.Equal(userRepo.batches[0], []string{"user-1", "user-deleted", "user-2"}) {
+		t.Fatalf("got lookups %v, want one batch of the distinct authors", userRepo.batches)
+	}
+
+	for i, want := range []struct {
+		userID   string
+		username string
+		role     entities.UserRole
+	}{
+		{"user-1", "Alice", entities.AdminRole},
+		{"user-deleted", deletedUsername, ""},
+		{"system", "System", ""},
+		{"user-1", "Alice", entities.AdminRole},
+		{"user-2", "Bob", entities.RoleUser},
+	} {
+		dto := dtos[i]
+		if dto.ID != messages[i].ID || dto.Content != messages[i].Content || dto.Event != "message" {
+			t.Errorf("message %d: got %+v, want it in its original order", i, dto)
+		}
+		if dto.UserID != want.userID || dto.Username != want.username || dto.UserRole != want.role {
+			t.Errorf("message %d: got author %s (%s, %q), want %s (%s, %q)",
+				i, dto.UserID, dto.Username, dto.UserRole, want.userID, want.username, want.role)
+		}
+	}
+
+	// System messages alone need no lookup.
+	userRepo.batches = nil
+	if _, err := uc.toMessageResponses(context.Background(), messages[2:3]); err != nil {
+		t.Fatal(err)
+	}
+	if len(userRepo.batches) != 0 {
+		t.Fatalf("got lookups %v for a system message, want none", userRepo.batches)
+	}
+}
+
+func TestProcessMessageRunsContentFilters(t *testing.T) {
+	mask, err := contentfilter.NewWordlistFilter([]string{"darn"}, contentfilter.ModeMask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flag, err := contentfilter.NewWordlistFilter([]string{"casino"}, contentfilter.ModeFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reject, err := contentfilter.NewLinkDomainFilter([]string{"evil.example"}, contentfilter.ModeReject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := contentfilter.NewPipeline(mask, flag, reject)
+
+	for _, tt := range []struct {
+		name        string
+		content     string
+		wantCode    int // Zero if the message is stored
+		wantContent string
+		wantFlagged bool
+	}{
+		{"clean", "hello", 0, "hello", false},
+		{"masked", "darn it", 0, "**** it", false},
+		{"flagged", "best casino in town", 0, "best casino in town", true},
+		{"masked and flagged", "darn casino", 0, "**** casino", true},
+		{"rejected", "see https://www.evil.example", http.StatusBadRequest, "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			moderationRepo := newFakeModerationRepository()
+			broadcaster := newFakeBroadcaster()
+			moderation, _ := newTestModerationUseCase(t, moderationRepo, broadcaster)
+			messageRepo := repositories.NewMemoryMessageRepository()
+			uc := NewChatUseCase(repositories.NewMockUserRepository(), messageRepo, broadcaster,
+				WithModeration(moderation), WithContentFilter(pipeline))
+
+			err := uc.ProcessMessage(ctx, "user-2", "room-1", []byte(`{"type":"text","content":"`+tt.content+`"}`))
+			messages, findErr := messageRepo.FindByRoom(ctx, "room-1")
+			if findErr != nil {
+				t.Fatal(findErr)
+			}
+			if tt.wantCode != 0 {
+				wantErrorCode(t, err, tt.wantCode)
+				if len(messages) != 0 {
+					t.Fatal("rejected message was stored")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != 1 || messages[0].Content != tt.wantContent {
+				t.Fatalf("got %d stored messages, want one with content %q", len(messages), tt.wantContent)
+			}
+			// Flagged messages are delivered, and recorded for moderator review.
+			if flagged := len(messages[0].Flags) > 0; flagged != tt.wantFlagged {
+				t.Fatalf("got flags %v, want flagged %v", messages[0].Flags, tt.wantFlagged)
+			}
+			if reviewed := len(moderationRepo.auditLog) == 1; reviewed != tt.wantFlagged {
+				t.Fatalf("got %d audit entries, want flagged %v", len(moderationRepo.auditLog), tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestPostAndGetMessages(t *testing.T) {
+	ctx := context.Background()
+	moderationRepo := newFakeModerationRepository()
+	broadcaster := newFakeBroadcaster()
+	moderation, _ := newTestModerationUseCase(t, moderationRepo, broadcaster)
+	uc := NewChatUseCase(repositories.NewMockUserRepository(), repositories.NewMemoryMessageRepository(), broadcaster,
+		WithModeration(moderation))
+
+	for _, messageType := range []string{messageTypeModeration, messageTypeRead, messageTypeInboxAck} {
+		_, err := uc.PostMessage(ctx, "user-2", "room-1", IncomingMessage{Type: messageType})
+		wantErrorCode(t, err, http.StatusBadRequest)
+	}
+
+	for i := 1; i <= 5; i++ {
+		dto, err := uc.PostMessage(ctx, "user-2", "room-1", IncomingMessage{Type: "text", Content: fmt.Sprintf("message %d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dto.Username != "Bob" || dto.RoomID != "room-1" {
+			t.Fatalf("got %+v, want a message by Bob in room-1", dto)
+		}
+		time.Sleep(time.Millisecond) // Keeps the timestamps apart, which order the history
+	}
+	if events := broadcaster.roomEvents("room-1"); len(events) != 5 {
+		t.Fatalf("got %d broadcasts, want 5", len(events))
+	}
+
+	// Pages go back in time, and each page is in chronological order.
+	var pages [][]string
+	query := HistoryQuery{Limit: 2}
+	for {
+		page, err := uc.GetMessages(ctx, "user-3", "room-1", query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var contents []string
+		for _, msg := range page.Messages {
+			contents = append(contents, msg.Content)
+		}
+		pages = append(pages, contents)
+		if page.NextCursor == "" || len(pages) > 5 {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	want := [][]string{{"message 4", "message 5"}, {"message 2", "message 3"}, {"message 1"}}
+	if !slices.EqualFunc(pages, want, slices.Equal) {
+		t.Fatalf("got pages %q, want %q", pages, want)
+	}
+
+	_, err := uc.GetMessages(ctx, "user-3", "room-1", HistoryQuery{Cursor: "not-a-cursor"})
+	wantErrorCode(t, err, http.StatusBadRequest)
+
+	ban := &entities.Sanction{RoomID: "room-1", UserID: "user-3", Type: entities.SanctionBan}
+	if err := moderationRepo.UpsertSanction(ctx, ban); err != nil {
+		t.Fatal(err)
+	}
+	_, err = uc.GetMessages(ctx, "user-3", "room-1", HistoryQuery{})
+	wantErrorCode(t, err, http.StatusForbidden)
+	_, err = uc.PostMessage(ctx, "user-3", "room-1", IncomingMessage{Type: "text", Content: "hello"})
+	wantErrorCode(t, err, http.StatusForbidden)
+}
+
+func TestSignForRecipient(t *testing.T) {
+	storage, err := filestorage.NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := urlsigner.NewSigner("file-url-secret")
+	uc := NewChatUseCase(repositories.NewMockUserRepository(), repositories.NewMemoryMessageRepository(), newFakeBroadcaster(),
+		WithAttachmentSigner(NewAttachmentSigner(signer, storage, "https://chat.example.com/files", time.Hour)))
+
+	broadcast, err := json.Marshal(&entities.MessageResponse{
+		ID:       primitive.NewObjectID(),
+		Event:    "message",
+		RoomID:   "room-1",
+		UserID:   "user-1",
+		Type:     "file",
+		Metadata: &entities.FileMetadata{URL: "/files/sha256/ab/ab12.png", FileName: "photo.png"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each recipient gets a URL of their own, so that a ban revokes only theirs.
+	for _, userID := range []string{"user-2", "user-3"} {
+		var dto entities.MessageResponse
+		if err := json.Unmarshal(uc.SignForRecipient(userID, "room-1", broadcast), &dto); err != nil {
+			t.Fatal(err)
+		}
+		signed, err := url.Parse(dto.Metadata.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := signer.Verify(signed.EscapedPath(), signed.Query())
+		if err != nil {
+			t.Fatalf("%s: got %v, want a valid signature", userID, err)
+		}
+		if claims.UserID != userID || claims.RoomID != "room-1" || dto.Metadata.FileName != "photo.png" {
+			t.Fatalf("%s: got claims %+v for %+v, want the file signed for the recipient", userID, claims, dto.Metadata)
+		}
+	}
+
+	// Events without files are passed on as they are.
+	event := []byte(`{"event":"user-joined","roomId":"room-1"}`)
+	if got := uc.SignForRecipient("user-2", "room-1", event); string(got) != string(event) {
+		t.Fatalf("got %s, want the event unchanged", got)
+	}
+}
@@ -1,46 +1,501 @@
 package usecases
 
 import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
 	"api-gateway/pkg/filestorage"
+	"api-gateway/pkg/scanner"
+	"api-gateway/pkg/thumbnail"
+	"api-gateway/pkg/urlsigner"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// contentKeyPrefix is the storage key prefix of content-addressed files.
+	contentKeyPrefix = "sha256/"
+	// quarantineKeyPrefix is the storage key prefix of uploads that were not scanned yet.
+	quarantineKeyPrefix = "quarantine/"
 )
 
+// isPublishedKey reports whether a key belongs to a published file, rather than to a file
+// in quarantine or a chunk of a resumable upload.
+func isPublishedKey(key string) bool {
+	return !strings.HasPrefix(key, quarantineKeyPrefix) && !strings.HasPrefix(key, resumableChunkPrefix)
+}
+
 // FileUploadResponse is the DTO returned after a successful file upload.
 type FileUploadResponse struct {
 	URL      string `json:"url"`
 	FileName string `json:"fileName"`
 	FileSize int64  `json:"fileSize"`
+	MIMEType string `json:"mimeType"` // Detected from the content, not the file name
+	SHA256   string `json:"sha256"`   // Hex-encoded; lets clients claim the file again instead of re-uploading it
+
+	Thumbnails []entities.Thumbnail `json:"thumbnails,omitempty"` // Smallest first; only for images
 }
 
 // FileUploadUseCase defines the business logic for uploading files.
 type FileUploadUseCase interface {
-	// UploadFile handles the business logic of storing a file and returning its metadata.
-	UploadFile(ctx context.Context, reader io.Reader, fileName string, fileSize int64) (*FileUploadResponse, error)
+	// UploadFile handles the business logic of storing a file uploaded by a user and returning its metadata.
+	// The upload is charged to the user, and to the room it is meant for if roomID is not empty.
+	UploadFile(ctx context.Context, reader io.Reader, userID, roomID, fileName string, fileSize int64) (*FileUploadResponse, error)
+
+	// StoreFile is like UploadFile, for uploads that were already charged to the user's quota.
+	// Uploads that fail are not refunded.
+	StoreFile(ctx context.Context, reader io.Reader, userID, fileName string, fileSize int64) (*FileUploadResponse, error)
+
+	// ClaimFile returns the metadata of a file that the user already uploaded with the given SHA-256 hash,
+	// so that clients can skip uploading it again. Unknown hashes, and files that the user did not upload,
+	// return a not found error.
+	ClaimFile(ctx context.Context, userID, sha256, fileName string) (*FileUploadResponse, error)
+
+	// RetainFile records that a message of a user references the file at a URL, and returns the metadata
+	// of the stored file for the message. Files that no longer exist, and content-addressed files that the
+	// user did not upload or claim, return a not found error.
+	RetainFile(ctx context.Context, userID, fileURL string) (*entities.FileMetadata, error)
+
+	// ReleaseFile records that a message referencing the file at a URL was deleted.
+	// The file and its thumbnails are deleted with the last reference.
+	ReleaseFile(ctx context.Context, fileURL string) error
 }
 
 // fileUploadUseCase implements the FileUploadUseCase.
 type fileUploadUseCase struct {
 	fileStorage filestorage.FileStorage
+	validator   UploadValidator
+	thumbnails  thumbnail.Generator
+	references  repositories.FileReferenceRepository
+	scanner     scanner.Scanner
+	quotas      StorageQuotaUseCase
+	claimTTL    time.Duration
 }
 
 // NewFileUploadUseCase creates a new FileUploadUseCase.
-func NewFileUploadUseCase(fileStorage filestorage.FileStorage) FileUploadUseCase {
+// Thumbnails of uploaded images are stored beside them if a thumbnail generator is given.
+// Files are stored once per content, under their SHA-256 hash, if a reference repository is given.
+// Files are only published once the scanner found them clean, if a scanner is given.
+// Uploads count towards the quota of their user, if a quota use case is given.
+// Content-addressed files handed out by uploads and claims are kept for claimTTL even without
+// references, so that they can be posted before a message deleting the last reference removes them.
+func NewFileUploadUseCase(
+	fileStorage filestorage.FileStorage,
+	validator UploadValidator,
+	thumbnails thumbnail.Generator,
+	references repositories.FileReferenceRepository,
+	scanner scanner.Scanner,
+	quotas StorageQuotaUseCase,
+	claimTTL time.Duration,
+) FileUploadUseCase {
 	return &fileUploadUseCase{
 		fileStorage: fileStorage,
+		validator:   validator,
+		thumbnails:  thumbnails,
+		references:  references,
+		scanner:     scanner,
+		quotas:      quotas,
+		claimTTL:    claimTTL,
 	}
 }
 
-// UploadFile saves the file using the configured file storage and returns the URL.
-func (uc *fileUploadUseCase) UploadFile(ctx context.Context, reader io.Reader, fileName string, fileSize int64) (*FileUploadResponse, error) {
-	url, err := uc.fileStorage.Upload(ctx, reader, fileName)
+// UploadFile validates the file, charges it to the user's quota and saves it using the configured file storage.
+// Uploads that fail are refunded.
+func (uc *fileUploadUseCase) UploadFile(ctx context.Context, reader io.Reader, userID, roomID, fileName string, fileSize int64) (*FileUploadResponse, error) {
+	fileName, err := normalizeFileName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	reader, mimeType, err := uc.validator.Validate(reader, fileName, fileSize)
 	if err != nil {
 		return nil, err
 	}
 
-	return &FileUploadResponse{
-		URL:      url,
+	if uc.quotas != nil {
+		if err := uc.quotas.Charge(ctx, userID, roomID, fileSize); err != nil {
+			return nil, err
+		}
+	}
+	response, err := uc.store(ctx, reader, userID, fileName, mimeType)
+	if err != nil && uc.quotas != nil {
+		uc.quotas.Refund(ctx, userID, roomID, fileSize)
+	}
+	return response, err
+}
+
+// StoreFile validates the file and saves it using the configured file storage.
+func (uc *fileUploadUseCase) StoreFile(ctx context.Context, reader io.Reader, userID, fileName string, fileSize int64) (*FileUploadResponse, error) {
+	fileName, err := normalizeFileName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	reader, mimeType, err := uc.validator.Validate(reader, fileName, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	return uc.store(ctx, reader, userID, fileName, mimeType)
+}
+
+// store hashes the file while it is stored in quarantine, then scans it and publishes it under its
+// content address, unless the same content is already stored.
+func (uc *fileUploadUseCase) store(ctx context.Context, reader io.Reader, userID, fileName, mimeType string) (*FileUploadResponse, error) {
+	// Keep a copy of images to generate their thumbnails from. The validator already limited their size.
+	var image *bytes.Buffer
+	if uc.thumbnails != nil && strings.HasPrefix(mimeType, "image/") {
+		image = &bytes.Buffer{}
+		reader = io.TeeReader(reader, image)
+	}
+	hash := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(reader, hash)}
+
+	quarantineKey := quarantineKeyPrefix + uuid.New().String() + strings.ToLower(path.Ext(fileName))
+	if _, err := uc.fileStorage.Put(ctx, quarantineKey, counter); err != nil {
+		uc.deleteFile(ctx, quarantineKey)
+		// The size limit is enforced while storing, so its error surfaces here.
+		var customErr errs.CustomError
+		if errors.As(err, &customErr) {
+			return nil, customErr
+		}
+		return nil, err
+	}
+
+	metadata := &entities.FileMetadata{
 		FileName: fileName,
-		FileSize: fileSize,
+		FileSize: counter.n,
+		MIMEType: mimeType,
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+	}
+	stored, err := uc.publish(ctx, quarantineKey, userID, metadata, image)
+	if err != nil {
+		uc.deleteFile(ctx, quarantineKey)
+		return nil, err
+	}
+	stored.FileName = fileName
+	return fileUploadResponse(stored), nil
+}
+
+// publish moves a scanned, clean file out of quarantine and returns the metadata of the stored file.
+// Content that is already stored was scanned before, so the file is returned without scanning it again.
+func (uc *fileUploadUseCase) publish(ctx context.Context, quarantineKey, userID string, metadata *entities.FileMetadata, image *bytes.Buffer) (*entities.FileMetadata, error) {
+	if uc.references != nil {
+		existing, err := uc.references.Claim(ctx, metadata.SHA256, userID, metadata.FileName, time.Now().Add(uc.claimTTL))
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			uc.deleteFile(ctx, quarantineKey)
+			return existing, nil
+		}
+	}
+
+	if err := uc.scan(ctx, quarantineKey, metadata); err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimPrefix(quarantineKey, quarantineKeyPrefix)
+	if uc.references != nil {
+		key = contentKey(metadata.SHA256, quarantineKey)
+	}
+	var err error
+	metadata.URL, err = uc.fileStorage.Move(ctx, quarantineKey, key)
+	if err != nil {
+		return nil, err
+	}
+	if image != nil {
+		metadata.Thumbnails = uc.storeThumbnails(ctx, metadata.URL, image)
+	}
+
+	if uc.references == nil {
+		return metadata, nil
+	}
+	// Concurrent uploads of the same content were moved to the same key, so either registration is valid.
+	return uc.references.Register(ctx, metadata.SHA256, userID, metadata, time.Now().Add(uc.claimTTL))
+}
+
+// scan runs a quarantined file through the malware scanner. Infected files are logged and rejected.
+func (uc *fileUploadUseCase) scan(ctx context.Context, key string, metadata *entities.FileMetadata) error {
+	if uc.scanner == nil {
+		return nil
+	}
+
+	reader, _, err := uc.fileStorage.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	result, err := uc.scanner.Scan(ctx, reader)
+	if errors.Is(err, scanner.ErrTooLarge) {
+		log.Printf("Rejected upload %s (%d bytes) too large to scan: %v", metadata.FileName, metadata.FileSize, err)
+		return errs.NewPayloadTooLargeError("file is too large to be scanned for malware")
+	}
+	if err != nil {
+		log.Printf("Failed to scan upload %s: %v", metadata.FileName, err)
+		return errs.NewServiceUnavailableError("file could not be scanned for malware, please try again later")
+	}
+	if !result.Clean {
+		log.Printf("Rejected infected upload %s (sha256 %s, %d bytes): %s", metadata.FileName, metadata.SHA256, metadata.FileSize, result.Threat)
+		return errs.NewBadRequestError("file was rejected by the malware scanner")
+	}
+	return nil
+}
+
+// fileUploadResponse converts the metadata of a stored file into an upload response.
+func fileUploadResponse(metadata *entities.FileMetadata) *FileUploadResponse {
+	return &FileUploadResponse{
+		URL:        metadata.URL,
+		FileName:   metadata.FileName,
+		FileSize:   metadata.FileSize,
+		MIMEType:   metadata.MIMEType,
+		SHA256:     metadata.SHA256,
+		Thumbnails: metadata.Thumbnails,
+	}
+}
+
+// contentKey returns the content address of a file, keeping its extension for the content type.
+// Files are spread over directories by the first byte of their hash.
+func contentKey(hash, key string) string {
+	return contentKeyPrefix + hash[:2] + "/" + hash + strings.ToLower(path.Ext(key))
+}
+
+// contentHash returns the hash of a content-addressed file or thumbnail key,
+// e.g. "sha256/ab/ab12…ef.png" or "sha256/ab/ab12…ef_256.jpg".
+func contentHash(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, contentKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	_, name, found := strings.Cut(rest, "/")
+	if !found || len(name) < sha256.Size*2 {
+		return "", false
+	}
+	hash := name[:sha256.Size*2]
+	return hash, isSHA256(hash)
+}
+
+// isSHA256 reports whether s is a hex-encoded SHA-256 hash.
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// ClaimFile looks up the file by its hash and pins it. Answering only to uploaders keeps other users
+// from fetching private files, or learning that they exist, by their hash.
+func (uc *fileUploadUseCase) ClaimFile(ctx context.Context, userID, hash, fileName string) (*FileUploadResponse, error) {
+	hash = strings.ToLower(hash)
+	if !isSHA256(hash) {
+		return nil, errs.NewBadRequestError("sha256 must be a hex-encoded SHA-256 hash")
+	}
+	fileName, err := normalizeFileName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if uc.references == nil {
+		return nil, errs.NewNotFoundError("file not found")
+	}
+
+	stored, err := uc.references.ClaimUploaded(ctx, hash, userID, fileName, time.Now().Add(uc.claimTTL))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, errs.NewNotFoundError("file not found")
+	}
+	stored.FileName = fileName
+	return fileUploadResponse(stored), nil
+}
+
+// RetainFile adds a reference to a content-addressed file. Files that were deleted since they were
+// uploaded are rejected, as the message would link to nothing, and so are files of other users,
+// which could otherwise be shared, or probed for, by their hash. Other files are described from
+// the file storage, as they have no record.
+func (uc *fileUploadUseCase) RetainFile(ctx context.Context, userID, fileURL string) (*entities.FileMetadata, error) {
+	key, ok := uc.fileStorage.KeyFromURL(fileURL)
+	if !ok || !isPublishedKey(key) {
+		return nil, errs.NewBadRequestError("file URL must reference an uploaded file")
+	}
+	hash, ok := contentHash(key)
+	if !ok || uc.references == nil {
+		return uc.describeFile(ctx, fileURL, key)
+	}
+
+	metadata, err := uc.references.Retain(ctx, hash, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, errs.NewNotFoundError("file no longer exists, please upload it again")
+	}
+	return metadata, err
+}
+
+// describeFile returns the metadata of a file that is not content-addressed. Its name is its key's,
+// and its MIME type is trusted from its extension, as uploads are only stored if it matches their content.
+func (uc *fileUploadUseCase) describeFile(ctx context.Context, fileURL, key string) (*entities.FileMetadata, error) {
+	info, err := uc.fileStorage.Stat(ctx, key)
+	if errors.Is(err, filestorage.ErrNotFound) {
+		return nil, errs.NewNotFoundError("file no longer exists, please upload it again")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := mediaType(mime.TypeByExtension(path.Ext(key)))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return &entities.FileMetadata{
+		URL:      fileURL,
+		FileName: path.Base(key),
+		FileSize: info.Size,
+		MIMEType: mimeType,
 	}, nil
 }
+
+// ReleaseFile removes a reference to a content-addressed file, deleting the file and its thumbnails with the last one.
+func (uc *fileUploadUseCase) ReleaseFile(ctx context.Context, fileURL string) error {
+	hash, ok := uc.urlContentHash(fileURL)
+	if !ok {
+		return nil
+	}
+
+	last, err := uc.references.Release(ctx, hash)
+	if err != nil || !last {
+		return err
+	}
+
+	key, _ := uc.fileStorage.KeyFromURL(fileURL)
+	// Thumbnails share the file's key without its extension as a prefix.
+	files, err := uc.fileStorage.List(ctx, strings.TrimSuffix(key, path.Ext(key)))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		uc.deleteFile(ctx, file.Key)
+	}
+	return nil
+}
+
+// urlContentHash returns the hash of a content-addressed file's URL.
+func (uc *fileUploadUseCase) urlContentHash(fileURL string) (string, bool) {
+	if uc.references == nil {
+		return "", false
+	}
+	key, ok := uc.fileStorage.KeyFromURL(fileURL)
+	if !ok {
+		return "", false
+	}
+	return contentHash(key)
+}
+
+// deleteFile deletes a stored file. Failures are logged, as the file is no longer referenced.
+func (uc *fileUploadUseCase) deleteFile(ctx context.Context, key string) {
+	if err := uc.fileStorage.Delete(ctx, key); err != nil && !errors.Is(err, filestorage.ErrNotFound) {
+		log.Printf("Failed to delete file %s: %v", key, err)
+	}
+}
+
+// storeThumbnails generates the thumbnails of an uploaded image and stores them beside it,
+// e.g. "photo_256.jpg" for "photo.jpg". Failures are logged, as the upload itself succeeded.
+func (uc *fileUploadUseCase) storeThumbnails(ctx context.Context, url string, image io.Reader) []entities.Thumbnail {
+	key, ok := uc.fileStorage.KeyFromURL(url)
+	if !ok {
+		return nil
+	}
+
+	generated, err := uc.thumbnails.Generate(image)
+	if err != nil {
+		log.Printf("Failed to generate thumbnails of %s: %v", key, err)
+		return nil
+	}
+
+	base := strings.TrimSuffix(key, path.Ext(key))
+	thumbnails := make([]entities.Thumbnail, 0, len(generated))
+	for _, t := range generated {
+		thumbnailURL, err := uc.fileStorage.Put(ctx, fmt.Sprintf("%s_%d%s", base, t.Size, t.Extension), bytes.NewReader(t.Data))
+		if err != nil {
+			log.Printf("Failed to store thumbnail of %s: %v", key, err)
+			return nil
+		}
+		thumbnails = append(thumbnails, entities.Thumbnail{
+			URL:    thumbnailURL,
+			Width:  t.Width,
+			Height: t.Height,
+		})
+	}
+	return thumbnails
+}
+
+// FileDownloadUseCase defines the business logic for downloading stored files.
+type FileDownloadUseCase interface {
+	// OpenFile returns a reader streaming the file stored under key, along with its details.
+	// requestURL is the path and query the file was requested with, which carry its signature
+	// if downloads require signed URLs. The caller must close the reader.
+	OpenFile(ctx context.Context, key string, requestURL *url.URL) (io.ReadCloser, *filestorage.FileInfo, error)
+}
+
+// fileDownloadUseCase implements the FileDownloadUseCase.
+type fileDownloadUseCase struct {
+	fileStorage filestorage.FileStorage
+	signer      *urlsigner.Signer
+	moderation  ModerationUseCase
+}
+
+// NewFileDownloadUseCase creates a new FileDownloadUseCase.
+// If a signer is given, files are only served for URLs it signed for a user, and the URLs
+// stop working once the user is banned from the room they were issued for.
+func NewFileDownloadUseCase(fileStorage filestorage.FileStorage, signer *urlsigner.Signer, moderation ModerationUseCase) FileDownloadUseCase {
+	return &fileDownloadUseCase{
+		fileStorage: fileStorage,
+		signer:      signer,
+		moderation:  moderation,
+	}
+}
+
+// OpenFile checks the URL's signature and opens the file in the configured file storage.
+func (uc *fileDownloadUseCase) OpenFile(ctx context.Context, key string, requestURL *url.URL) (io.ReadCloser, *filestorage.FileInfo, error) {
+	if uc.signer != nil {
+		claims, err := uc.signer.Verify(requestURL.EscapedPath(), requestURL.Query())
+		if errors.Is(err, urlsigner.ErrExpired) {
+			return nil, nil, errs.NewForbiddenError(err.Error())
+		}
+		if err != nil {
+			return nil, nil, errs.NewForbiddenError(urlsigner.ErrInvalidSignature.Error())
+		}
+		// Bans are checked per user, so URLs signed for a whole room are not accepted.
+		if claims.UserID == "" {
+			return nil, nil, errs.NewForbiddenError(urlsigner.ErrInvalidSignature.Error())
+		}
+		if uc.moderation != nil {
+			if err := uc.moderation.CheckCanJoin(ctx, claims.UserID, claims.RoomID); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if !isPublishedKey(key) {
+		return nil, nil, errs.NewNotFoundError(filestorage.ErrNotFound.Error())
+	}
+
+	reader, info, err := uc.fileStorage.Open(ctx, key)
+	if errors.Is(err, filestorage.ErrNotFound) || errors.Is(err, filestorage.ErrInvalidKey) {
+		return nil, nil, errs.NewNotFoundError(filestorage.ErrNotFound.Error())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, info, nil
+}
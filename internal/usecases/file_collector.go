@@ -0,0 +1,186 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/filestorage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// CollectedFile describes a file deleted by the file collector, or that it would delete in a dry run.
+type CollectedFile struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// FileCollectionReport describes a run of the file collector.
+type FileCollectionReport struct {
+	DryRun   bool            `json:"dryRun"`
+	Scanned  int             `json:"scanned"`  // Number of stored files
+	Orphaned []CollectedFile `json:"orphaned"` // Files that no message references, deleted unless DryRun is set
+	Bytes    int64           `json:"bytes"`    // Total size of the orphaned files
+}
+
+// FileCollector defines the interface for deleting uploaded files that no message references,
+// such as files that were never posted or whose messages were deleted.
+type FileCollector interface {
+	// Collect deletes the files older than the grace period that no message references.
+	Collect(ctx context.Context) (*FileCollectionReport, error)
+
+	// Run collects orphaned files every interval until the context is cancelled.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// fileCollector implements the FileCollector interface.
+type fileCollector struct {
+	fileStorage filestorage.FileStorage
+	messageRepo repositories.MessageRepository
+	references  repositories.FileReferenceRepository
+	gracePeriod time.Duration
+	dryRun      bool
+}
+
+// NewFileCollector creates a new FileCollector. Files younger than the grace period are kept, so that
+// uploads can be posted in a message, as are content-addressed files that the reference repository
+// still pins because they were uploaded again or claimed. A dry run only reports the files it would delete, without
+// checking whether content-addressed files gained a reference since the messages were read.
+// The records of content-addressed files are forgotten with them if a reference repository is given.
+func NewFileCollector(
+	fileStorage filestorage.FileStorage,
+	messageRepo repositories.MessageRepository,
+	references repositories.FileReferenceRepository,
+	gracePeriod time.Duration,
+	dryRun bool,
+) FileCollector {
+	return &fileCollector{
+		fileStorage: fileStorage,
+		messageRepo: messageRepo,
+		references:  references,
+		gracePeriod: gracePeriod,
+		dryRun:      dryRun,
+	}
+}
+
+// Run implements FileCollector.
+func (uc *fileCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := uc.Collect(ctx); err != nil {
+				log.Printf("Failed to collect orphaned files: %v", err)
+			}
+		}
+	}
+}
+
+// Collect lists the stored files before the messages, so that files uploaded in between are
+// too young to be collected. Chunks of resumable uploads are left to their own sweeper.
+func (uc *fileCollector) Collect(ctx context.Context) (*FileCollectionReport, error) {
+	files, err := uc.fileStorage.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FileCollectionReport{DryRun: uc.dryRun, Scanned: len(files)}
+	cutoff := time.Now().Add(-uc.gracePeriod)
+	var candidates []*filestorage.FileInfo
+	for _, file := range files {
+		if file.ModTime.Before(cutoff) && !strings.HasPrefix(file.Key, resumableChunkPrefix) {
+			candidates = append(candidates, file)
+		}
+	}
+	if len(candidates) == 0 {
+		return report, nil
+	}
+
+	referenced, err := uc.referencedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Content-addressed files are forgotten once per hash, together with their thumbnails.
+	forgotten := make(map[string]bool)
+	for _, file := range candidates {
+		if referenced[file.Key] {
+			continue
+		}
+		if hash, ok := contentHash(file.Key); ok && uc.references != nil && !uc.dryRun {
+			forget, seen := forgotten[hash]
+			if !seen {
+				forget, err = uc.references.Forget(ctx, hash)
+				if err != nil {
+					log.Printf("Failed to forget file %s: %v", hash, err)
+				}
+				forgotten[hash] = forget
+			}
+			// A message referencing the file was posted since the messages were read.
+			if !forget {
+				continue
+			}
+		}
+
+		if !uc.dryRun {
+			if err := uc.fileStorage.Delete(ctx, file.Key); err != nil && !errors.Is(err, filestorage.ErrNotFound) {
+				log.Printf("Failed to delete orphaned file %s: %v", file.Key, err)
+				continue
+			}
+		}
+		report.Orphaned = append(report.Orphaned, CollectedFile{Key: file.Key, Size: file.Size, ModTime: file.ModTime})
+		report.Bytes += file.Size
+	}
+
+	uc.logReport(report)
+	return report, nil
+}
+
+// referencedKeys returns the keys of the files and thumbnails of every message. It fails if messages
+// have files but none of their URLs maps to a key, as happens when the storage's base URL changed,
+// since every stored file would look orphaned.
+func (uc *fileCollector) referencedKeys(ctx context.Context) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	add := func(url string) {
+		if key, ok := uc.fileStorage.KeyFromURL(url); ok {
+			keys[key] = true
+		}
+	}
+
+	files := 0
+	err := uc.messageRepo.ForEachFileMetadata(ctx, func(metadata *entities.FileMetadata) error {
+		files++
+		add(metadata.URL)
+		for _, thumbnail := range metadata.Thumbnails {
+			add(thumbnail.URL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if files > 0 && len(keys) == 0 {
+		return nil, fmt.Errorf("none of the %d files of messages has a URL of the file storage, refusing to collect", files)
+	}
+	return keys, nil
+}
+
+// logReport logs the files that were collected.
+func (uc *fileCollector) logReport(report *FileCollectionReport) {
+	action := "Deleted"
+	if report.DryRun {
+		action = "Would delete"
+	}
+	for _, file := range report.Orphaned {
+		log.Printf("%s orphaned file %s (%d bytes, modified %s)", action, file.Key, file.Size, file.ModTime.Format(time.RFC3339))
+	}
+	log.Printf("%s %d orphaned files of %d, %d bytes", action, len(report.Orphaned), report.Scanned, report.Bytes)
+}
@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/filestorage"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// putOldFile stores a file that was last modified two days ago, beyond the collector's grace period.
+func putOldFile(t *testing.T, storage filestorage.FileStorage, basePath, key string) string {
+	t.Helper()
+	url, err := storage.Put(context.Background(), key, strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(basePath, filepath.FromSlash(key)), old, old); err != nil {
+		t.Fatal(err)
+	}
+	return url
+}
+
+func TestFileCollectorKeepsPinnedFiles(t *testing.T) {
+	ctx := context.Background()
+	basePath := t.TempDir()
+	storage, err := filestorage.NewLocalStorage(basePath, "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	references := repositories.NewRedisFileReferenceRepository(client)
+	collector := NewFileCollector(storage, repositories.NewMemoryMessageRepository(), references, 24*time.Hour, false)
+
+	claimed := strings.Repeat("a", 64)
+	abandoned := strings.Repeat("b", 64)
+	for hash, pinnedUntil := range map[string]time.Time{
+		// An old file that was uploaded again an hour ago and not posted yet.
+		claimed: time.Now().Add(23 * time.Hour),
+		// An old file that nobody claimed since.
+		abandoned: time.Now().Add(-24 * time.Hour),
+	} {
+		url := putOldFile(t, storage, basePath, contentKey(hash, ".png"))
+		if _, err := references.Register(ctx, hash, "user-1", &entities.FileMetadata{URL: url, SHA256: hash}, pinnedUntil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphaned) != 1 || report.Orphaned[0].Key != contentKey(abandoned, ".png") {
+		t.Fatalf("got %+v, want only the abandoned file collected", report.Orphaned)
+	}
+	if _, err := storage.Stat(ctx, contentKey(claimed, ".png")); err != nil {
+		t.Fatalf("claimed file: %v", err)
+	}
+}
+
+func TestFileCollectorRefusesUnmappedURLs(t *testing.T) {
+	ctx := context.Background()
+	basePath := t.TempDir()
+	storage, err := filestorage.NewLocalStorage(basePath, "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := repositories.NewMemoryMessageRepository()
+	collector := NewFileCollector(storage, messages, nil, 24*time.Hour, false)
+
+	putOldFile(t, storage, basePath, "photo.png")
+	// The message was posted while files were served from another base URL.
+	err = messages.Create(ctx, &entities.Message{
+		ID:       primitive.NewObjectID(),
+		RoomID:   "room-1",
+		Type:     "file",
+		Metadata: &entities.FileMetadata{URL: "https://cdn.example.com/photo.png"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err := collector.Collect(ctx); err == nil {
+		t.Fatalf("got %+v, want an error", report)
+	}
+	if _, err := storage.Stat(ctx, "photo.png"); err != nil {
+		t.Fatalf("file of the message: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
+	"api-gateway/pkg/filestorage"
+	"api-gateway/pkg/scanner"
+	"api-gateway/pkg/urlsigner"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// failingScanner is a scanner that cannot scan anything.
+type failingScanner struct {
+	err error
+}
+
+func (s failingScanner) Scan(ctx context.Context, reader io.Reader) (*scanner.Result, error) {
+	return nil, s.err
+}
+
+func newTestFileUploadUseCase(t *testing.T, malwareScanner scanner.Scanner) (FileUploadUseCase, filestorage.FileStorage) {
+	t.Helper()
+	storage, err := filestorage.NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFileUploadUseCase(storage, NewUploadValidator(UploadValidationConfig{}), nil, nil, malwareScanner, nil, time.Hour), storage
+}
+
+func TestUploadFileScanning(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		scanner  scanner.Scanner
+		content  string
+		wantCode int // Zero for a successful upload
+	}{
+		{"clean", scanner.NewFakeScanner(nil), "hello, world", 0},
+		{"infected", scanner.NewFakeScanner(nil), scanner.EICAR, http.StatusBadRequest},
+		{"scanner error", failingScanner{errors.New("connection refused")}, "hello, world", http.StatusServiceUnavailable},
+		{"too large to scan", failingScanner{fmt.Errorf("%w: limit exceeded", scanner.ErrTooLarge)}, "hello, world", http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			uc, storage := newTestFileUploadUseCase(t, tt.scanner)
+
+			response, err := uc.UploadFile(ctx, strings.NewReader(tt.content), "user-1", "", "note.txt", int64(len(tt.content)))
+			files, listErr := storage.List(ctx, "")
+			if listErr != nil {
+				t.Fatal(listErr)
+			}
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("got %v, want a successful upload", err)
+				}
+				key, ok := storage.KeyFromURL(response.URL)
+				if !ok || !isPublishedKey(key) {
+					t.Fatalf("got URL %q, want a published file", response.URL)
+				}
+				if len(files) != 1 || files[0].Key != key {
+					t.Fatalf("stored files: got %d, want only %s", len(files), key)
+				}
+				return
+			}
+
+			var customErr errs.CustomError
+			if !errors.As(err, &customErr) || customErr.Code != tt.wantCode {
+				t.Fatalf("got %v, want an error with status %d", err, tt.wantCode)
+			}
+			// Rejected uploads do not stay in quarantine.
+			if len(files) != 0 {
+				t.Fatalf("got %d stored files, want none", len(files))
+			}
+		})
+	}
+}
+
+func TestRetainFileDescribesStoredFile(t *testing.T) {
+	ctx := context.Background()
+	storage, err := filestorage.NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	references := repositories.NewRedisFileReferenceRepository(client)
+	uc := NewFileUploadUseCase(storage, NewUploadValidator(UploadValidationConfig{}), nil, references, nil, nil, time.Hour)
+
+	const content = "quarterly numbers"
+	uploaded, err := uc.UploadFile(ctx, strings.NewReader(content), "user-1", "", "report.txt", int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := uc.RetainFile(ctx, "user-1", uploaded.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.FileName != "report.txt" || metadata.FileSize != int64(len(content)) || metadata.SHA256 != uploaded.SHA256 {
+		t.Fatalf("got %+v, want the metadata of the upload", metadata)
+	}
+
+	var customErr errs.CustomError
+	if _, err := uc.RetainFile(ctx, "user-2", uploaded.URL); !errors.As(err, &customErr) || customErr.Code != http.StatusNotFound {
+		t.Fatalf("retain by another user: got %v, want a not found error", err)
+	}
+}
+
+func TestOpenFileChecksSignedURL(t *testing.T) {
+	ctx := context.Background()
+	storage, err := filestorage.NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Put(ctx, "photo.png", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+	moderationRepo := newFakeModerationRepository()
+	ban := &entities.Sanction{RoomID: "room-1", UserID: "user-3", Type: entities.SanctionBan}
+	if err := moderationRepo.UpsertSanction(ctx, ban); err != nil {
+		t.Fatal(err)
+	}
+	moderation, _ := newTestModerationUseCase(t, moderationRepo, newFakeBroadcaster())
+	signer := urlsigner.NewSigner("file-url-secret")
+	uc := NewFileDownloadUseCase(storage, signer, moderation)
+
+	for _, tt := range []struct {
+		name     string
+		claims   urlsigner.Claims
+		wantCode int // Zero if the file is served
+	}{
+		{"recipient", urlsigner.Claims{UserID: "user-2", RoomID: "room-1", ExpiresAt: time.Now().Add(time.Hour)}, 0},
+		{"banned recipient", urlsigner.Claims{UserID: "user-3", RoomID: "room-1", ExpiresAt: time.Now().Add(time.Hour)}, http.StatusForbidden},
+		{"whole room", urlsigner.Claims{RoomID: "room-1", ExpiresAt: time.Now().Add(time.Hour)}, http.StatusForbidden},
+		{"expired", urlsigner.Claims{UserID: "user-2", RoomID: "room-1", ExpiresAt: time.Now().Add(-time.Minute)}, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signer.Sign("/files/photo.png", tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requestURL, err := url.ParseRequestURI(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reader, _, err := uc.OpenFile(ctx, "photo.png", requestURL)
+			if tt.wantCode != 0 {
+				wantErrorCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader.Close()
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// snippetContext is the number of characters kept on each side of the first match.
+	snippetContext = 60
+
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+)
+
+// searchTerms extracts the words of a full-text query that should be highlighted.
+// Negated terms ("-word") are skipped, and quotes are removed from phrases.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		if term := strings.Trim(field, `"`); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// highlightSnippet returns an HTML-escaped excerpt of content around the first matched term,
+// with every occurrence of the terms wrapped in <mark> tags.
+func highlightSnippet(content string, terms []string) string {
+	if len(terms) == 0 {
+		return html.EscapeString(truncateRunes(content, 2*snippetContext))
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	pattern := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	// Cut a window of characters around the first match.
+	start, end := 0, len(content)
+	if loc := pattern.FindStringIndex(content); loc != nil {
+		start = backRunes(content, loc[0], snippetContext)
+		end = forwardRunes(content, loc[1], snippetContext)
+	} else {
+		end = forwardRunes(content, 0, 2*snippetContext)
+	}
+	excerpt := content[start:end]
+
+	// Escape the text between matches so that only our own tags are HTML.
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(excerpt, -1) {
+		b.WriteString(html.EscapeString(excerpt[last:loc[0]]))
+		b.WriteString(highlightOpen)
+		b.WriteString(html.EscapeString(excerpt[loc[0]:loc[1]]))
+		b.WriteString(highlightClose)
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(excerpt[last:]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+// backRunes returns the byte offset n runes before offset.
+func backRunes(s string, offset, n int) int {
+	for ; n > 0 && offset > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:offset])
+		offset -= size
+	}
+	return offset
+}
+
+// forwardRunes returns the byte offset n runes after offset.
+func forwardRunes(s string, offset, n int) int {
+	for ; n > 0 && offset < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// truncateRunes returns at most the first n runes of s.
+func truncateRunes(s string, n int) string {
+	return s[:forwardRunes(s, 0, n)]
+}
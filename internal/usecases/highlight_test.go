@@ -0,0 +1,56 @@
+package usecases
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSearchTerms(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"hello world", []string{"hello", "world"}},
+		{`"exact phrase" -excluded`, []string{"exact", "phrase"}},
+		{`  -only "" `, nil},
+	} {
+		if got := searchTerms(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	for _, tt := range []struct {
+		name    string
+		content string
+		terms   []string
+		want    string
+	}{
+		{"single match", "say hello there", []string{"hello"}, "say <mark>hello</mark> there"},
+		{"case insensitive", "Hello HELLO", []string{"hello"}, "<mark>Hello</mark> <mark>HELLO</mark>"},
+		{"several terms", "cats and dogs", []string{"dogs", "cats"}, "<mark>cats</mark> and <mark>dogs</mark>"},
+		{"escaped content", "<b>hi</b> & bye", []string{"hi"}, "&lt;b&gt;<mark>hi</mark>&lt;/b&gt; &amp; bye"},
+		{"escaped term", "a<b", []string{"<"}, "a<mark>&lt;</mark>b"},
+		{"regexp characters", "costs $5.00 (maybe)", []string{"$5.00", "(maybe)"}, "costs <mark>$5.00</mark> <mark>(maybe)</mark>"},
+		{"cut on both sides", long + "needle" + long, []string{"needle"},
+			"…" + strings.Repeat("a", snippetContext) + "<mark>needle</mark>" + strings.Repeat("a", snippetContext) + "…"},
+		{"no match", long + long, []string{"needle"}, strings.Repeat("a", 2*snippetContext) + "…"},
+		{"no terms", long + long, nil, strings.Repeat("a", 2*snippetContext)},
+		{"multibyte context", strings.Repeat("é", 100) + "x", []string{"x"},
+			"…" + strings.Repeat("é", snippetContext) + "<mark>x</mark>"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlightSnippet(tt.content, tt.terms)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("got invalid UTF-8 %q", got)
+			}
+		})
+	}
+}
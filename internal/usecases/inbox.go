@@ -0,0 +1,111 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InboxUseCase defines the business logic for events addressed to users who are offline.
+// It implements UserNotifier, so it can stand in for the connection manager wherever an
+// event must not be lost.
+type InboxUseCase interface {
+	UserNotifier
+
+	// Queue stores an event in the user's inbox regardless of their presence, for events
+	// that must survive a disconnect that is already underway.
+	Queue(ctx context.Context, userID string, message []byte) error
+
+	// Pending returns the user's undelivered events, oldest first, wrapped for delivery.
+	Pending(ctx context.Context, userID string) ([]*entities.InboxDeliveryResponse, error)
+
+	// Ack removes delivered events from the user's inbox.
+	Ack(ctx context.Context, userID string, inboxIDs []string) error
+}
+
+type inboxUseCase struct {
+	inboxRepo    repositories.InboxRepository
+	presenceRepo repositories.PresenceRepository
+	notifier     UserNotifier
+	ttl          time.Duration
+	maxItems     int64
+}
+
+// NewInboxUseCase creates a new InboxUseCase.
+// Queued events are kept for ttl, and only the newest maxItems events are kept per user.
+func NewInboxUseCase(
+	inboxRepo repositories.InboxRepository,
+	presenceRepo repositories.PresenceRepository,
+	notifier UserNotifier,
+	ttl time.Duration,
+	maxItems int64,
+) InboxUseCase {
+	return &inboxUseCase{
+		inboxRepo:    inboxRepo,
+		presenceRepo: presenceRepo,
+		notifier:     notifier,
+		ttl:          ttl,
+		maxItems:     maxItems,
+	}
+}
+
+// SendMessage delivers the event to a connected user, or queues it in their inbox
+// if they have no connection anywhere in the cluster.
+func (uc *inboxUseCase) SendMessage(userID string, message []byte) error {
+	ctx := context.Background()
+
+	presences, err := uc.presenceRepo.FindByUserIDs(ctx, []string{userID})
+	if err != nil {
+		log.Printf("Failed to read presence of user %s, queueing event: %v", userID, err)
+	} else if presences[userID].Status != entities.StatusOffline {
+		if err := uc.notifier.SendMessage(userID, message); err == nil {
+			return nil
+		}
+	}
+	return uc.Queue(ctx, userID, message)
+}
+
+// Queue pushes the event to the user's inbox, trimming it to the newest items.
+func (uc *inboxUseCase) Queue(ctx context.Context, userID string, message []byte) error {
+	now := time.Now()
+	item := &entities.InboxItem{
+		ID:        uuid.New().String(),
+		Payload:   message,
+		CreatedAt: now,
+		ExpiresAt: now.Add(uc.ttl),
+	}
+	if err := uc.inboxRepo.Push(ctx, userID, item, uc.maxItems); err != nil {
+		log.Printf("Failed to queue event for user %s: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// Pending reads the user's inbox. Items stay queued until they are acknowledged,
+// so that events are not lost if the connection drops during delivery.
+func (uc *inboxUseCase) Pending(ctx context.Context, userID string) ([]*entities.InboxDeliveryResponse, error) {
+	items, err := uc.inboxRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*entities.InboxDeliveryResponse, 0, len(items))
+	for _, item := range items {
+		deliveries = append(deliveries, &entities.InboxDeliveryResponse{
+			Event:     "inbox",
+			InboxID:   item.ID,
+			Payload:   item.Payload,
+			CreatedAt: item.CreatedAt,
+		})
+	}
+	return deliveries, nil
+}
+
+// Ack removes the acknowledged items. Unknown IDs are ignored.
+func (uc *inboxUseCase) Ack(ctx context.Context, userID string, inboxIDs []string) error {
+	return uc.inboxRepo.Delete(ctx, userID, inboxIDs)
+}
@@ -0,0 +1,107 @@
+package usecases
+
+import (
+	"api-gateway/internal/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// unreachableNotifier fails to deliver every event, like a connection that just dropped.
+type unreachableNotifier struct{}
+
+func (unreachableNotifier) SendMessage(clientID string, message []byte) error {
+	return errors.New("client not found")
+}
+
+func TestInboxSendMessage(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		online    bool
+		notifier  UserNotifier
+		wantLive  bool
+		wantQueue bool
+	}{
+		{"online", true, newFakeBroadcaster(), true, false},
+		{"offline", false, newFakeBroadcaster(), false, true},
+		{"delivery fails", true, unreachableNotifier{}, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			presenceRepo := repositories.NewRedisPresenceRepository(client, "node-1", time.Minute)
+			uc := NewInboxUseCase(repositories.NewRedisInboxRepository(client), presenceRepo, tt.notifier, time.Hour, 10)
+			if tt.online {
+				if _, err := presenceRepo.Connect(ctx, "user-2", "room-1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := uc.SendMessage("user-2", []byte(`{"event":"moderation-notice"}`)); err != nil {
+				t.Fatal(err)
+			}
+
+			if broadcaster, ok := tt.notifier.(*fakeBroadcaster); ok {
+				if live := len(broadcaster.userEvents("user-2")) == 1; live != tt.wantLive {
+					t.Errorf("delivered live: got %v, want %v", live, tt.wantLive)
+				}
+			}
+			pending, err := uc.Pending(ctx, "user-2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if queued := len(pending) == 1; queued != tt.wantQueue {
+				t.Errorf("queued: got %v, want %v", queued, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestInboxPendingAndAck(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	uc := NewInboxUseCase(
+		repositories.NewRedisInboxRepository(client),
+		repositories.NewRedisPresenceRepository(client, "node-1", time.Minute),
+		newFakeBroadcaster(),
+		time.Hour,
+		2,
+	)
+
+	for _, payload := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		if err := uc.Queue(ctx, "user-2", []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond) // Keeps the creation times apart, which order the inbox
+	}
+
+	// Only the newest items are kept, oldest first.
+	pending, err := uc.Pending(ctx, "user-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || string(pending[0].Payload) != `{"n":2}` || string(pending[1].Payload) != `{"n":3}` {
+		t.Fatalf("got %d pending events, want the newest 2 in order", len(pending))
+	}
+	if pending[0].Event != "inbox" || pending[0].InboxID == "" {
+		t.Fatalf("got %+v, want an inbox delivery with an ID", pending[0])
+	}
+
+	// Acknowledged items are removed, unknown IDs are ignored, and other users are unaffected.
+	if err := uc.Queue(ctx, "user-3", []byte(`{"n":4}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.Ack(ctx, "user-2", []string{pending[0].InboxID, "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err := uc.Pending(ctx, "user-2"); err != nil || len(remaining) != 1 || remaining[0].InboxID != pending[1].InboxID {
+		t.Fatalf("after ack: got %d pending events, %v, want the unacknowledged one", len(remaining), err)
+	}
+	if other, err := uc.Pending(ctx, "user-3"); err != nil || len(other) != 1 {
+		t.Fatalf("other user: got %d pending events, %v, want 1", len(other), err)
+	}
+}
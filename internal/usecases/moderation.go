@@ -0,0 +1,291 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ModerationBroadcaster defines the output port for the moderation use case.
+// Implementations are expected to apply disconnects across the whole cluster.
+type ModerationBroadcaster interface {
+	BroadcastToRoom(roomID string, message []byte)
+	DisconnectClient(clientID, roomID string)
+}
+
+// ModerationCommand is a moderation request sent by an admin over WebSocket or REST.
+type ModerationCommand struct {
+	Action       entities.ModerationAction `json:"action"`
+	TargetUserID string                    `json:"targetUserId"`
+	Duration     string                    `json:"duration,omitempty"` // e.g. "10m"; required for mutes, optional for bans
+	Reason       string                    `json:"reason,omitempty"`
+}
+
+// ModerationUseCase defines the business logic for moderating chat rooms.
+type ModerationUseCase interface {
+	// Execute applies a moderation command issued by an admin in a room.
+	Execute(ctx context.Context, actorID, roomID string, cmd ModerationCommand) error
+
+	// CheckCanJoin returns a forbidden error if the user is banned from the room.
+	CheckCanJoin(ctx context.Context, userID, roomID string) error
+
+	// CheckCanPost returns a forbidden error if the user is muted or banned in the room.
+	CheckCanPost(ctx context.Context, userID, roomID string) error
+
+	// FlagMessage records a message flagged by content filters in the audit log for moderator review.
+	FlagMessage(ctx context.Context, msg *entities.Message) error
+
+	// AuditLog returns the most recent moderation actions in a room. Only admins may read it.
+	AuditLog(ctx context.Context, actorID, roomID string, limit int64) ([]*entities.AuditEntry, error)
+}
+
+type moderationUseCase struct {
+	userRepo       repositories.UserRepository
+	moderationRepo repositories.ModerationRepository
+	broadcaster    ModerationBroadcaster
+	inbox          InboxUseCase
+}
+
+// NewModerationUseCase creates a new ModerationUseCase.
+// The inbox tells sanctioned users what happened, even if they are not connected to the room.
+// The roles of actors are read from userRepo, which should not be cached.
+func NewModerationUseCase(
+	userRepo repositories.UserRepository,
+	moderationRepo repositories.ModerationRepository,
+	broadcaster ModerationBroadcaster,
+	inbox InboxUseCase,
+) ModerationUseCase {
+	return &moderationUseCase{
+		userRepo:       userRepo,
+		moderationRepo: moderationRepo,
+		broadcaster:    broadcaster,
+		inbox:          inbox,
+	}
+}
+
+// requireAdmin ensures the actor exists and holds the admin role.
+func (uc *moderationUseCase) requireAdmin(ctx context.Context, actorID string) error {
+	actor, err := uc.userRepo.FindByID(ctx, actorID)
+	if err != nil {
+		return errs.NewForbiddenError("unknown user")
+	}
+	if actor.Role != entities.AdminRole {
+		return errs.NewForbiddenError("only admins can perform moderation actions")
+	}
+	return nil
+}
+
+// Execute validates and applies a moderation command, then records it in the audit log.
+func (uc *moderationUseCase) Execute(ctx context.Context, actorID, roomID string, cmd ModerationCommand) error {
+	if err := uc.requireAdmin(ctx, actorID); err != nil {
+		return err
+	}
+	if cmd.TargetUserID == "" {
+		return errs.NewBadRequestError("targetUserId is required")
+	}
+	if cmd.TargetUserID == actorID {
+		return errs.NewBadRequestError("admins cannot moderate themselves")
+	}
+
+	target, err := uc.userRepo.FindByID(ctx, cmd.TargetUserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return errs.NewNotFoundError(err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	var duration time.Duration
+	if cmd.Duration != "" {
+		duration, err = time.ParseDuration(cmd.Duration)
+		if err != nil || duration <= 0 {
+			return errs.NewBadRequestError("duration must be a positive duration such as \"10m\"")
+		}
+	}
+
+	var event, content string
+	switch cmd.Action {
+	case entities.ActionKick:
+		uc.notifyTarget(ctx, target.ID, roomID, cmd)
+		uc.broadcaster.DisconnectClient(target.ID, roomID)
+		event, content = "user-kicked", target.Username+" was kicked from the room."
+
+	case entities.ActionMute:
+		if duration == 0 {
+			return errs.NewBadRequestError("duration is required to mute a user")
+		}
+		if err := uc.applySanction(ctx, actorID, roomID, target.ID, entities.SanctionMute, duration, cmd.Reason); err != nil {
+			return err
+		}
+		event, content = "user-muted", fmt.Sprintf("%s was muted for %s.", target.Username, duration)
+
+	case entities.ActionUnmute:
+		if err := uc.moderationRepo.DeleteSanction(ctx, roomID, target.ID, entities.SanctionMute); err != nil {
+			return err
+		}
+		event, content = "user-unmuted", target.Username+" was unmuted."
+
+	case entities.ActionBan:
+		if err := uc.applySanction(ctx, actorID, roomID, target.ID, entities.SanctionBan, duration, cmd.Reason); err != nil {
+			return err
+		}
+		uc.notifyTarget(ctx, target.ID, roomID, cmd)
+		uc.broadcaster.DisconnectClient(target.ID, roomID)
+		event, content = "user-banned", target.Username+" was banned from the room."
+
+	case entities.ActionUnban:
+		if err := uc.moderationRepo.DeleteSanction(ctx, roomID, target.ID, entities.SanctionBan); err != nil {
+			return err
+		}
+		event, content = "user-unbanned", target.Username+" was unbanned."
+
+	default:
+		return errs.NewBadRequestError(fmt.Sprintf("unknown moderation action '%s'", cmd.Action))
+	}
+
+	entry := &entities.AuditEntry{
+		ID:           primitive.NewObjectID(),
+		Action:       cmd.Action,
+		RoomID:       roomID,
+		ActorUserID:  actorID,
+		TargetUserID: target.ID,
+		Reason:       cmd.Reason,
+		Duration:     cmd.Duration,
+		Timestamp:    time.Now(),
+	}
+	if err := uc.moderationRepo.CreateAuditEntry(ctx, entry); err != nil {
+		log.Printf("Failed to record moderation audit entry: %v", err)
+	}
+
+	notice := &entities.MessageResponse{
+		ID:        primitive.NewObjectID(),
+		Event:     event,
+		RoomID:    roomID,
+		UserID:    "system",
+		Username:  "System",
+		Content:   content,
+		Timestamp: time.Now(),
+	}
+	payload, _ := json.Marshal(notice)
+	uc.broadcaster.BroadcastToRoom(roomID, payload)
+
+	if cmd.Action != entities.ActionKick && cmd.Action != entities.ActionBan {
+		uc.notifyTarget(ctx, target.ID, roomID, cmd)
+	}
+	return nil
+}
+
+// notifyTarget sends the moderated user a notice of the action and its reason.
+// Notices of kicks and bans are queued in the inbox before the user is disconnected,
+// since the user still shows as online until the disconnect completes and a live
+// delivery would race with it.
+func (uc *moderationUseCase) notifyTarget(ctx context.Context, targetID, roomID string, cmd ModerationCommand) {
+	content := fmt.Sprintf("Moderation action '%s' was applied to you in room %s.", cmd.Action, roomID)
+	if cmd.Duration != "" {
+		content += " Duration: " + cmd.Duration + "."
+	}
+	if cmd.Reason != "" {
+		content += " Reason: " + cmd.Reason
+	}
+
+	notice := &entities.MessageResponse{
+		ID:        primitive.NewObjectID(),
+		Event:     "moderation-notice",
+		RoomID:    roomID,
+		UserID:    "system",
+		Username:  "System",
+		Content:   content,
+		Timestamp: time.Now(),
+	}
+	payload, _ := json.Marshal(notice)
+	var err error
+	if cmd.Action == entities.ActionKick || cmd.Action == entities.ActionBan {
+		err = uc.inbox.Queue(ctx, targetID, payload)
+	} else {
+		err = uc.inbox.SendMessage(targetID, payload)
+	}
+	if err != nil {
+		log.Printf("Failed to notify user %s of moderation action: %v", targetID, err)
+	}
+}
+
+// applySanction stores a mute or ban. A zero duration means the sanction never expires.
+func (uc *moderationUseCase) applySanction(ctx context.Context, actorID, roomID, targetID string, sanctionType entities.SanctionType, duration time.Duration, reason string) error {
+	now := time.Now()
+	sanction := &entities.Sanction{
+		RoomID:    roomID,
+		UserID:    targetID,
+		Type:      sanctionType,
+		Reason:    reason,
+		CreatedBy: actorID,
+		CreatedAt: now,
+	}
+	if duration > 0 {
+		sanction.ExpiresAt = now.Add(duration)
+	}
+
+	return uc.moderationRepo.UpsertSanction(ctx, sanction)
+}
+
+// CheckCanJoin returns a forbidden error if the user is banned from the room.
+func (uc *moderationUseCase) CheckCanJoin(ctx context.Context, userID, roomID string) error {
+	ban, err := uc.moderationRepo.FindActiveSanction(ctx, roomID, userID, entities.SanctionBan)
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		return errs.NewForbiddenError("you are banned from this room")
+	}
+	return nil
+}
+
+// CheckCanPost returns a forbidden error if the user is muted or banned in the room.
+func (uc *moderationUseCase) CheckCanPost(ctx context.Context, userID, roomID string) error {
+	if err := uc.CheckCanJoin(ctx, userID, roomID); err != nil {
+		return err
+	}
+
+	mute, err := uc.moderationRepo.FindActiveSanction(ctx, roomID, userID, entities.SanctionMute)
+	if err != nil {
+		return err
+	}
+	if mute != nil {
+		return errs.NewForbiddenError("you are muted in this room until " + mute.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// FlagMessage records a flagged message in the audit log.
+func (uc *moderationUseCase) FlagMessage(ctx context.Context, msg *entities.Message) error {
+	entry := &entities.AuditEntry{
+		ID:           primitive.NewObjectID(),
+		Action:       entities.ActionFlag,
+		RoomID:       msg.RoomID,
+		ActorUserID:  "system",
+		TargetUserID: msg.UserID,
+		MessageID:    msg.ID.Hex(),
+		Reason:       strings.Join(msg.Flags, "; "),
+		Timestamp:    time.Now(),
+	}
+	return uc.moderationRepo.CreateAuditEntry(ctx, entry)
+}
+
+// AuditLog returns the most recent moderation actions in a room.
+func (uc *moderationUseCase) AuditLog(ctx context.Context, actorID, roomID string, limit int64) ([]*entities.AuditEntry, error) {
+	if err := uc.requireAdmin(ctx, actorID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return uc.moderationRepo.FindAuditLog(ctx, roomID, limit)
+}
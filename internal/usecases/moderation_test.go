@@ -0,0 +1,282 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeBroadcaster records the events sent to rooms and users.
+type fakeBroadcaster struct {
+	mu          sync.Mutex
+	rooms       map[string][][]byte
+	users       map[string][][]byte
+	disconnects []string
+}
+
+func newFakeBroadcaster() *fakeBroadcaster {
+	return &fakeBroadcaster{rooms: make(map[string][][]byte), users: make(map[string][][]byte)}
+}
+
+func (b *fakeBroadcaster) BroadcastToRoom(roomID string, message []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rooms[roomID] = append(b.rooms[roomID], message)
+}
+
+func (b *fakeBroadcaster) BroadcastToRoomRecipients(roomID string, message []byte) {
+	b.BroadcastToRoom(roomID, message)
+}
+
+func (b *fakeBroadcaster) SendMessage(clientID string, message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.users[clientID] = append(b.users[clientID], message)
+	return nil
+}
+
+func (b *fakeBroadcaster) DisconnectClient(clientID, roomID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.disconnects = append(b.disconnects, clientID+"@"+roomID)
+}
+
+// roomEvents returns the event names broadcast to a room.
+func (b *fakeBroadcaster) roomEvents(roomID string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return eventNames(b.rooms[roomID])
+}
+
+// userEvents returns the event names sent to a user.
+func (b *fakeBroadcaster) userEvents(userID string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return eventNames(b.users[userID])
+}
+
+func eventNames(messages [][]byte) []string {
+	var names []string
+	for _, message := range messages {
+		var event struct {
+			Event string `json:"event"`
+		}
+		_ = json.Unmarshal(message, &event)
+		names = append(names, event.Event)
+	}
+	return names
+}
+
+// fakeModerationRepository is an in-memory ModerationRepository.
+type fakeModerationRepository struct {
+	mu        sync.Mutex
+	sanctions map[string]*entities.Sanction
+	auditLog  []*entities.AuditEntry
+}
+
+func newFakeModerationRepository() *fakeModerationRepository {
+	return &fakeModerationRepository{sanctions: make(map[string]*entities.Sanction)}
+}
+
+func sanctionKey(roomID, userID string, sanctionType entities.SanctionType) string {
+	return roomID + "/" + userID + "/" + string(sanctionType)
+}
+
+func (r *fakeModerationRepository) UpsertSanction(ctx context.Context, sanction *entities.Sanction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sanctions[sanctionKey(sanction.RoomID, sanction.UserID, sanction.Type)] = sanction
+	return nil
+}
+
+func (r *fakeModerationRepository) FindActiveSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) (*entities.Sanction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sanction := r.sanctions[sanctionKey(roomID, userID, sanctionType)]
+	if sanction == nil || !sanction.IsActive(time.Now()) {
+		return nil, nil
+	}
+	return sanction, nil
+}
+
+func (r *fakeModerationRepository) DeleteSanction(ctx context.Context, roomID, userID string, sanctionType entities.SanctionType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sanctions, sanctionKey(roomID, userID, sanctionType))
+	return nil
+}
+
+func (r *fakeModerationRepository) CreateAuditEntry(ctx context.Context, entry *entities.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.auditLog = append(r.auditLog, entry)
+	return nil
+}
+
+func (r *fakeModerationRepository) FindAuditLog(ctx context.Context, roomID string, limit int64) ([]*entities.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []*entities.AuditEntry
+	for i := len(r.auditLog) - 1; i >= 0 && int64(len(entries)) < limit; i-- {
+		if r.auditLog[i].RoomID == roomID {
+			entries = append(entries, r.auditLog[i])
+		}
+	}
+	return entries, nil
+}
+
+func newTestModerationUseCase(t *testing.T, moderationRepo repositories.ModerationRepository, broadcaster *fakeBroadcaster) (ModerationUseCase, InboxUseCase) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	inbox := NewInboxUseCase(
+		repositories.NewRedisInboxRepository(client),
+		repositories.NewRedisPresenceRepository(client, "node-1", time.Minute),
+		broadcaster,
+		time.Hour,
+		10,
+	)
+	return NewModerationUseCase(repositories.NewMockUserRepository(), moderationRepo, broadcaster, inbox), inbox
+}
+
+// wantErrorCode fails the test unless err is a CustomError with the given status code.
+func wantErrorCode(t *testing.T, err error, code int) {
+	t.Helper()
+	var customErr errs.CustomError
+	if !errors.As(err, &customErr) || customErr.Code != code {
+		t.Fatalf("got %v, want an error with status %d", err, code)
+	}
+}
+
+func TestProcessMessageEnforcesSanctions(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		sanction *entities.Sanction
+		wantCode int // Zero if the message is stored
+	}{
+		{"no sanction", nil, 0},
+		{"muted", &entities.Sanction{RoomID: "room-1", UserID: "user-2", Type: entities.SanctionMute, ExpiresAt: time.Now().Add(time.Hour)}, http.StatusForbidden},
+		{"banned", &entities.Sanction{RoomID: "room-1", UserID: "user-2", Type: entities.SanctionBan}, http.StatusForbidden},
+		{"banned until later", &entities.Sanction{RoomID: "room-1", UserID: "user-2", Type: entities.SanctionBan, ExpiresAt: time.Now().Add(time.Hour)}, http.StatusForbidden},
+		{"mute expired", &entities.Sanction{RoomID: "room-1", UserID: "user-2", Type: entities.SanctionMute, ExpiresAt: time.Now().Add(-time.Minute)}, 0},
+		{"muted in another room", &entities.Sanction{RoomID: "room-2", UserID: "user-2", Type: entities.SanctionMute, ExpiresAt: time.Now().Add(time.Hour)}, 0},
+		{"other user banned", &entities.Sanction{RoomID: "room-1", UserID: "user-3", Type: entities.SanctionBan}, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			moderationRepo := newFakeModerationRepository()
+			if tt.sanction != nil {
+				if err := moderationRepo.UpsertSanction(ctx, tt.sanction); err != nil {
+					t.Fatal(err)
+				}
+			}
+			broadcaster := newFakeBroadcaster()
+			moderation, _ := newTestModerationUseCase(t, moderationRepo, broadcaster)
+			messageRepo := repositories.NewMemoryMessageRepository()
+			uc := NewChatUseCase(repositories.NewMockUserRepository(), messageRepo, broadcaster, WithModeration(moderation))
+
+			err := uc.ProcessMessage(ctx, "user-2", "room-1", []byte(`{"type":"text","content":"hello"}`))
+			messages, findErr := messageRepo.FindByRoom(ctx, "room-1")
+			if findErr != nil {
+				t.Fatal(findErr)
+			}
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(messages) != 1 {
+					t.Fatalf("got %d stored messages, want 1", len(messages))
+				}
+				return
+			}
+			wantErrorCode(t, err, tt.wantCode)
+			if len(messages) != 0 || len(broadcaster.roomEvents("room-1")) != 0 {
+				t.Fatal("rejected message was stored or broadcast")
+			}
+			if events := broadcaster.userEvents("user-2"); len(events) != 1 || events[0] != "error" {
+				t.Fatalf("got events %v sent to the sender, want an error", events)
+			}
+		})
+	}
+}
+
+func TestModerationExecute(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		actorID  string
+		cmd      ModerationCommand
+		wantCode int
+	}{
+		{"not an admin", "user-2", ModerationCommand{Action: entities.ActionMute, TargetUserID: "user-3", Duration: "10m"}, http.StatusForbidden},
+		{"unknown actor", "user-9", ModerationCommand{Action: entities.ActionMute, TargetUserID: "user-3", Duration: "10m"}, http.StatusForbidden},
+		{"no target", "user-1", ModerationCommand{Action: entities.ActionKick}, http.StatusBadRequest},
+		{"self", "user-1", ModerationCommand{Action: entities.ActionBan, TargetUserID: "user-1"}, http.StatusBadRequest},
+		{"unknown target", "user-1", ModerationCommand{Action: entities.ActionBan, TargetUserID: "user-9"}, http.StatusNotFound},
+		{"mute without duration", "user-1", ModerationCommand{Action: entities.ActionMute, TargetUserID: "user-2"}, http.StatusBadRequest},
+		{"negative duration", "user-1", ModerationCommand{Action: entities.ActionBan, TargetUserID: "user-2", Duration: "-1h"}, http.StatusBadRequest},
+		{"unknown action", "user-1", ModerationCommand{Action: "shadowban", TargetUserID: "user-2"}, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			moderationRepo := newFakeModerationRepository()
+			moderation, _ := newTestModerationUseCase(t, moderationRepo, newFakeBroadcaster())
+
+			err := moderation.Execute(context.Background(), tt.actorID, "room-1", tt.cmd)
+			wantErrorCode(t, err, tt.wantCode)
+			if len(moderationRepo.sanctions) != 0 || len(moderationRepo.auditLog) != 0 {
+				t.Fatal("rejected command was applied")
+			}
+		})
+	}
+}
+
+func TestModerationBanAndUnban(t *testing.T) {
+	ctx := context.Background()
+	moderationRepo := newFakeModerationRepository()
+	broadcaster := newFakeBroadcaster()
+	moderation, inbox := newTestModerationUseCase(t, moderationRepo, broadcaster)
+
+	ban := ModerationCommand{Action: entities.ActionBan, TargetUserID: "user-2", Reason: "spam"}
+	if err := moderation.Execute(ctx, "user-1", "room-1", ban); err != nil {
+		t.Fatal(err)
+	}
+	wantErrorCode(t, moderation.CheckCanJoin(ctx, "user-2", "room-1"), http.StatusForbidden)
+	wantErrorCode(t, moderation.CheckCanPost(ctx, "user-2", "room-1"), http.StatusForbidden)
+	if err := moderation.CheckCanJoin(ctx, "user-2", "room-2"); err != nil {
+		t.Fatalf("other room: got %v, want no error", err)
+	}
+	if len(broadcaster.disconnects) != 1 || broadcaster.disconnects[0] != "user-2@room-1" {
+		t.Fatalf("got disconnects %v, want user-2 from room-1", broadcaster.disconnects)
+	}
+	if events := broadcaster.roomEvents("room-1"); len(events) != 1 || events[0] != "user-banned" {
+		t.Fatalf("got room events %v, want user-banned", events)
+	}
+	// The notice is queued, since the banned user is being disconnected.
+	if pending, err := inbox.Pending(ctx, "user-2"); err != nil || len(pending) != 1 {
+		t.Fatalf("got %d pending notices, %v, want 1", len(pending), err)
+	}
+	if entries, err := moderation.AuditLog(ctx, "user-1", "room-1", 0); err != nil || len(entries) != 1 ||
+		entries[0].Action != entities.ActionBan || entries[0].Reason != "spam" {
+		t.Fatalf("got audit log %+v, %v, want the ban", entries, err)
+	}
+	if _, err := moderation.AuditLog(ctx, "user-2", "room-1", 0); err == nil {
+		t.Fatal("audit log read by a user: got no error")
+	}
+
+	unban := ModerationCommand{Action: entities.ActionUnban, TargetUserID: "user-2"}
+	if err := moderation.Execute(ctx, "user-1", "room-1", unban); err != nil {
+		t.Fatal(err)
+	}
+	if err := moderation.CheckCanPost(ctx, "user-2", "room-1"); err != nil {
+		t.Fatalf("after unban: got %v, want no error", err)
+	}
+}
@@ -0,0 +1,294 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// PresenceBroadcaster defines the output port for the presence use case.
+type PresenceBroadcaster interface {
+	BroadcastToRoom(roomID string, message []byte)
+}
+
+// RoomPresenceResponse is the DTO returned for the presence of a room's members.
+type RoomPresenceResponse struct {
+	RoomID      string               `json:"roomId"`
+	ActiveUsers int                  `json:"activeUsers"`
+	TotalUsers  int                  `json:"totalUsers"`
+	Users       []*entities.Presence `json:"users"`
+}
+
+// PresenceUseCase defines the business logic for tracking who is online across the cluster.
+type PresenceUseCase interface {
+	// Connected records a new connection of a user to a room.
+	Connected(ctx context.Context, userID, roomID string) error
+
+	// Disconnected records a closed connection of a user to a room.
+	Disconnected(ctx context.Context, userID, roomID string) error
+
+	// Activity records that a user did something, bringing them back from away.
+	Activity(ctx context.Context, userID string) error
+
+	// RoomPresence returns the presence of every member of a room.
+	RoomPresence(ctx context.Context, roomID string) (*RoomPresenceResponse, error)
+
+	// Run marks idle users as away every interval until the context is cancelled.
+	Run(ctx context.Context, interval time.Duration)
+
+	// RunHeartbeat keeps this node's connections alive and closes those of dead nodes
+	// every interval until the context is cancelled.
+	RunHeartbeat(ctx context.Context, interval time.Duration)
+}
+
+type presenceUseCase struct {
+	userRepo     repositories.UserRepository
+	memberRepo   repositories.RoomMemberRepository
+	presenceRepo repositories.PresenceRepository
+	broadcaster  PresenceBroadcaster
+	idleTimeout  time.Duration
+}
+
+// NewPresenceUseCase creates a new PresenceUseCase.
+// Users without activity for idleTimeout are shown as away.
+func NewPresenceUseCase(
+	userRepo repositories.UserRepository,
+	memberRepo repositories.RoomMemberRepository,
+	presenceRepo repositories.PresenceRepository,
+	broadcaster PresenceBroadcaster,
+	idleTimeout time.Duration,
+) PresenceUseCase {
+	return &presenceUseCase{
+		userRepo:     userRepo,
+		memberRepo:   memberRepo,
+		presenceRepo: presenceRepo,
+		broadcaster:  broadcaster,
+		idleTimeout:  idleTimeout,
+	}
+}
+
+// Connected marks the user online and updates the room's user count.
+func (uc *presenceUseCase) Connected(ctx context.Context, userID, roomID string) error {
+	cameOnline, err := uc.presenceRepo.Connect(ctx, userID, roomID)
+	if err != nil {
+		return err
+	}
+
+	if cameOnline {
+		uc.broadcastStatus(ctx, userID, entities.StatusOnline)
+	} else {
+		// The user was already online elsewhere, but is new to this room.
+		uc.broadcastStatusToRoom(ctx, roomID, userID, entities.StatusOnline, time.Time{})
+	}
+	uc.broadcastUserCount(ctx, roomID)
+	return nil
+}
+
+// Disconnected marks the user offline after their last connection and updates the room's user count.
+func (uc *presenceUseCase) Disconnected(ctx context.Context, userID, roomID string) error {
+	wentOffline, err := uc.presenceRepo.Disconnect(ctx, userID, roomID)
+	if err != nil {
+		return err
+	}
+
+	if wentOffline {
+		// The user has no rooms left, so the room they just left is notified directly.
+		uc.broadcastStatusToRoom(ctx, roomID, userID, entities.StatusOffline, time.Now())
+	}
+	uc.broadcastUserCount(ctx, roomID)
+	return nil
+}
+
+// Activity records user activity and broadcasts the return from away.
+func (uc *presenceUseCase) Activity(ctx context.Context, userID string) error {
+	backOnline, err := uc.presenceRepo.Touch(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if backOnline {
+		uc.broadcastStatus(ctx, userID, entities.StatusOnline)
+	}
+	return nil
+}
+
+// RoomPresence returns the presence of every member and connected user of a room.
+func (uc *presenceUseCase) RoomPresence(ctx context.Context, roomID string) (*RoomPresenceResponse, error) {
+	members, err := uc.memberRepo.FindMembers(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	connected, err := uc.presenceRepo.FindRoomUsers(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := members
+	isMember := make(map[string]bool, len(members))
+	for _, member := range members {
+		isMember[member] = true
+	}
+	for _, userID := range connected {
+		if !isMember[userID] {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	presences, err := uc.presenceRepo.FindByUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	users, err := uc.userRepo.FindByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RoomPresenceResponse{
+		RoomID:      roomID,
+		ActiveUsers: len(connected),
+		TotalUsers:  len(userIDs),
+		Users:       make([]*entities.Presence, 0, len(users)),
+	}
+	for _, user := range users {
+		presence := presences[user.ID]
+		presence.Username = user.Username
+		response.Users = append(response.Users, presence)
+	}
+	return response, nil
+}
+
+// Run periodically marks idle users as away. Every node may run it,
+// since only one of them wins each status transition.
+func (uc *presenceUseCase) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.markIdleUsersAway(ctx)
+		}
+	}
+}
+
+// RunHeartbeat sends a heartbeat right away, so that connections are not reaped before the first tick.
+func (uc *presenceUseCase) RunHeartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := uc.presenceRepo.Heartbeat(ctx); err != nil {
+			log.Printf("Failed to send presence heartbeat: %v", err)
+		}
+		uc.reapDeadNodes(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapDeadNodes closes the connections of dead nodes and broadcasts the resulting changes.
+func (uc *presenceUseCase) reapDeadNodes(ctx context.Context) {
+	reaped, err := uc.presenceRepo.ReapDeadNodes(ctx)
+	if err != nil {
+		log.Printf("Failed to reap connections of dead nodes: %v", err)
+	}
+
+	rooms := make(map[string]bool)
+	now := time.Now()
+	for _, connection := range reaped {
+		if connection.WentOffline {
+			uc.broadcastStatusToRoom(ctx, connection.RoomID, connection.UserID, entities.StatusOffline, now)
+		}
+		rooms[connection.RoomID] = true
+	}
+	for roomID := range rooms {
+		uc.broadcastUserCount(ctx, roomID)
+	}
+}
+
+// markIdleUsersAway checks every online user for inactivity.
+func (uc *presenceUseCase) markIdleUsersAway(ctx context.Context) {
+	userIDs, err := uc.presenceRepo.FindOnlineUsers(ctx)
+	if err != nil {
+		log.Printf("Failed to list online users: %v", err)
+		return
+	}
+
+	idleSince := time.Now().Add(-uc.idleTimeout)
+	for _, userID := range userIDs {
+		away, err := uc.presenceRepo.MarkAwayIfIdle(ctx, userID, idleSince)
+		if err != nil {
+			log.Printf("Failed to update presence of user %s: %v", userID, err)
+			continue
+		}
+		if away {
+			uc.broadcastStatus(ctx, userID, entities.StatusAway)
+		}
+	}
+}
+
+// broadcastStatus sends a presence-changed event to every room the user is connected to.
+func (uc *presenceUseCase) broadcastStatus(ctx context.Context, userID string, status entities.PresenceStatus) {
+	roomIDs, err := uc.presenceRepo.FindUserRooms(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list rooms of user %s: %v", userID, err)
+		return
+	}
+
+	for _, roomID := range roomIDs {
+		uc.broadcastStatusToRoom(ctx, roomID, userID, status, time.Time{})
+	}
+}
+
+// broadcastStatusToRoom sends a presence-changed event to a single room.
+func (uc *presenceUseCase) broadcastStatusToRoom(ctx context.Context, roomID, userID string, status entities.PresenceStatus, lastSeen time.Time) {
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		log.Printf("Could not find user %s: %v", userID, err)
+		return
+	}
+
+	event := &entities.PresenceChangedResponse{
+		Event:     "presence-changed",
+		RoomID:    roomID,
+		UserID:    userID,
+		Username:  user.Username,
+		Status:    status,
+		LastSeen:  lastSeen,
+		Timestamp: time.Now(),
+	}
+	payload, _ := json.Marshal(event)
+	uc.broadcaster.BroadcastToRoom(roomID, payload)
+}
+
+// broadcastUserCount sends the room's active and total user counts to the room.
+func (uc *presenceUseCase) broadcastUserCount(ctx context.Context, roomID string) {
+	connected, err := uc.presenceRepo.FindRoomUsers(ctx, roomID)
+	if err != nil {
+		log.Printf("Failed to list users of room %s: %v", roomID, err)
+		return
+	}
+	total, err := uc.memberRepo.CountMembers(ctx, roomID)
+	if err != nil {
+		log.Printf("Failed to count members of room %s: %v", roomID, err)
+		return
+	}
+
+	event := &entities.UserCountResponse{
+		Event:       "user-count",
+		RoomID:      roomID,
+		ActiveUsers: len(connected),
+		TotalUsers:  int(total),
+	}
+	payload, _ := json.Marshal(event)
+	uc.broadcaster.BroadcastToRoom(roomID, payload)
+}
@@ -0,0 +1,426 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
+	"api-gateway/pkg/filestorage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// resumableChunkPrefix is the storage key prefix of the chunks of resumable uploads.
+	resumableChunkPrefix = "tus/"
+	// abandonedChunkAge is how old chunks without an upload must be before they are deleted,
+	// so that a chunk stored just before its upload is recorded is not mistaken for an abandoned one.
+	abandonedChunkAge = 10 * time.Minute
+)
+
+// ResumableUploadUseCase defines the business logic for uploading files in chunks with the tus protocol.
+type ResumableUploadUseCase interface {
+	// Create starts an upload of a file of length bytes by a user, for a room if roomID is not empty.
+	Create(ctx context.Context, userID, roomID, fileName string, length int64) (*entities.ResumableUpload, error)
+
+	// Status returns an upload of the user. Unknown and expired uploads, and those of other users, return a not found error.
+	Status(ctx context.Context, userID, id string) (*entities.ResumableUpload, error)
+
+	// WriteChunk appends the bytes of reader to an upload at offset, which must be the upload's current offset.
+	// Once all bytes are received, the file is validated and stored like a direct upload, and its details are returned.
+	WriteChunk(ctx context.Context, userID, id string, offset int64, reader io.Reader) (*entities.ResumableUpload, *FileUploadResponse, error)
+
+	// Terminate cancels an upload and deletes its chunks.
+	Terminate(ctx context.Context, userID, id string) error
+
+	// MaxSize returns the largest file size accepted.
+	MaxSize() int64
+
+	// Run refunds expired uploads and deletes their chunks every interval until the context is cancelled.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type resumableUploadUseCase struct {
+	uploadRepo  repositories.ResumableUploadRepository
+	fileStorage filestorage.FileStorage
+	fileUpload  FileUploadUseCase
+	quotas      StorageQuotaUseCase
+	maxSize     int64
+	expiry      time.Duration
+}
+
+// NewResumableUploadUseCase creates a new ResumableUploadUseCase.
+// Uploads expire if no chunk is received for the given expiry; completed uploads are stored through fileUpload.
+// Uploads are charged to their user's quota when they are created, and refunded if they are terminated, rejected
+// or expire, if a quota use case is given.
+func NewResumableUploadUseCase(
+	uploadRepo repositories.ResumableUploadRepository,
+	fileStorage filestorage.FileStorage,
+	fileUpload FileUploadUseCase,
+	quotas StorageQuotaUseCase,
+	maxSize int64,
+	expiry time.Duration,
+) ResumableUploadUseCase {
+	return &resumableUploadUseCase{
+		uploadRepo:  uploadRepo,
+		fileStorage: fileStorage,
+		fileUpload:  fileUpload,
+		quotas:      quotas,
+		maxSize:     maxSize,
+		expiry:      expiry,
+	}
+}
+
+// MaxSize returns the configured limit.
+func (uc *resumableUploadUseCase) MaxSize() int64 {
+	return uc.maxSize
+}
+
+// Create charges the upload to the user's quota and records it. Chunks are only stored once they arrive.
+// Charging the whole length up front keeps concurrent uploads from exceeding the quota together.
+func (uc *resumableUploadUseCase) Create(ctx context.Context, userID, roomID, fileName string, length int64) (*entities.ResumableUpload, error) {
+	fileName, err := normalizeFileName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if length <= 0 {
+		return nil, errs.NewBadRequestError("upload length must be positive")
+	}
+	if uc.maxSize > 0 && length > uc.maxSize {
+		return nil, errs.NewPayloadTooLargeError(fmt.Sprintf("files must not be larger than %d bytes", uc.maxSize))
+	}
+	if uc.quotas != nil {
+		if err := uc.quotas.Charge(ctx, userID, roomID, length); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	upload := &entities.ResumableUpload{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		RoomID:    roomID,
+		FileName:  fileName,
+		Length:    length,
+		CreatedAt: now,
+		ExpiresAt: now.Add(uc.expiry),
+	}
+	if err := uc.uploadRepo.Create(ctx, upload); err != nil {
+		uc.refund(ctx, upload)
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Status looks up the upload. Uploads of other users are reported as unknown, so that their IDs cannot be probed.
+func (uc *resumableUploadUseCase) Status(ctx context.Context, userID, id string) (*entities.ResumableUpload, error) {
+	upload, err := uc.uploadRepo.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, errs.NewNotFoundError("upload not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if upload.UserID != userID {
+		return nil, errs.NewNotFoundError("upload not found")
+	}
+	return upload, nil
+}
+
+// WriteChunk stores the chunk as a file of its own and appends it to the upload.
+// Receiving the last chunk completes the upload.
+func (uc *resumableUploadUseCase) WriteChunk(ctx context.Context, userID, id string, offset int64, reader io.Reader) (*entities.ResumableUpload, *FileUploadResponse, error) {
+	upload, err := uc.Status(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upload.Result != nil {
+		// The client retries the last chunk because it missed the response.
+		if offset != upload.Length {
+			return nil, nil, errs.NewConflictError(fmt.Sprintf("upload offset is %d", upload.Offset))
+		}
+		return upload, fileUploadResponse(upload.Result), nil
+	}
+	if offset != upload.Offset {
+		return nil, nil, errs.NewConflictError(fmt.Sprintf("upload offset is %d", upload.Offset))
+	}
+
+	if offset < upload.Length {
+		upload, err = uc.appendChunk(ctx, upload, reader)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if upload.Offset < upload.Length {
+		return upload, nil, nil
+	}
+
+	response, err := uc.complete(ctx, upload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return upload, response, nil
+}
+
+// appendChunk stores the bytes of reader and records them as the upload's next chunk.
+func (uc *resumableUploadUseCase) appendChunk(ctx context.Context, upload *entities.ResumableUpload, reader io.Reader) (*entities.ResumableUpload, error) {
+	remaining := upload.Length - upload.Offset
+	counter := &countingReader{reader: io.LimitReader(reader, remaining+1)}
+
+	chunkKey := fmt.Sprintf("%s%s/%d-%s", resumableChunkPrefix, upload.ID, upload.Offset, uuid.New().String())
+	if _, err := uc.fileStorage.Put(ctx, chunkKey, counter); err != nil {
+		return nil, err
+	}
+	if counter.n > remaining {
+		uc.deleteChunks(ctx, []string{chunkKey})
+		return nil, errs.NewBadRequestError(fmt.Sprintf("chunk exceeds the upload length by %d or more bytes", counter.n-remaining))
+	}
+	if counter.n == 0 {
+		uc.deleteChunks(ctx, []string{chunkKey})
+		return upload, nil
+	}
+
+	newOffset := upload.Offset + counter.n
+	expiresAt := time.Now().Add(uc.expiry)
+	appended, err := uc.uploadRepo.AppendChunk(ctx, upload.ID, upload.Offset, newOffset, chunkKey, expiresAt)
+	if err != nil || !appended {
+		uc.deleteChunks(ctx, []string{chunkKey})
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, errs.NewNotFoundError("upload not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !appended {
+		// Another request wrote at the same offset first.
+		return nil, errs.NewConflictError("upload offset has changed")
+	}
+
+	upload.Offset = newOffset
+	upload.ExpiresAt = expiresAt
+	upload.Chunks = append(upload.Chunks, chunkKey)
+	return upload, nil
+}
+
+// complete stores the file assembled from the upload's chunks through the file upload use case,
+// so that it is validated like a direct upload. Invalid files end the upload, which is refunded.
+func (uc *resumableUploadUseCase) complete(ctx context.Context, upload *entities.ResumableUpload) (*FileUploadResponse, error) {
+	started, err := uc.uploadRepo.StartCompletion(ctx, upload.ID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, errs.NewNotFoundError("upload not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, errs.NewConflictError("upload is already being completed")
+	}
+
+	reader := &chunkReader{ctx: ctx, fileStorage: uc.fileStorage, keys: upload.Chunks}
+	response, err := uc.fileUpload.StoreFile(ctx, reader, upload.UserID, upload.FileName, upload.Length)
+	reader.Close()
+
+	var customErr errs.CustomError
+	if errors.As(err, &customErr) {
+		uc.deleteChunks(ctx, upload.Chunks)
+		charged, deleteErr := uc.uploadRepo.Delete(ctx, upload.ID)
+		if deleteErr != nil {
+			log.Printf("Failed to delete rejected upload %s: %v", upload.ID, deleteErr)
+		}
+		if charged {
+			uc.refund(ctx, upload)
+		}
+		return nil, err
+	}
+	if err != nil {
+		if err := uc.uploadRepo.CancelCompletion(ctx, upload.ID); err != nil {
+			log.Printf("Failed to cancel completion of upload %s: %v", upload.ID, err)
+		}
+		return nil, err
+	}
+
+	result := &entities.FileMetadata{
+		URL:        response.URL,
+		FileName:   response.FileName,
+		FileSize:   response.FileSize,
+		MIMEType:   response.MIMEType,
+		SHA256:     response.SHA256,
+		Thumbnails: response.Thumbnails,
+	}
+	// Remember the result for a while, for clients retrying the last chunk.
+	upload.Result = result
+	upload.ExpiresAt = time.Now().Add(uc.expiry)
+	if err := uc.uploadRepo.Complete(ctx, upload.ID, result, upload.ExpiresAt); err != nil {
+		log.Printf("Failed to record completion of upload %s: %v", upload.ID, err)
+	}
+	uc.deleteChunks(ctx, upload.Chunks)
+	upload.Chunks = nil
+
+	return response, nil
+}
+
+// Terminate deletes the upload and its chunks, and refunds it unless it was completed.
+func (uc *resumableUploadUseCase) Terminate(ctx context.Context, userID, id string) error {
+	upload, err := uc.Status(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	charged, err := uc.uploadRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if charged {
+		uc.refund(ctx, upload)
+	}
+	uc.deleteChunks(ctx, upload.Chunks)
+	return nil
+}
+
+// refund reverts the charge of an upload that will not be completed.
+func (uc *resumableUploadUseCase) refund(ctx context.Context, upload *entities.ResumableUpload) {
+	if uc.quotas != nil {
+		uc.quotas.Refund(ctx, upload.UserID, upload.RoomID, upload.Length)
+	}
+}
+
+// Run periodically refunds expired uploads and deletes abandoned chunks. Every node may run it.
+func (uc *resumableUploadUseCase) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.refundExpiredUploads(ctx)
+			uc.deleteAbandonedChunks(ctx)
+		}
+	}
+}
+
+// refundExpiredUploads refunds the uploads that expired before they were completed.
+// Each upload is released by one node only.
+func (uc *resumableUploadUseCase) refundExpiredUploads(ctx context.Context) {
+	expired, err := uc.uploadRepo.ReleaseExpired(ctx, time.Now())
+	if err != nil {
+		log.Printf("Failed to release expired uploads: %v", err)
+	}
+	for _, upload := range expired {
+		uc.refund(ctx, upload)
+	}
+}
+
+// deleteAbandonedChunks deletes the chunks whose upload expired or failed to record them.
+func (uc *resumableUploadUseCase) deleteAbandonedChunks(ctx context.Context) {
+	files, err := uc.fileStorage.List(ctx, resumableChunkPrefix)
+	if err != nil {
+		log.Printf("Failed to list upload chunks: %v", err)
+		return
+	}
+
+	// Chunks are grouped by upload, and each upload is looked up once.
+	recorded := make(map[string]map[string]bool)
+	cutoff := time.Now().Add(-abandonedChunkAge)
+	for _, file := range files {
+		if file.ModTime.After(cutoff) {
+			continue
+		}
+		id, _, found := strings.Cut(strings.TrimPrefix(file.Key, resumableChunkPrefix), "/")
+		if !found {
+			continue
+		}
+
+		chunks, ok := recorded[id]
+		if !ok {
+			chunks = make(map[string]bool)
+			upload, err := uc.uploadRepo.FindByID(ctx, id)
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				log.Printf("Failed to look up upload %s: %v", id, err)
+				continue
+			}
+			if upload != nil {
+				for _, key := range upload.Chunks {
+					chunks[key] = true
+				}
+			}
+			recorded[id] = chunks
+		}
+
+		if !chunks[file.Key] {
+			uc.deleteChunks(ctx, []string{file.Key})
+		}
+	}
+}
+
+// deleteChunks deletes stored chunks. Failures are logged; leftovers are deleted as abandoned chunks later.
+func (uc *resumableUploadUseCase) deleteChunks(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := uc.fileStorage.Delete(ctx, key); err != nil && !errors.Is(err, filestorage.ErrNotFound) {
+			log.Printf("Failed to delete upload chunk %s: %v", key, err)
+		}
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// chunkReader reads stored chunks one after another, opening each only when it is reached.
+type chunkReader struct {
+	ctx         context.Context
+	fileStorage filestorage.FileStorage
+	keys        []string
+	current     io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			reader, _, err := r.fileStorage.Open(r.ctx, r.keys[0])
+			if err != nil {
+				return 0, fmt.Errorf("failed to open upload chunk %s: %w", r.keys[0], err)
+			}
+			r.current, r.keys = reader, r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close closes the chunk being read.
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
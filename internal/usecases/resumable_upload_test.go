@@ -0,0 +1,101 @@
+package usecases
+
+import (
+	"api-gateway/internal/repositories"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeQuotas is a StorageQuotaUseCase with the same quota for every user.
+type fakeQuotas struct {
+	mu    sync.Mutex
+	quota int64
+	used  map[string]int64
+}
+
+func (q *fakeQuotas) Charge(ctx context.Context, userID, roomID string, size int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.used[userID]+size > q.quota {
+		return quotaExceededError(q.used[userID], q.quota, size)
+	}
+	q.used[userID] += size
+	return nil
+}
+
+func (q *fakeQuotas) Refund(ctx context.Context, userID, roomID string, size int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.used[userID] -= size
+}
+
+func (q *fakeQuotas) Usage(ctx context.Context, userID string) (*StorageUsageResponse, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return &StorageUsageResponse{Used: q.used[userID], Quota: q.quota}, nil
+}
+
+func (q *fakeQuotas) usedBy(userID string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.used[userID]
+}
+
+func TestResumableUploadReservesQuota(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	uploads := repositories.NewRedisResumableUploadRepository(client)
+	quotas := &fakeQuotas{quota: 100, used: make(map[string]int64)}
+	fileUpload, storage := newTestFileUploadUseCase(t, nil)
+	uc := NewResumableUploadUseCase(uploads, storage, fileUpload, quotas, 0, time.Hour)
+
+	// Uploads in progress count towards the quota, so they cannot exceed it together.
+	first, err := uc.Create(ctx, "user-1", "", "a.txt", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Create(ctx, "user-1", "", "b.txt", 60); err == nil {
+		t.Fatal("second upload: got no error, want the quota exceeded")
+	}
+
+	if err := uc.Terminate(ctx, "user-1", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if used := quotas.usedBy("user-1"); used != 0 {
+		t.Fatalf("after terminating: got %d bytes used, want 0", used)
+	}
+
+	// Expired uploads are refunded by the sweeper.
+	expiring := NewResumableUploadUseCase(uploads, storage, fileUpload, quotas, 0, -time.Minute)
+	if _, err := expiring.Create(ctx, "user-1", "", "c.txt", 40); err != nil {
+		t.Fatal(err)
+	}
+	expiring.(*resumableUploadUseCase).refundExpiredUploads(ctx)
+	if used := quotas.usedBy("user-1"); used != 0 {
+		t.Fatalf("after expiring: got %d bytes used, want 0", used)
+	}
+
+	// Completed uploads stay charged once, without a second charge for storing the file.
+	upload, err := uc.Create(ctx, "user-1", "", "d.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, response, err := uc.WriteChunk(ctx, "user-1", upload.ID, 0, strings.NewReader("hello")); err != nil || response == nil {
+		t.Fatalf("write: got %+v, %v, want a stored file", response, err)
+	}
+	if used := quotas.usedBy("user-1"); used != 5 {
+		t.Fatalf("after completing: got %d bytes used, want 5", used)
+	}
+	if err := uc.Terminate(ctx, "user-1", upload.ID); err != nil {
+		t.Fatal(err)
+	}
+	if used := quotas.usedBy("user-1"); used != 5 {
+		t.Fatalf("after terminating a completed upload: got %d bytes used, want 5", used)
+	}
+}
@@ -0,0 +1,130 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"api-gateway/pkg/errs"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// StorageUsageResponse is the DTO returned for the bytes a user uploaded in the current quota period.
+// It reports upload volume, not the storage the user's files take up: every upload is counted at its
+// full size, including files that were already stored, and deleting a file does not lower it.
+type StorageUsageResponse struct {
+	Used      int64            `json:"used"`                // Bytes uploaded in the period
+	Files     int64            `json:"files"`               // Uploads in the period
+	Quota     int64            `json:"quota"`               // Bytes that may be uploaded per period; zero means unlimited
+	Remaining *int64           `json:"remaining,omitempty"` // Bytes left to upload in the period; omitted without a quota
+	Rooms     map[string]int64 `json:"rooms"`               // Bytes uploaded for each room in the period
+	ResetsAt  *time.Time       `json:"resetsAt,omitempty"`  // End of the period; omitted if usage never resets
+}
+
+// StorageQuotaUseCase defines the business logic for accounting the bytes users upload per period and limiting them per role.
+// Quotas limit upload volume rather than storage used, so deduplicated uploads are charged and deletions are not refunded.
+type StorageQuotaUseCase interface {
+	// Charge records an upload of size bytes by a user, for a room if roomID is not empty.
+	// Uploads that would exceed the user's quota are rejected.
+	Charge(ctx context.Context, userID, roomID string, size int64) error
+
+	// Refund reverts the charge of an upload that failed. Deleting a stored file is not refunded.
+	Refund(ctx context.Context, userID, roomID string, size int64)
+
+	// Usage returns the bytes a user uploaded in the current quota period, and their quota.
+	Usage(ctx context.Context, userID string) (*StorageUsageResponse, error)
+}
+
+// storageQuotaUseCase implements the StorageQuotaUseCase interface.
+type storageQuotaUseCase struct {
+	userRepo  repositories.UserRepository
+	usageRepo repositories.StorageUsageRepository
+	quotas    map[entities.UserRole]int64
+	period    time.Duration
+}
+
+// NewStorageQuotaUseCase creates a new StorageQuotaUseCase. Quotas are in bytes per role, where zero or a missing
+// role is unlimited. Usage is reset period after a user's first upload, or never if period is zero.
+func NewStorageQuotaUseCase(
+	userRepo repositories.UserRepository,
+	usageRepo repositories.StorageUsageRepository,
+	quotas map[entities.UserRole]int64,
+	period time.Duration,
+) StorageQuotaUseCase {
+	return &storageQuotaUseCase{
+		userRepo:  userRepo,
+		usageRepo: usageRepo,
+		quotas:    quotas,
+		period:    period,
+	}
+}
+
+// Charge adds the upload to the user's usage if it fits in their quota.
+func (uc *storageQuotaUseCase) Charge(ctx context.Context, userID, roomID string, size int64) error {
+	quota, err := uc.quota(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	charged, used, err := uc.usageRepo.Add(ctx, userID, roomID, size, quota, uc.period)
+	if err != nil {
+		return err
+	}
+	if !charged {
+		return quotaExceededError(used, quota, size)
+	}
+	return nil
+}
+
+// Refund subtracts the upload from the user's usage. Failures are logged, as the upload failed already.
+func (uc *storageQuotaUseCase) Refund(ctx context.Context, userID, roomID string, size int64) {
+	if err := uc.usageRepo.Subtract(ctx, userID, roomID, size); err != nil {
+		log.Printf("Failed to refund upload of %d bytes to user %s: %v", size, userID, err)
+	}
+}
+
+// Usage reports the user's usage against their quota.
+func (uc *storageQuotaUseCase) Usage(ctx context.Context, userID string) (*StorageUsageResponse, error) {
+	quota, err := uc.quota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := uc.usageRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &StorageUsageResponse{
+		Used:     usage.Bytes,
+		Files:    usage.Files,
+		Quota:    quota,
+		Rooms:    usage.Rooms,
+		ResetsAt: usage.ResetsAt,
+	}
+	if quota > 0 {
+		remaining := max(quota-usage.Bytes, 0)
+		response.Remaining = &remaining
+	}
+	return response, nil
+}
+
+// quota returns the quota of the user's role.
+func (uc *storageQuotaUseCase) quota(ctx context.Context, userID string) (int64, error) {
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return 0, errs.NewForbiddenError("unknown user")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uc.quotas[user.Role], nil
+}
+
+// quotaExceededError describes how far an upload is over the quota.
+func quotaExceededError(used, quota, size int64) error {
+	return errs.NewPayloadTooLargeError(fmt.Sprintf(
+		"storage quota exceeded: %d of %d bytes used, the upload of %d bytes does not fit", used, quota, size,
+	))
+}
@@ -0,0 +1,138 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// UserNotifier defines the output port for sending events to all connections of a user.
+type UserNotifier interface {
+	SendMessage(clientID string, message []byte) error
+}
+
+// UnreadCounts is the DTO returned for a user's unread messages.
+type UnreadCounts struct {
+	Rooms map[string]int64 `json:"rooms"`
+	Total int64            `json:"total"`
+}
+
+// UnreadUseCase defines the business logic for tracking unread messages per user and room.
+type UnreadUseCase interface {
+	// RoomJoined makes the user a member of the room and marks the room as read.
+	RoomJoined(ctx context.Context, userID, roomID string) error
+
+	// MessageStored increments the unread count of every room member except the author.
+	MessageStored(ctx context.Context, msg *entities.Message) error
+
+	// MarkRead resets the user's unread count for the room.
+	MarkRead(ctx context.Context, userID, roomID string) error
+
+	// Counts returns the user's unread counts across all rooms.
+	Counts(ctx context.Context, userID string) (*UnreadCounts, error)
+}
+
+type unreadUseCase struct {
+	memberRepo repositories.RoomMemberRepository
+	unreadRepo repositories.UnreadRepository
+	notifier   UserNotifier
+}
+
+// NewUnreadUseCase creates a new UnreadUseCase.
+func NewUnreadUseCase(
+	memberRepo repositories.RoomMemberRepository,
+	unreadRepo repositories.UnreadRepository,
+	notifier UserNotifier,
+) UnreadUseCase {
+	return &unreadUseCase{
+		memberRepo: memberRepo,
+		unreadRepo: unreadRepo,
+		notifier:   notifier,
+	}
+}
+
+// RoomJoined adds the user to the room's members and resets their unread count,
+// since the room history is delivered when joining.
+func (uc *unreadUseCase) RoomJoined(ctx context.Context, userID, roomID string) error {
+	if err := uc.memberRepo.AddMember(ctx, roomID, userID); err != nil {
+		return err
+	}
+	return uc.MarkRead(ctx, userID, roomID)
+}
+
+// MessageStored increments the counters of the room members and notifies them of their new counts.
+func (uc *unreadUseCase) MessageStored(ctx context.Context, msg *entities.Message) error {
+	// The author of a message is a member of the room, even if they posted over REST.
+	if err := uc.memberRepo.AddMember(ctx, msg.RoomID, msg.UserID); err != nil {
+		return err
+	}
+
+	members, err := uc.memberRepo.FindMembers(ctx, msg.RoomID)
+	if err != nil {
+		return err
+	}
+
+	var recipients []string
+	for _, member := range members {
+		if member != msg.UserID {
+			recipients = append(recipients, member)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	counts, err := uc.unreadRepo.Increment(ctx, msg.RoomID, recipients)
+	if err != nil {
+		return err
+	}
+
+	for userID, count := range counts {
+		uc.notify(userID, msg.RoomID, count)
+	}
+	return nil
+}
+
+// MarkRead resets the user's counter and notifies their other connections.
+func (uc *unreadUseCase) MarkRead(ctx context.Context, userID, roomID string) error {
+	if err := uc.unreadRepo.Reset(ctx, userID, roomID); err != nil {
+		return err
+	}
+
+	uc.notify(userID, roomID, 0)
+	return nil
+}
+
+// Counts returns the user's unread counts across all rooms.
+func (uc *unreadUseCase) Counts(ctx context.Context, userID string) (*UnreadCounts, error) {
+	rooms, err := uc.unreadRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &UnreadCounts{Rooms: rooms}
+	for _, count := range rooms {
+		counts.Total += count
+	}
+	return counts, nil
+}
+
+// notify sends an unread-updated event to the user. Users who are offline simply miss it,
+// since their counts are fetched again when they come back.
+func (uc *unreadUseCase) notify(userID, roomID string, count int64) {
+	event := &entities.UnreadUpdatedResponse{
+		Event:       "unread-updated",
+		RoomID:      roomID,
+		UnreadCount: count,
+		Timestamp:   time.Now(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal unread event: %v", err)
+		return
+	}
+	_ = uc.notifier.SendMessage(userID, payload)
+}
@@ -0,0 +1,93 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/internal/repositories"
+	"context"
+	"encoding/json"
+	"maps"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUnreadCounts(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	broadcaster := newFakeBroadcaster()
+	uc := NewUnreadUseCase(
+		repositories.NewRedisRoomMemberRepository(client),
+		repositories.NewRedisUnreadRepository(client),
+		broadcaster,
+	)
+
+	for _, member := range []struct{ userID, roomID string }{
+		{"user-1", "room-1"},
+		{"user-2", "room-1"},
+		{"user-2", "room-2"},
+	} {
+		if err := uc.RoomJoined(ctx, member.userID, member.roomID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// user-3 posts over REST without joining, and becomes a member of room-1 from then on.
+	for _, msg := range []*entities.Message{
+		{RoomID: "room-1", UserID: "user-1"},
+		{RoomID: "room-1", UserID: "user-3"},
+		{RoomID: "room-2", UserID: "user-1"},
+		{RoomID: "room-1", UserID: "user-1"},
+	} {
+		if err := uc.MessageStored(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		userID string
+		want   map[string]int64
+	}{
+		{"user-1", map[string]int64{"room-1": 1}},
+		{"user-2", map[string]int64{"room-1": 3, "room-2": 1}},
+		{"user-3", map[string]int64{"room-1": 1}},
+	} {
+		wantUnreadCounts(t, uc, tt.userID, tt.want)
+	}
+
+	// Reading a room only resets its own count, and is pushed to the user's connections.
+	if err := uc.MarkRead(ctx, "user-2", "room-1"); err != nil {
+		t.Fatal(err)
+	}
+	wantUnreadCounts(t, uc, "user-2", map[string]int64{"room-1": 0, "room-2": 1})
+
+	var last entities.UnreadUpdatedResponse
+	sent := broadcaster.users["user-2"]
+	if err := json.Unmarshal(sent[len(sent)-1], &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Event != "unread-updated" || last.RoomID != "room-1" || last.UnreadCount != 0 {
+		t.Fatalf("got event %+v, want room-1 marked read", last)
+	}
+}
+
+// wantUnreadCounts fails the test unless the user's unread counts match. Rooms with a zero count may be omitted.
+func wantUnreadCounts(t *testing.T, uc UnreadUseCase, userID string, want map[string]int64) {
+	t.Helper()
+	counts, err := uc.Counts(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, count := range want {
+		total += count
+	}
+	isZero := func(roomID string, count int64) bool { return count == 0 }
+	got := maps.Clone(counts.Rooms)
+	maps.DeleteFunc(got, isZero)
+	want = maps.Clone(want)
+	maps.DeleteFunc(want, isZero)
+	if !maps.Equal(got, want) || counts.Total != total {
+		t.Fatalf("%s: got %v with total %d, want %v with total %d", userID, counts.Rooms, counts.Total, want, total)
+	}
+}
@@ -0,0 +1,269 @@
+package usecases
+
+import (
+	"api-gateway/pkg/errs"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sniffLength is the number of leading bytes used to detect the content type of uploads.
+const sniffLength = 512
+
+// SizeLimit is the largest size accepted for uploads whose MIME type matches Pattern.
+type SizeLimit struct {
+	Pattern string // A MIME type such as "image/png", a wildcard such as "image/*", or "*"
+	MaxSize int64  // In bytes; zero disables the limit
+}
+
+// UploadValidationConfig holds the rules enforced on uploaded files.
+type UploadValidationConfig struct {
+	AllowedTypes   []string    // MIME type patterns; empty allows every type that is not denied
+	DeniedTypes    []string    // MIME type patterns, checked before the allowed types
+	SizeLimits     []SizeLimit // The first matching limit applies
+	DefaultMaxSize int64       // Applies if no size limit matches; zero disables the limit
+}
+
+// UploadValidator checks uploaded files before they are stored.
+type UploadValidator interface {
+	// Validate detects the MIME type of an upload from its first bytes and checks it against the
+	// type and size rules and the file name's extension. It returns the detected MIME type and a reader
+	// of the whole upload, which fails with a payload too large error if the upload exceeds its limit.
+	Validate(reader io.Reader, fileName string, fileSize int64) (io.Reader, string, error)
+
+	// MaxSize returns the largest size accepted for any type, or zero if the size of some types is not limited.
+	MaxSize() int64
+}
+
+type uploadValidator struct {
+	config UploadValidationConfig
+}
+
+// NewUploadValidator creates a new UploadValidator.
+func NewUploadValidator(config UploadValidationConfig) UploadValidator {
+	return &uploadValidator{
+		config: config,
+	}
+}
+
+// ParseSizeLimits parses size limits written as "pattern=size", e.g. "image/*=10MB".
+// Sizes are in bytes unless suffixed with KB, MB or GB.
+func ParseSizeLimits(specs []string) ([]SizeLimit, error) {
+	limits := make([]SizeLimit, 0, len(specs))
+	for _, spec := range specs {
+		pattern, size, found := strings.Cut(spec, "=")
+		if !found {
+			return nil, fmt.Errorf("size limit %q must be written as pattern=size", spec)
+		}
+		maxSize, err := ParseByteSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("size limit %q: %w", spec, err)
+		}
+		limits = append(limits, SizeLimit{Pattern: strings.TrimSpace(pattern), MaxSize: maxSize})
+	}
+	return limits, nil
+}
+
+// ParseByteSize parses a size in bytes, optionally suffixed with KB, MB or GB (powers of 1024).
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			value, multiplier = strings.TrimSpace(number), m
+			break
+		}
+	}
+	value = strings.TrimSuffix(value, "B")
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// MaxSize returns the largest configured limit. A zero limit disables the limit, so it wins.
+func (v *uploadValidator) MaxSize() int64 {
+	maxSize := v.config.DefaultMaxSize
+	if maxSize == 0 {
+		return 0
+	}
+	for _, limit := range v.config.SizeLimits {
+		if limit.MaxSize == 0 {
+			return 0
+		}
+		maxSize = max(maxSize, limit.MaxSize)
+	}
+	return maxSize
+}
+
+// Validate sniffs the upload and enforces the rules.
+func (v *uploadValidator) Validate(reader io.Reader, fileName string, fileSize int64) (io.Reader, string, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, "", err
+	}
+	head = head[:n]
+	if n == 0 {
+		return nil, "", errs.NewBadRequestError("file must not be empty")
+	}
+
+	mimeType, err := detectMIMEType(head, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if matchesAnyMIMEType(mimeType, v.config.DeniedTypes) ||
+		(len(v.config.AllowedTypes) > 0 && !matchesAnyMIMEType(mimeType, v.config.AllowedTypes)) {
+		return nil, "", errs.NewBadRequestError(fmt.Sprintf("files of type %s are not allowed", mimeType))
+	}
+
+	maxSize := v.maxSizeFor(mimeType)
+	if maxSize > 0 && fileSize > maxSize {
+		return nil, "", tooLargeError(mimeType, maxSize)
+	}
+
+	full := io.MultiReader(bytes.NewReader(head), reader)
+	if maxSize > 0 {
+		// The declared size is not trusted, so the limit is also enforced while the file is stored.
+		full = &limitedReader{reader: full, remaining: maxSize, err: tooLargeError(mimeType, maxSize)}
+	}
+	return full, mimeType, nil
+}
+
+// maxSizeFor returns the size limit of a MIME type.
+func (v *uploadValidator) maxSizeFor(mimeType string) int64 {
+	for _, limit := range v.config.SizeLimits {
+		if matchesMIMEType(mimeType, limit.Pattern) {
+			return limit.MaxSize
+		}
+	}
+	return v.config.DefaultMaxSize
+}
+
+// tooLargeError reports an upload over its size limit.
+func tooLargeError(mimeType string, maxSize int64) error {
+	return errs.NewPayloadTooLargeError(fmt.Sprintf("files of type %s must not be larger than %d bytes", mimeType, maxSize))
+}
+
+// detectMIMEType sniffs the MIME type of the content and checks that it agrees with the file name's extension.
+// When they agree, the extension's more specific type is preferred, e.g. for office documents sniffed as zip files.
+func detectMIMEType(head []byte, fileName string) (string, error) {
+	sniffed := mediaType(http.DetectContentType(head))
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == "" {
+		return sniffed, nil
+	}
+	declared := mediaType(mime.TypeByExtension(ext))
+	if declared == "" {
+		// Unknown extensions cannot be checked, but must not disguise a known type.
+		if sniffed != "application/octet-stream" && sniffed != "text/plain" {
+			return "", errs.NewBadRequestError(fmt.Sprintf("file extension %s does not match its content (%s)", ext, sniffed))
+		}
+		return sniffed, nil
+	}
+
+	switch {
+	case declared == sniffed:
+		return sniffed, nil
+	case sniffed == "text/plain" && isTextMIMEType(declared):
+		return declared, nil
+	case sniffed == "application/zip" && isZipBasedMIMEType(declared):
+		return declared, nil
+	case sniffed == "application/octet-stream" && !isSniffableMIMEType(declared):
+		// Formats that http.DetectContentType does not know are trusted by extension.
+		return declared, nil
+	}
+	return "", errs.NewBadRequestError(fmt.Sprintf("file extension %s does not match its content (%s)", ext, sniffed))
+}
+
+// mediaType strips the parameters from a MIME type, e.g. "text/plain; charset=utf-8" becomes "text/plain".
+func mediaType(mimeType string) string {
+	t, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+// isTextMIMEType reports whether content of the type is plain text.
+func isTextMIMEType(mimeType string) bool {
+	switch mimeType {
+	case "application/json", "application/javascript", "application/xml", "application/x-yaml", "application/yaml":
+		return true
+	}
+	return strings.HasPrefix(mimeType, "text/")
+}
+
+// isZipBasedMIMEType reports whether files of the type are zip archives, such as office documents.
+func isZipBasedMIMEType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.") ||
+		mimeType == "application/epub+zip" || mimeType == "application/java-archive"
+}
+
+// isSniffableMIMEType reports whether http.DetectContentType recognizes content of the type,
+// so that a file of the type that was sniffed as unknown binary data is disguised.
+func isSniffableMIMEType(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") || strings.HasPrefix(mimeType, "image/") {
+		return true
+	}
+	switch mimeType {
+	case "application/pdf", "application/zip", "application/x-gzip", "application/x-rar-compressed",
+		"application/ogg", "audio/mpeg", "audio/wave", "audio/wav", "video/mp4", "video/webm", "video/avi":
+		return true
+	}
+	return false
+}
+
+// matchesAnyMIMEType reports whether the MIME type matches one of the patterns.
+func matchesAnyMIMEType(mimeType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchesMIMEType(mimeType, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMIMEType matches a MIME type against "*", "type/*" or an exact type.
+func matchesMIMEType(mimeType, pattern string) bool {
+	if pattern == "*" || pattern == "*/*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, prefix+"/")
+	}
+	return mimeType == pattern
+}
+
+// limitedReader fails with err once more than remaining bytes are read.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	err       error
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, r.err
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, r.err
+	}
+	return n, err
+}
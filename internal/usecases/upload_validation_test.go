@@ -0,0 +1,20 @@
+package usecases
+
+import "testing"
+
+func TestUploadValidatorMaxSize(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config UploadValidationConfig
+		want   int64
+	}{
+		{"default only", UploadValidationConfig{DefaultMaxSize: 4 << 20}, 4 << 20},
+		{"larger type limit", UploadValidationConfig{DefaultMaxSize: 4 << 20, SizeLimits: []SizeLimit{{"video/*", 100 << 20}}}, 100 << 20},
+		{"unlimited default", UploadValidationConfig{SizeLimits: []SizeLimit{{"image/*", 10 << 20}}}, 0},
+		{"unlimited type", UploadValidationConfig{DefaultMaxSize: 4 << 20, SizeLimits: []SizeLimit{{"video/*", 0}}}, 0},
+	} {
+		if got := NewUploadValidator(tt.config).MaxSize(); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
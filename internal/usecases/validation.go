@@ -0,0 +1,108 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/pkg/errs"
+	"api-gateway/pkg/filestorage"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	messageTypeText = "text"
+	messageTypeFile = "file"
+
+	// maxFileNameLength is the longest file name accepted for uploads.
+	maxFileNameLength = 255
+)
+
+// MessageValidator checks and normalizes incoming messages before they are stored.
+type MessageValidator interface {
+	// Validate trims the message content in place and returns a bad request error
+	// if the message does not match the schema of its type.
+	Validate(msg *IncomingMessage) error
+}
+
+// MessageValidationConfig holds the limits enforced by the message validator.
+type MessageValidationConfig struct {
+	AllowedTypes     []string
+	MaxContentLength int // In characters; zero disables the limit
+}
+
+type messageValidator struct {
+	allowedTypes     map[string]bool
+	maxContentLength int
+	fileStorage      filestorage.FileStorage
+}
+
+// NewMessageValidator creates a new MessageValidator.
+// File messages are only accepted if their URL was produced by the given file storage.
+func NewMessageValidator(fileStorage filestorage.FileStorage, config MessageValidationConfig) MessageValidator {
+	allowedTypes := make(map[string]bool, len(config.AllowedTypes))
+	for _, t := range config.AllowedTypes {
+		allowedTypes[t] = true
+	}
+
+	return &messageValidator{
+		allowedTypes:     allowedTypes,
+		maxContentLength: config.MaxContentLength,
+		fileStorage:      fileStorage,
+	}
+}
+
+// Validate checks the message type, content and metadata.
+func (v *messageValidator) Validate(msg *IncomingMessage) error {
+	if !v.allowedTypes[msg.Type] {
+		return errs.NewBadRequestError(fmt.Sprintf("unsupported message type '%s'", msg.Type))
+	}
+
+	if !utf8.ValidString(msg.Content) {
+		return errs.NewBadRequestError("content must be valid UTF-8")
+	}
+	msg.Content = strings.TrimSpace(msg.Content)
+	if v.maxContentLength > 0 && utf8.RuneCountInString(msg.Content) > v.maxContentLength {
+		return errs.NewBadRequestError(fmt.Sprintf("content exceeds the maximum length of %d characters", v.maxContentLength))
+	}
+
+	if msg.Type == messageTypeFile {
+		return v.validateFile(msg)
+	}
+
+	if msg.Metadata != nil {
+		return errs.NewBadRequestError("metadata is only allowed on file messages")
+	}
+	if msg.Content == "" {
+		return errs.NewBadRequestError("content must not be empty")
+	}
+
+	return nil
+}
+
+// normalizeFileName trims the name of an uploaded file. As it is shown beside the file in messages,
+// it must be valid UTF-8 of a reasonable length.
+func normalizeFileName(fileName string) (string, error) {
+	fileName = strings.TrimSpace(fileName)
+	if !utf8.ValidString(fileName) {
+		return "", errs.NewBadRequestError("fileName must be valid UTF-8")
+	}
+	if fileName == "" || len(fileName) > maxFileNameLength {
+		return "", errs.NewBadRequestError(fmt.Sprintf("fileName must be between 1 and %d bytes", maxFileNameLength))
+	}
+	return fileName, nil
+}
+
+// validateFile checks that a file message references a file from our own storage. Only the URL is
+// taken from the client; the rest of the metadata describes the stored file, and is filled in when
+// the message is stored.
+func (v *messageValidator) validateFile(msg *IncomingMessage) error {
+	if msg.Metadata == nil {
+		return errs.NewBadRequestError("metadata is required for file messages")
+	}
+	key, ok := v.fileStorage.KeyFromURL(msg.Metadata.URL)
+	if !ok || !isPublishedKey(key) {
+		return errs.NewBadRequestError("file URL must reference an uploaded file")
+	}
+	msg.Metadata = &entities.FileMetadata{URL: msg.Metadata.URL}
+	return nil
+}
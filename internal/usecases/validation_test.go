@@ -0,0 +1,107 @@
+package usecases
+
+import (
+	"api-gateway/internal/entities"
+	"api-gateway/pkg/errs"
+	"api-gateway/pkg/filestorage"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMessageValidatorValidate(t *testing.T) {
+	storage, err := filestorage.NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := NewMessageValidator(storage, MessageValidationConfig{
+		AllowedTypes:     []string{messageTypeText, messageTypeFile},
+		MaxContentLength: 5,
+	})
+
+	for _, tt := range []struct {
+		name        string
+		msg         IncomingMessage
+		wantErr     bool
+		wantContent string
+	}{
+		{"text", IncomingMessage{Type: "text", Content: "hello"}, false, "hello"},
+		{"trimmed text", IncomingMessage{Type: "text", Content: "  héllo \n"}, false, "héllo"},
+		{"unsupported type", IncomingMessage{Type: "poll", Content: "hello"}, true, ""},
+		{"empty type", IncomingMessage{Content: "hello"}, true, ""},
+		{"blank text", IncomingMessage{Type: "text", Content: " \t "}, true, ""},
+		{"too long", IncomingMessage{Type: "text", Content: "hello!"}, true, ""},
+		{"invalid UTF-8", IncomingMessage{Type: "text", Content: "he\xffllo"}, true, ""},
+		{"text with metadata", IncomingMessage{Type: "text", Content: "hi", Metadata: &entities.FileMetadata{URL: "/files/a.png"}}, true, ""},
+		{"file", IncomingMessage{Type: "file", Metadata: &entities.FileMetadata{URL: "/files/sha256/ab/ab12.png"}}, false, ""},
+		{"file without metadata", IncomingMessage{Type: "file"}, true, ""},
+		{"external file", IncomingMessage{Type: "file", Metadata: &entities.FileMetadata{URL: "https://evil.example/a.png"}}, true, ""},
+		{"quarantined file", IncomingMessage{Type: "file", Metadata: &entities.FileMetadata{URL: "/files/" + quarantineKeyPrefix + "a.png"}}, true, ""},
+		{"resumable chunk", IncomingMessage{Type: "file", Metadata: &entities.FileMetadata{URL: "/files/" + resumableChunkPrefix + "upload-1/0"}}, true, ""},
+		{"escaping file", IncomingMessage{Type: "file", Metadata: &entities.FileMetadata{URL: "/files/../secret.txt"}}, true, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			err := validator.Validate(&msg)
+			if tt.wantErr {
+				var customErr errs.CustomError
+				if !errors.As(err, &customErr) || customErr.Code != http.StatusBadRequest {
+					t.Fatalf("got %v, want a bad request error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Content != tt.wantContent {
+				t.Fatalf("got content %q, want %q", msg.Content, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestMessageValidatorKeepsOnlyFileURL(t *testing.T) {
+	storage, err := filestorage.NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := NewMessageValidator(storage, MessageValidationConfig{AllowedTypes: []string{messageTypeFile}})
+
+	// The rest of the metadata is taken from the stored file, not from the client.
+	msg := IncomingMessage{Type: "file", Metadata: &entities.FileMetadata{
+		URL:        "/files/sha256/ab/ab12.png",
+		FileName:   "invoice.pdf",
+		FileSize:   1,
+		MIMEType:   "application/pdf",
+		Thumbnails: []entities.Thumbnail{{URL: "https://evil.example/thumb.png"}},
+	}}
+	if err := validator.Validate(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Metadata.URL != "/files/sha256/ab/ab12.png" || msg.Metadata.FileName != "" ||
+		msg.Metadata.FileSize != 0 || msg.Metadata.MIMEType != "" || msg.Metadata.Thumbnails != nil {
+		t.Fatalf("got %+v, want only the URL", msg.Metadata)
+	}
+}
+
+func TestNormalizeFileName(t *testing.T) {
+	for _, tt := range []struct {
+		fileName string
+		want     string
+		wantErr  bool
+	}{
+		{"report.pdf", "report.pdf", false},
+		{"  résumé.pdf\n", "résumé.pdf", false},
+		{"", "", true},
+		{"   ", "", true},
+		{"bad\xff.txt", "", true},
+		{strings.Repeat("a", maxFileNameLength), strings.Repeat("a", maxFileNameLength), false},
+		{strings.Repeat("a", maxFileNameLength+1), "", true},
+	} {
+		got, err := normalizeFileName(tt.fileName)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeFileName(%q) = %q, %v; want %q, error %v", tt.fileName, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
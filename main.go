@@ -2,23 +2,37 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"log"
+	"math"
 	"path/filepath"
 
 	"api-gateway/config"
+	"api-gateway/internal/entities"
 	"api-gateway/internal/handlers"
 	"api-gateway/internal/infrastructures"
 	"api-gateway/internal/repositories"
 	"api-gateway/internal/usecases"
+	"api-gateway/pkg/contentfilter"
 	"api-gateway/pkg/filestorage"
+	"api-gateway/pkg/scanner"
+	"api-gateway/pkg/thumbnail"
+	"api-gateway/pkg/token"
+	"api-gateway/pkg/urlsigner"
 	"api-gateway/pkg/ws"
 
+	"github.com/gofiber/fiber/v2/middleware/expvar"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
 )
 
 func main() {
 	conf := config.NewConfig()
+	if conf.Auth.JWTSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
 
 	redisClient := infrastructures.NewRedis(
 		conf.Redis.URI,
@@ -26,33 +40,189 @@ func main() {
 		conf.Redis.DB,
 	)
 
-	mongoClient, err := mongo.Connect(
-		context.Background(),
-		options.Client().ApplyURI(conf.Mongo.URI),
-	)
-	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
-	}
-	defer mongoClient.Disconnect(context.Background())
+	// --- MongoDB ---
+	// Only connected when a store is backed by MongoDB.
+	var mongoDB *mongo.Database
+	if conf.MessageStore == "mongo" || conf.ModerationStore == "mongo" {
+		mongoClient, err := mongo.Connect(
+			context.Background(),
+			options.Client().ApplyURI(conf.Mongo.URI),
+		)
+		if err != nil {
+			log.Fatalf("Failed to connect to MongoDB: %v", err)
+		}
+		defer mongoClient.Disconnect(context.Background())
 
-	err = mongoClient.Ping(context.Background(), nil)
-	if err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+		if err := mongoClient.Ping(context.Background(), nil); err != nil {
+			log.Fatalf("Failed to ping MongoDB: %v", err)
+		}
+
+		mongoDB = mongoClient.Database(conf.Mongo.Database)
 	}
 
-	mongoDB := mongoClient.Database(conf.Mongo.Database)
+	// --- PostgreSQL ---
+	var gormDB *gorm.DB
+	if conf.UserStore == "postgres" || conf.MessageStore == "postgres" || conf.ModerationStore == "postgres" {
+		var sqlDB *sql.DB
+		gormDB, sqlDB = infrastructures.NewGorm(conf.Postgres.DSN)
+		defer sqlDB.Close()
+
+		if err := repositories.MigrateGorm(gormDB); err != nil {
+			log.Fatalf("Failed to migrate PostgreSQL schema: %v", err)
+		}
+	}
 
 	// --- Repositories ---
-	userRepository := repositories.NewMockUserRepository()
-	messageRepository := repositories.NewMongoMessageRepository(mongoDB)
+	var userRepository repositories.UserRepository
+	switch conf.UserStore {
+	case "postgres":
+		userRepository = repositories.NewGormUserRepository(gormDB)
+	case "mock":
+		userRepository = repositories.NewMockUserRepository()
+	default:
+		log.Fatalf("Unknown user store: %s", conf.UserStore)
+	}
+	// Role checks read the uncached store, so that demotions apply at once.
+	userStore := userRepository
+	userRepository = repositories.NewCachedUserRepository(userRepository, conf.UserCache.Size, conf.UserCache.TTL)
+	var messageRepository repositories.MessageRepository
+	switch conf.MessageStore {
+	case "postgres":
+		messageRepository = repositories.NewGormMessageRepository(gormDB)
+	case "mongo":
+		messageRepository = repositories.NewMongoMessageRepository(mongoDB)
+	case "memory":
+		messageRepository = repositories.NewMemoryMessageRepository()
+	default:
+		log.Fatalf("Unknown message store: %s", conf.MessageStore)
+	}
+	var moderationRepository repositories.ModerationRepository
+	switch conf.ModerationStore {
+	case "postgres":
+		moderationRepository = repositories.NewGormModerationRepository(gormDB)
+	case "mongo":
+		moderationRepository = repositories.NewMongoModerationRepository(mongoDB)
+	default:
+		log.Fatalf("Unknown moderation store: %s", conf.ModerationStore)
+	}
+	roomMemberRepository := repositories.NewRedisRoomMemberRepository(redisClient)
+	unreadRepository := repositories.NewRedisUnreadRepository(redisClient)
+	refreshTokenRepository := repositories.NewRedisRefreshTokenRepository(redisClient)
+	presenceRepository := repositories.NewRedisPresenceRepository(redisClient, uuid.NewString(), conf.Presence.NodeTTL)
+	inboxRepository := repositories.NewRedisInboxRepository(redisClient)
+	messageDedupeRepository := repositories.NewRedisMessageDedupeRepository(redisClient)
+	resumableUploadRepository := repositories.NewRedisResumableUploadRepository(redisClient)
+	fileReferenceRepository := repositories.NewRedisFileReferenceRepository(redisClient)
+	storageUsageRepository := repositories.NewRedisStorageUsageRepository(redisClient)
+	historyCacheRepository := repositories.NewRedisHistoryCacheRepository(
+		redisClient,
+		int64(conf.Chat.HistoryCacheSize),
+		conf.Chat.HistoryCacheTTL,
+	)
 
 	// --- File Storage ---
-	uploadsPath, _ := filepath.Abs("./uploads")
-	fileStorage, err := filestorage.NewLocalStorage(uploadsPath, conf.BaseUrl+"/files")
+	uploadsPath, _ := filepath.Abs(conf.Storage.LocalPath)
+	var fileStorage filestorage.FileStorage
+	var err error
+	switch conf.Storage.Backend {
+	case "local":
+		fileStorage, err = filestorage.NewLocalStorage(uploadsPath, conf.BaseUrl+"/files")
+	case "s3":
+		// Presigned URLs expire after at most 7 days, so they must not end up in stored messages.
+		// Attachments are served through signed URLs of this server or from a public base URL instead.
+		if !conf.Storage.SignedURLs && conf.Storage.S3.PublicBaseURL == "" {
+			log.Fatal("S3 storage requires FILE_SIGNED_URLS or S3_PUBLIC_BASE_URL")
+		}
+		fileStorage, err = filestorage.NewS3Storage(context.Background(), filestorage.S3Config{
+			Endpoint:        conf.Storage.S3.Endpoint,
+			Region:          conf.Storage.S3.Region,
+			Bucket:          conf.Storage.S3.Bucket,
+			Prefix:          conf.Storage.S3.Prefix,
+			AccessKeyID:     conf.Storage.S3.AccessKeyID,
+			SecretAccessKey: conf.Storage.S3.SecretAccessKey,
+			UseSSL:          conf.Storage.S3.UseSSL,
+			PathStyle:       conf.Storage.S3.PathStyle,
+			PublicBaseURL:   conf.Storage.S3.PublicBaseURL,
+			PresignExpiry:   conf.Storage.S3.PresignExpiry,
+			PartSize:        uint64(conf.Storage.S3.PartSize),
+		})
+	default:
+		log.Fatalf("Unknown file storage: %s", conf.Storage.Backend)
+	}
 	if err != nil {
 		log.Fatalf("Failed to create file storage: %v", err)
 	}
 
+	// --- Content Filters ---
+	wordlistFilter, err := contentfilter.NewWordlistFilter(conf.Filter.Words, contentfilter.Mode(conf.Filter.WordsMode))
+	if err != nil {
+		log.Fatalf("Failed to create wordlist filter: %v", err)
+	}
+	linkDomainFilter, err := contentfilter.NewLinkDomainFilter(conf.Filter.BlockedDomains, contentfilter.Mode(conf.Filter.DomainsMode))
+	if err != nil {
+		log.Fatalf("Failed to create link domain filter: %v", err)
+	}
+	contentFilter := contentfilter.NewPipeline(wordlistFilter, linkDomainFilter)
+
+	// --- Upload Validation ---
+	uploadSizeLimits, err := usecases.ParseSizeLimits(conf.Upload.SizeLimits)
+	if err != nil {
+		log.Fatalf("Invalid upload size limits: %v", err)
+	}
+	uploadMaxSize, err := usecases.ParseByteSize(conf.Upload.DefaultMaxSize)
+	if err != nil {
+		log.Fatalf("Invalid upload max size: %v", err)
+	}
+	uploadValidator := usecases.NewUploadValidator(usecases.UploadValidationConfig{
+		AllowedTypes:   conf.Upload.AllowedTypes,
+		DeniedTypes:    conf.Upload.DeniedTypes,
+		SizeLimits:     uploadSizeLimits,
+		DefaultMaxSize: uploadMaxSize,
+	})
+
+	storageQuotas := make(map[entities.UserRole]int64)
+	for role, quota := range map[entities.UserRole]string{
+		entities.RoleUser:  conf.Upload.UserQuota,
+		entities.AdminRole: conf.Upload.AdminQuota,
+	} {
+		if storageQuotas[role], err = usecases.ParseByteSize(quota); err != nil {
+			log.Fatalf("Invalid storage quota of role %s: %v", role, err)
+		}
+	}
+
+	var thumbnailGenerator thumbnail.Generator
+	if len(conf.Thumbnail.Sizes) > 0 {
+		thumbnailGenerator, err = thumbnail.NewGenerator(conf.Thumbnail.Sizes, conf.Thumbnail.MaxPixels, conf.Thumbnail.Concurrency)
+		if err != nil {
+			log.Fatalf("Failed to create thumbnail generator: %v", err)
+		}
+	}
+
+	var malwareScanner scanner.Scanner
+	switch conf.Scanner.Backend {
+	case "clamd":
+		malwareScanner, err = scanner.NewClamdScanner(conf.Scanner.ClamdAddress, conf.Scanner.Timeout)
+		if err != nil {
+			log.Fatalf("Failed to create clamd scanner: %v", err)
+		}
+	case "none":
+		log.Println("Malware scanning is disabled; uploads are published unscanned")
+	default:
+		log.Fatalf("Unknown scanner backend %q", conf.Scanner.Backend)
+	}
+
+	// --- Signed File URLs ---
+	var urlSigner *urlsigner.Signer
+	var attachmentSigner usecases.AttachmentSigner
+	if conf.Storage.SignedURLs {
+		// A key of its own keeps a leak of either secret from forging both tokens and download URLs.
+		if conf.Storage.URLSecret == "" || conf.Storage.URLSecret == conf.Auth.JWTSecret {
+			log.Fatal("FILE_URL_SECRET must be set to a secret other than JWT_SECRET")
+		}
+		urlSigner = urlsigner.NewSigner(conf.Storage.URLSecret)
+		attachmentSigner = usecases.NewAttachmentSigner(urlSigner, fileStorage, conf.BaseUrl+"/files", conf.Storage.URLTTL)
+	}
+
 	// --- WebSockets ---
 	connManager := ws.NewConnectionManager(
 		ws.WithRedis(redisClient),
@@ -60,26 +230,155 @@ func main() {
 	)
 
 	// --- Use Cases ---
-	chatUseCase := usecases.NewChatUseCase(userRepository, messageRepository, connManager)
-	fileUploadUseCase := usecases.NewFileUploadUseCase(fileStorage)
+	tokenManager := token.NewManager(conf.Auth.JWTSecret, conf.AppName, conf.Auth.AccessTokenTTL)
+	authUseCase := usecases.NewAuthUseCase(userStore, refreshTokenRepository, tokenManager, conf.Auth.RefreshTokenTTL)
+	inboxUseCase := usecases.NewInboxUseCase(
+		inboxRepository,
+		presenceRepository,
+		connManager,
+		conf.Inbox.TTL,
+		int64(conf.Inbox.MaxItems),
+	)
+	moderationUseCase := usecases.NewModerationUseCase(userStore, moderationRepository, connManager, inboxUseCase)
+	unreadUseCase := usecases.NewUnreadUseCase(roomMemberRepository, unreadRepository, connManager)
+	presenceUseCase := usecases.NewPresenceUseCase(
+		userRepository,
+		roomMemberRepository,
+		presenceRepository,
+		connManager,
+		conf.Presence.IdleTimeout,
+	)
+	messageValidator := usecases.NewMessageValidator(fileStorage, usecases.MessageValidationConfig{
+		AllowedTypes:     conf.Chat.AllowedMessageTypes,
+		MaxContentLength: conf.Chat.MaxMessageLength,
+	})
+	storageQuotaUseCase := usecases.NewStorageQuotaUseCase(userRepository, storageUsageRepository, storageQuotas, conf.Upload.QuotaPeriod)
+	fileUploadUseCase := usecases.NewFileUploadUseCase(
+		fileStorage,
+		uploadValidator,
+		thumbnailGenerator,
+		fileReferenceRepository,
+		malwareScanner,
+		storageQuotaUseCase,
+		conf.Upload.GCGracePeriod,
+	)
+	chatUseCase := usecases.NewChatUseCase(
+		userRepository,
+		messageRepository,
+		connManager,
+		usecases.WithRoleRepository(userStore),
+		usecases.WithModeration(moderationUseCase),
+		usecases.WithMessageValidator(messageValidator),
+		usecases.WithContentFilter(contentFilter),
+		usecases.WithUnreadCounter(unreadUseCase),
+		usecases.WithPresence(presenceUseCase),
+		usecases.WithInbox(inboxUseCase),
+		usecases.WithDeduplication(messageDedupeRepository, conf.Chat.DedupeWindow),
+		usecases.WithHistoryCache(historyCacheRepository, conf.Chat.HistoryCacheSize),
+		usecases.WithAttachmentSigner(attachmentSigner),
+		usecases.WithFileReferences(fileUploadUseCase),
+	)
+	connManager.SetRecipientRewriter(chatUseCase.SignForRecipient)
+	resumableUploadUseCase := usecases.NewResumableUploadUseCase(
+		resumableUploadRepository,
+		fileStorage,
+		fileUploadUseCase,
+		storageQuotaUseCase,
+		uploadValidator.MaxSize(),
+		conf.Upload.ResumableExpiry,
+	)
+	fileCollector := usecases.NewFileCollector(
+		fileStorage,
+		messageRepository,
+		fileReferenceRepository,
+		conf.Upload.GCGracePeriod,
+		conf.Upload.GCDryRun,
+	)
+	fileDownloadUseCase := usecases.NewFileDownloadUseCase(fileStorage, urlSigner, moderationUseCase)
 
 	// --- Handlers ---
-	chatHandler := handlers.NewChatHandler(chatUseCase, connManager)
-	fileUploadHandler := handlers.NewFileUploadHandler(fileUploadUseCase)
+	requireAuth := handlers.NewAuthMiddleware(authUseCase)
+	requireAdmin := handlers.NewAdminMiddleware(authUseCase)
+	authHandler := handlers.NewAuthHandler(authUseCase)
+	chatHandler := handlers.NewChatHandler(chatUseCase, moderationUseCase, inboxUseCase, connManager)
+	fileUploadHandler := handlers.NewFileUploadHandler(fileUploadUseCase, moderationUseCase)
+	resumableUploadHandler := handlers.NewResumableUploadHandler(resumableUploadUseCase, moderationUseCase)
+	fileDownloadHandler := handlers.NewFileDownloadHandler(fileDownloadUseCase)
+	moderationHandler := handlers.NewModerationHandler(moderationUseCase)
+	messageHandler := handlers.NewMessageHandler(chatUseCase)
+	unreadHandler := handlers.NewUnreadHandler(unreadUseCase)
+	presenceHandler := handlers.NewPresenceHandler(presenceUseCase)
+	storageHandler := handlers.NewStorageHandler(storageQuotaUseCase)
+
+	// Only the upload route accepts bodies larger than the default limit, leaving room for
+	// the multipart encoding around the largest accepted upload.
+	uploadBodyLimit := math.MaxInt
+	if maxSize := uploadValidator.MaxSize(); maxSize > 0 {
+		uploadBodyLimit = int(max(maxSize, 4<<20) + 1<<20)
+	}
+	app := infrastructures.NewFiber(map[string]int{"/api/v1/files/upload": uploadBodyLimit})
 
-	app := infrastructures.NewFiber()
+	// --- Metrics ---
+	// Runtime metrics and cache counters are only served to admins.
+	app.Get("/debug/vars", requireAuth, requireAdmin, expvar.New())
 
-	// --- Static File Server ---
-	app.Static("/files", uploadsPath)
+	// --- File Downloads ---
+	app.Get("/files/*", fileDownloadHandler.DownloadFile)
 
 	// --- API Routes ---
 	v1 := app.Group("/api/v1")
 	{
-		wsGroup := v1.Group("/ws")
+		authGroup := v1.Group("/auth")
+		authGroup.Post("/register", authHandler.Register)
+		authGroup.Post("/login", authHandler.Login)
+		authGroup.Post("/refresh", authHandler.Refresh)
+		authGroup.Post("/logout", authHandler.Logout)
+
+		wsGroup := v1.Group("/ws", requireAuth)
 		wsGroup.Get("/chat", chatHandler.ServeWS)
 
 		fileGroup := v1.Group("/files")
-		fileGroup.Post("/upload", fileUploadHandler.UploadFile)
+		fileGroup.Post("/upload", requireAuth, fileUploadHandler.UploadFile)
+		fileGroup.Post("/claim", requireAuth, fileUploadHandler.ClaimFile)
+
+		// OPTIONS stays public, as clients and CORS preflights use it without credentials.
+		tusGroup := fileGroup.Group("/tus", resumableUploadHandler.RequireTusResumable)
+		tusGroup.Options("/", resumableUploadHandler.Options)
+		tusGroup.Post("/", requireAuth, resumableUploadHandler.Create)
+		tusGroup.Head("/:id", requireAuth, resumableUploadHandler.Head)
+		tusGroup.Patch("/:id", requireAuth, resumableUploadHandler.Patch)
+		tusGroup.Delete("/:id", requireAuth, resumableUploadHandler.Delete)
+
+		meGroup := v1.Group("/me", requireAuth)
+		meGroup.Get("/unread", unreadHandler.GetUnread)
+		meGroup.Get("/storage", storageHandler.GetUsage)
+
+		searchGroup := v1.Group("/search", requireAuth)
+		searchGroup.Get("/messages", messageHandler.SearchMessages)
+
+		roomGroup := v1.Group("/rooms/:id", requireAuth)
+		roomGroup.Get("/messages", messageHandler.GetMessages)
+		roomGroup.Post("/messages", messageHandler.PostMessage)
+		roomGroup.Patch("/messages/:messageId", messageHandler.EditMessage)
+		roomGroup.Delete("/messages/:messageId", messageHandler.DeleteMessage)
+		roomGroup.Post("/read", unreadHandler.MarkRead)
+		roomGroup.Get("/presence", presenceHandler.GetRoomPresence)
+
+		moderationGroup := roomGroup.Group("/moderation")
+		moderationGroup.Post("/kick", moderationHandler.Kick)
+		moderationGroup.Post("/mute", moderationHandler.Mute)
+		moderationGroup.Delete("/mute/:userId", moderationHandler.Unmute)
+		moderationGroup.Post("/ban", moderationHandler.Ban)
+		moderationGroup.Delete("/ban/:userId", moderationHandler.Unban)
+		moderationGroup.Get("/audit", moderationHandler.AuditLog)
+	}
+
+	// --- Background Jobs ---
+	go presenceUseCase.Run(context.Background(), conf.Presence.SweepInterval)
+	go presenceUseCase.RunHeartbeat(context.Background(), conf.Presence.HeartbeatInterval)
+	go resumableUploadUseCase.Run(context.Background(), conf.Upload.SweepInterval)
+	if conf.Upload.GCInterval > 0 {
+		go fileCollector.Run(context.Background(), conf.Upload.GCInterval)
 	}
 
 	log.Printf("Server is running on port: %s", conf.HttpPort)
@@ -0,0 +1,95 @@
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+)
+
+// Action defines what a filter decided to do with a piece of content.
+type Action int
+
+const (
+	// ActionPass leaves the content unchanged.
+	ActionPass Action = iota
+	// ActionRewrite replaces the content with Result.Content.
+	ActionRewrite
+	// ActionFlag keeps the content but marks it for moderator review.
+	ActionFlag
+	// ActionReject refuses the content entirely.
+	ActionReject
+)
+
+// Mode defines how a built-in filter reacts to a match.
+type Mode string
+
+const (
+	ModeMask   Mode = "mask"
+	ModeFlag   Mode = "flag"
+	ModeReject Mode = "reject"
+)
+
+// Result is the decision of a single filter.
+type Result struct {
+	Action  Action
+	Content string // The rewritten content, for ActionRewrite
+	Reason  string // Why the content was flagged or rejected
+}
+
+// Filter defines the interface for a content filter.
+// Filters must be safe for concurrent use.
+type Filter interface {
+	// Name identifies the filter in flags and logs.
+	Name() string
+	// Apply inspects the content and returns the filter's decision.
+	Apply(ctx context.Context, content string) (Result, error)
+}
+
+// Flag records that a filter marked content for review.
+type Flag struct {
+	Filter string
+	Reason string
+}
+
+// Outcome is the combined decision of all filters in a pipeline.
+type Outcome struct {
+	Content  string // The content after all rewrites
+	Rejected bool
+	Reason   string // Why the content was rejected
+	Flags    []Flag
+}
+
+// Pipeline runs content through a sequence of filters.
+// Rewrites are passed on to the next filter, and the first rejection stops the pipeline.
+type Pipeline struct {
+	filters []Filter
+}
+
+// NewPipeline creates a new Pipeline from the given filters.
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Run applies every filter to the content in order.
+func (p *Pipeline) Run(ctx context.Context, content string) (*Outcome, error) {
+	outcome := &Outcome{Content: content}
+
+	for _, filter := range p.filters {
+		result, err := filter.Apply(ctx, outcome.Content)
+		if err != nil {
+			return nil, fmt.Errorf("content filter %s failed: %w", filter.Name(), err)
+		}
+
+		switch result.Action {
+		case ActionRewrite:
+			outcome.Content = result.Content
+		case ActionFlag:
+			outcome.Flags = append(outcome.Flags, Flag{Filter: filter.Name(), Reason: result.Reason})
+		case ActionReject:
+			outcome.Rejected = true
+			outcome.Reason = result.Reason
+			return outcome, nil
+		}
+	}
+
+	return outcome, nil
+}
@@ -0,0 +1,80 @@
+package contentfilter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// stubFilter returns a fixed result, and records the content it was applied to.
+type stubFilter struct {
+	name   string
+	result Result
+	err    error
+	seen   *string
+}
+
+func (f stubFilter) Name() string {
+	return f.name
+}
+
+func (f stubFilter) Apply(ctx context.Context, content string) (Result, error) {
+	if f.seen != nil {
+		*f.seen = content
+	}
+	return f.result, f.err
+}
+
+func TestPipelineRun(t *testing.T) {
+	var seen string
+	rewrite := stubFilter{name: "rewrite", result: Result{Action: ActionRewrite, Content: "rewritten"}}
+	flag := stubFilter{name: "flag", result: Result{Action: ActionFlag, Reason: "suspicious"}}
+	reject := stubFilter{name: "reject", result: Result{Action: ActionReject, Reason: "not allowed"}}
+	pass := stubFilter{name: "pass", result: Result{Action: ActionPass}, seen: &seen}
+
+	tests := []struct {
+		name         string
+		filters      []Filter
+		wantContent  string
+		wantRejected bool
+		wantReason   string
+		wantFlags    []string
+		wantSeen     string
+	}{
+		{"no filters", nil, "hello", false, "", nil, ""},
+		{"rewrite is passed on", []Filter{rewrite, pass}, "rewritten", false, "", nil, "rewritten"},
+		{"flags accumulate", []Filter{flag, rewrite, flag}, "rewritten", false, "", []string{"flag", "flag"}, ""},
+		{"reject stops the pipeline", []Filter{flag, reject, pass}, "hello", true, "not allowed", []string{"flag"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = ""
+			outcome, err := NewPipeline(tt.filters...).Run(context.Background(), "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var flags []string
+			for _, flag := range outcome.Flags {
+				flags = append(flags, flag.Filter)
+			}
+			if outcome.Content != tt.wantContent || outcome.Rejected != tt.wantRejected ||
+				outcome.Reason != tt.wantReason || !slices.Equal(flags, tt.wantFlags) {
+				t.Errorf("got %+v, want content %q, rejected %v, reason %q and flags %v",
+					outcome, tt.wantContent, tt.wantRejected, tt.wantReason, tt.wantFlags)
+			}
+			if seen != tt.wantSeen {
+				t.Errorf("last filter saw %q, want %q", seen, tt.wantSeen)
+			}
+		})
+	}
+}
+
+func TestPipelineRunFilterError(t *testing.T) {
+	failure := errors.New("service unavailable")
+	pipeline := NewPipeline(stubFilter{name: "remote", err: failure})
+
+	if _, err := pipeline.Run(context.Background(), "hello"); !errors.Is(err, failure) {
+		t.Errorf("got %v, want the filter's error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// linkPattern matches http(s) links and bare links starting with "www.".
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// blockedLinkPlaceholder replaces blocked links in ModeMask.
+const blockedLinkPlaceholder = "[link removed]"
+
+// LinkDomainFilter matches links whose host is a blocked domain or one of its subdomains.
+type LinkDomainFilter struct {
+	domains map[string]bool
+	mode    Mode
+}
+
+// NewLinkDomainFilter creates a new LinkDomainFilter.
+// In ModeMask, each blocked link is replaced by a placeholder.
+func NewLinkDomainFilter(domains []string, mode Mode) (*LinkDomainFilter, error) {
+	if mode != ModeMask && mode != ModeFlag && mode != ModeReject {
+		return nil, fmt.Errorf("unsupported link domain filter mode '%s'", mode)
+	}
+
+	blocked := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			blocked[domain] = true
+		}
+	}
+
+	return &LinkDomainFilter{domains: blocked, mode: mode}, nil
+}
+
+// Name returns the name of the filter.
+func (f *LinkDomainFilter) Name() string {
+	return "link-domain"
+}
+
+// Apply masks, flags or rejects content containing a link to a blocked domain.
+func (f *LinkDomainFilter) Apply(ctx context.Context, content string) (Result, error) {
+	if len(f.domains) == 0 {
+		return Result{Action: ActionPass}, nil
+	}
+
+	var blockedHost string
+	masked := linkPattern.ReplaceAllStringFunc(content, func(link string) string {
+		host := linkHost(link)
+		if !f.isBlocked(host) {
+			return link
+		}
+		if blockedHost == "" {
+			blockedHost = host
+		}
+		return blockedLinkPlaceholder
+	})
+
+	if blockedHost == "" {
+		return Result{Action: ActionPass}, nil
+	}
+
+	switch f.mode {
+	case ModeMask:
+		return Result{Action: ActionRewrite, Content: masked}, nil
+	case ModeFlag:
+		return Result{Action: ActionFlag, Reason: "links to blocked domain " + blockedHost}, nil
+	default:
+		return Result{Action: ActionReject, Reason: "message links to a blocked domain"}, nil
+	}
+}
+
+// isBlocked reports whether the host or any of its parent domains is blocked.
+func (f *LinkDomainFilter) isBlocked(host string) bool {
+	for host != "" {
+		if f.domains[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			return false
+		}
+		host = parent
+	}
+	return false
+}
+
+// linkHost extracts the lower-cased host name of a matched link.
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+}
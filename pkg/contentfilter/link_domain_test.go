@@ -0,0 +1,94 @@
+package contentfilter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLinkDomainFilterBlocksSubdomains(t *testing.T) {
+	filter, err := NewLinkDomainFilter([]string{"Evil.example", " .spam.test. "}, ModeMask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"evil.example", true},
+		{"www.evil.example", true},
+		{"a.b.evil.example", true},
+		{"spam.test", true},
+		{"cdn.spam.test", true},
+		{"notevil.example", false},
+		{"evil.example.org", false},
+		{"example", false},
+		{"test", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := filter.isBlocked(tt.host); got != tt.want {
+			t.Errorf("isBlocked(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestLinkDomainFilterMasksLinks(t *testing.T) {
+	filter, err := NewLinkDomainFilter([]string{"evil.example"}, ModeMask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"see https://evil.example/page", "see [link removed]"},
+		{"see HTTP://Login.EVIL.example./reset?x=1", "see [link removed]"},
+		{"www.evil.example and https://good.example", "[link removed] and https://good.example"},
+		{"https://evil.example.org/page", "https://evil.example.org/page"},
+		{"https://good.example/?next=evil.example", "https://good.example/?next=evil.example"},
+		{"evil.example without a scheme", "evil.example without a scheme"},
+	}
+	for _, tt := range tests {
+		result, err := filter.Apply(context.Background(), tt.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tt.content
+		if result.Action == ActionRewrite {
+			got = result.Content
+		}
+		if got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestLinkDomainFilterModes(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		want Action
+	}{
+		{ModeMask, ActionRewrite},
+		{ModeFlag, ActionFlag},
+		{ModeReject, ActionReject},
+	}
+	for _, tt := range tests {
+		filter, err := NewLinkDomainFilter([]string{"evil.example"}, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := filter.Apply(context.Background(), "https://sub.evil.example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != tt.want {
+			t.Errorf("mode %s: got action %v, want %v", tt.mode, result.Action, tt.want)
+		}
+	}
+
+	if _, err := NewLinkDomainFilter(nil, "drop"); err == nil {
+		t.Error("got no error for an unsupported mode")
+	}
+}
@@ -0,0 +1,99 @@
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// nonWordChar matches a character that cannot be part of a word. Go's \b only knows ASCII word
+// characters, so it would find no boundaries around words in other scripts.
+const nonWordChar = `[^\p{L}\p{N}\p{M}_]`
+
+// WordlistFilter matches whole words from a configurable list, ignoring case.
+type WordlistFilter struct {
+	pattern *regexp.Regexp
+	mode    Mode
+}
+
+// NewWordlistFilter creates a new WordlistFilter.
+// In ModeMask, each matched word is replaced by asterisks of the same length.
+func NewWordlistFilter(words []string, mode Mode) (*WordlistFilter, error) {
+	if mode != ModeMask && mode != ModeFlag && mode != ModeReject {
+		return nil, fmt.Errorf("unsupported wordlist filter mode '%s'", mode)
+	}
+
+	var quoted []string
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	// Longer words are tried first, so that a word is not cut short by a listed prefix of it.
+	sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+
+	filter := &WordlistFilter{mode: mode}
+	if len(quoted) > 0 {
+		// RE2 has no lookaround, so the boundary before a word is matched and the one after it is checked in matches.
+		filter.pattern = regexp.MustCompile(`(?i)(?:^|` + nonWordChar + `)(` + strings.Join(quoted, "|") + `)`)
+	}
+
+	return filter, nil
+}
+
+// Name returns the name of the filter.
+func (f *WordlistFilter) Name() string {
+	return "wordlist"
+}
+
+// Apply masks, flags or rejects content containing a listed word.
+func (f *WordlistFilter) Apply(ctx context.Context, content string) (Result, error) {
+	matches := f.matches(content)
+	if len(matches) == 0 {
+		return Result{Action: ActionPass}, nil
+	}
+
+	switch f.mode {
+	case ModeMask:
+		var masked strings.Builder
+		last := 0
+		for _, match := range matches {
+			masked.WriteString(content[last:match[0]])
+			masked.WriteString(strings.Repeat("*", utf8.RuneCountInString(content[match[0]:match[1]])))
+			last = match[1]
+		}
+		masked.WriteString(content[last:])
+		return Result{Action: ActionRewrite, Content: masked.String()}, nil
+	case ModeFlag:
+		return Result{Action: ActionFlag, Reason: "contains a listed word"}, nil
+	default:
+		return Result{Action: ActionReject, Reason: "message contains a prohibited word"}, nil
+	}
+}
+
+// matches returns the start and end offsets of the listed words in the content
+// that are followed by the end of the content or a non-word character.
+func (f *WordlistFilter) matches(content string) [][2]int {
+	if f.pattern == nil {
+		return nil
+	}
+
+	var matches [][2]int
+	for _, match := range f.pattern.FindAllStringSubmatchIndex(content, -1) {
+		start, end := match[2], match[3]
+		if next, _ := utf8.DecodeRuneInString(content[end:]); end < len(content) && isWordRune(next) {
+			continue
+		}
+		matches = append(matches, [2]int{start, end})
+	}
+	return matches
+}
+
+// isWordRune reports whether r can be part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
@@ -0,0 +1,57 @@
+package contentfilter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWordlistFilterMasksWholeWords(t *testing.T) {
+	filter, err := NewWordlistFilter([]string{"bad", "schön", "дурак", "bad word"}, ModeMask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"bad", "***"},
+		{"bad bad", "*** ***"},
+		{"BAD, badly, abad", "***, badly, abad"},
+		{"a bad word here", "a ******** here"},
+		{"schön und schöner", "***** und schöner"},
+		{"ты дурак!", "ты *****!"},
+		{"дураки", "дураки"},
+		{"überbad", "überbad"},
+		{"bad_name", "bad_name"},
+		{"nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		result, err := filter.Apply(context.Background(), tt.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tt.content
+		if result.Action == ActionRewrite {
+			got = result.Content
+		}
+		if got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestWordlistFilterRejects(t *testing.T) {
+	filter, err := NewWordlistFilter([]string{"straße"}, ModeReject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := filter.Apply(context.Background(), "die Straße.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != ActionReject {
+		t.Errorf("got action %v, want ActionReject", result.Action)
+	}
+}
@@ -31,6 +31,34 @@ func NewNotFoundError(message string) error {
 	}
 }
 
+func NewForbiddenError(message string) error {
+	return CustomError{
+		Message: message,
+		Code:    http.StatusForbidden,
+	}
+}
+
+func NewUnauthorizedError(message string) error {
+	return CustomError{
+		Message: message,
+		Code:    http.StatusUnauthorized,
+	}
+}
+
+func NewConflictError(message string) error {
+	return CustomError{
+		Message: message,
+		Code:    http.StatusConflict,
+	}
+}
+
+func NewPayloadTooLargeError(message string) error {
+	return CustomError{
+		Message: message,
+		Code:    http.StatusRequestEntityTooLarge,
+	}
+}
+
 func NewInternalServerError(message string) error {
 	return CustomError{
 		Message: message,
@@ -38,6 +66,13 @@ func NewInternalServerError(message string) error {
 	}
 }
 
+func NewServiceUnavailableError(message string) error {
+	return CustomError{
+		Message: message,
+		Code:    http.StatusServiceUnavailable,
+	}
+}
+
 func NewUnexpectedError() error {
 	return CustomError{
 		Message: "An unexpected error occurred",
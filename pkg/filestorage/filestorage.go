@@ -2,12 +2,52 @@ package filestorage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"strings"
+	"time"
 )
 
+var (
+	// ErrNotFound is returned when no file is stored under a key.
+	ErrNotFound = errors.New("file not found")
+	// ErrInvalidKey is returned for keys that could escape the storage, such as keys containing "..".
+	ErrInvalidKey = errors.New("invalid file key")
+)
+
+// FileInfo describes a stored file.
+type FileInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
 // FileStorage defines the interface for a file storage service.
 // This abstraction allows for easy swapping between local and cloud-based storage.
 type FileStorage interface {
 	// Upload saves a file from an io.Reader and returns its public-facing URL.
 	Upload(ctx context.Context, reader io.Reader, fileName string) (string, error)
+	// Put saves a file from an io.Reader under key, replacing any file stored under it, and returns its URL.
+	// Keys may contain "/" to group files.
+	Put(ctx context.Context, key string, reader io.Reader) (string, error)
+	// KeyFromURL returns the storage key of a URL produced by Upload.
+	// It reports false if the URL was not produced by this storage.
+	KeyFromURL(url string) (string, bool)
+	// Open returns a reader streaming the file stored under key, along with its details.
+	// The caller must close the reader.
+	Open(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error)
+	// Stat returns the details of the file stored under key.
+	Stat(ctx context.Context, key string) (*FileInfo, error)
+	// Move renames the file stored under from to key to, replacing any file stored under to, and returns its URL.
+	Move(ctx context.Context, from, to string) (string, error)
+	// Delete removes the file stored under key.
+	Delete(ctx context.Context, key string) error
+	// List returns the details of every file whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]*FileInfo, error)
+}
+
+// isValidKey reports whether a key is safe to use as a storage key.
+func isValidKey(key string) bool {
+	return key != "" && !strings.Contains(key, "..") && !strings.HasPrefix(key, "/") && !strings.ContainsAny(key, "\\?#")
 }
@@ -2,10 +2,14 @@ package filestorage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"mime"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -31,11 +35,21 @@ func NewLocalStorage(basePath, baseURL string) (FileStorage, error) {
 	}, nil
 }
 
-// Upload saves a file to the local disk and returns its public URL.
+// Upload saves a file to the local disk under a unique name and returns its public URL.
 func (s *LocalStorage) Upload(ctx context.Context, reader io.Reader, fileName string) (string, error) {
 	// Generate a unique filename to prevent collisions.
-	uniqueFileName := uuid.New().String() + filepath.Ext(fileName)
-	filePath := filepath.Join(s.basePath, uniqueFileName)
+	return s.Put(ctx, uuid.New().String()+filepath.Ext(fileName), reader)
+}
+
+// Put saves a file to the local disk and returns its public URL.
+func (s *LocalStorage) Put(ctx context.Context, key string, reader io.Reader) (string, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
 
 	// Create the file.
 	file, err := os.Create(filePath)
@@ -50,6 +64,167 @@ func (s *LocalStorage) Upload(ctx context.Context, reader io.Reader, fileName st
 	}
 
 	// Return the public URL.
-	url := fmt.Sprintf("%s/%s", s.baseURL, uniqueFileName)
+	url := fmt.Sprintf("%s/%s", s.baseURL, key)
 	return url, nil
 }
+
+// KeyFromURL returns the file name of a URL under the storage's base URL.
+func (s *LocalStorage) KeyFromURL(url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, s.baseURL+"/")
+	if !ok || !isValidKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// path returns the file path of a key.
+func (s *LocalStorage) path(key string) (string, error) {
+	if !isValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.basePath, filepath.FromSlash(key)), nil
+}
+
+// Open opens the file for reading.
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, nil, ErrNotFound
+	}
+	return file, localFileInfo(key, stat), nil
+}
+
+// Stat returns the details of the file.
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*FileInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return localFileInfo(key, stat), nil
+}
+
+// Move renames the file on the disk.
+func (s *LocalStorage) Move(ctx context.Context, from, to string) (string, error) {
+	fromPath, err := s.path(from)
+	if err != nil {
+		return "", err
+	}
+	toPath, err := s.path(to)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(toPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(fromPath, toPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to move file: %w", err)
+	}
+	return fmt.Sprintf("%s/%s", s.baseURL, to), nil
+}
+
+// Delete removes the file from the disk.
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// List walks the directory of the prefix, rather than the whole base path, and returns the files whose
+// key starts with prefix. A prefix whose directory does not exist has no files.
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]*FileInfo, error) {
+	if strings.Contains(prefix, "..") || strings.HasPrefix(prefix, "/") {
+		return nil, ErrInvalidKey
+	}
+	// The part of the prefix up to its last "/" is a directory, e.g. "sha256/ab/" for "sha256/ab/ab12".
+	root := filepath.Join(s.basePath, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+
+	var files []*FileInfo
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.basePath, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, localFileInfo(key, stat))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return files, nil
+}
+
+// localFileInfo builds the details of a file on disk. The content type is derived from the extension.
+func localFileInfo(key string, stat fs.FileInfo) *FileInfo {
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &FileInfo{
+		Key:         key,
+		Size:        stat.Size(),
+		ContentType: contentType,
+		ModTime:     stat.ModTime(),
+	}
+}
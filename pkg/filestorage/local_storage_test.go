@@ -0,0 +1,60 @@
+package filestorage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageList(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewLocalStorage(t.TempDir(), "/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{
+		"photo.png",
+		"sha256/ab/ab12.png",
+		"sha256/ab/ab12_256.jpg",
+		"sha256/ab/ab34.png",
+		"sha256/cd/cd56.png",
+		"tus/upload-1/0-chunk",
+	} {
+		if _, err := storage.Put(ctx, key, strings.NewReader("content")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"photo.png", "sha256/ab/ab12.png", "sha256/ab/ab12_256.jpg", "sha256/ab/ab34.png", "sha256/cd/cd56.png", "tus/upload-1/0-chunk"}},
+		{"sha256/ab/ab12", []string{"sha256/ab/ab12.png", "sha256/ab/ab12_256.jpg"}},
+		{"sha256/", []string{"sha256/ab/ab12.png", "sha256/ab/ab12_256.jpg", "sha256/ab/ab34.png", "sha256/cd/cd56.png"}},
+		{"sha256/c", []string{"sha256/cd/cd56.png"}},
+		{"tus/", []string{"tus/upload-1/0-chunk"}},
+		{"sha256/ef/ef78", nil},
+		{"missing/", nil},
+	} {
+		files, err := storage.List(ctx, tt.prefix)
+		if err != nil {
+			t.Errorf("%q: %v", tt.prefix, err)
+			continue
+		}
+		var keys []string
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.prefix, keys, tt.want)
+		}
+	}
+
+	if _, err := storage.List(ctx, "../"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("prefix escaping the base path: got %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,271 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// defaultPartSize is the size of the parts of multipart uploads if none is configured.
+	defaultPartSize = 16 << 20
+	// maxPresignExpiry is the longest validity S3 accepts for presigned URLs.
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// S3Config holds the settings of an S3-compatible object store.
+type S3Config struct {
+	Endpoint        string // Host and optional port, e.g. "s3.eu-west-1.amazonaws.com" or "localhost:9000"
+	Region          string
+	Bucket          string
+	Prefix          string // Prepended to every object name, e.g. "uploads/"
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	PathStyle       bool          // Address the bucket in the path instead of the host name, as MinIO expects
+	PublicBaseURL   string        // If set, files are linked as PublicBaseURL/<object name>; otherwise presigned URLs are used
+	PresignExpiry   time.Duration // Validity of presigned URLs, at most 7 days
+	PartSize        uint64        // Size of the parts of multipart uploads, at least 5 MiB
+}
+
+// S3Storage implements the FileStorage interface on top of an S3-compatible object store,
+// so that every node of the cluster shares the same files. It works against AWS S3, MinIO,
+// or an in-process fake served over HTTP.
+type S3Storage struct {
+	client    *minio.Client
+	config    S3Config
+	urlPrefix string // Every URL produced by the storage starts with it, followed by the object name
+}
+
+// NewS3Storage creates a new S3Storage and checks that the bucket exists.
+func NewS3Storage(ctx context.Context, config S3Config) (FileStorage, error) {
+	lookup := minio.BucketLookupDNS
+	if config.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+		Secure:       config.UseSSL,
+		Region:       config.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", config.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", config.Bucket)
+	}
+
+	if config.PartSize == 0 {
+		config.PartSize = defaultPartSize
+	}
+	if config.PresignExpiry <= 0 || config.PresignExpiry > maxPresignExpiry {
+		config.PresignExpiry = maxPresignExpiry
+	}
+
+	return &S3Storage{
+		client:    client,
+		config:    config,
+		urlPrefix: s3URLPrefix(client.EndpointURL(), config),
+	}, nil
+}
+
+// s3URLPrefix returns the part of the object URLs that precedes the object name.
+func s3URLPrefix(endpoint *url.URL, config S3Config) string {
+	if config.PublicBaseURL != "" {
+		return strings.TrimSuffix(config.PublicBaseURL, "/") + "/"
+	}
+	if config.PathStyle {
+		return endpoint.Scheme + "://" + endpoint.Host + "/" + config.Bucket + "/"
+	}
+	return endpoint.Scheme + "://" + config.Bucket + "." + endpoint.Host + "/"
+}
+
+// objectName returns the name of the object stored under a key.
+func (s *S3Storage) objectName(key string) string {
+	return s.config.Prefix + key
+}
+
+// Upload streams the file to the bucket under a unique name and returns its URL.
+func (s *S3Storage) Upload(ctx context.Context, reader io.Reader, fileName string) (string, error) {
+	return s.Put(ctx, uuid.New().String()+filepath.Ext(fileName), reader)
+}
+
+// Put streams the file to the bucket, using a multipart upload for files larger than one part,
+// and returns its URL.
+func (s *S3Storage) Put(ctx context.Context, key string, reader io.Reader) (string, error) {
+	if !isValidKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	opts := minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		PartSize:    s.config.PartSize,
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "application/octet-stream"
+	}
+
+	// The size is unknown, so the client buffers one part at a time.
+	if _, err := s.client.PutObject(ctx, s.config.Bucket, s.objectName(key), reader, -1, opts); err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	return s.url(ctx, key)
+}
+
+// url returns the public URL of a key, or a presigned URL if the bucket is private.
+func (s *S3Storage) url(ctx context.Context, key string) (string, error) {
+	if s.config.PublicBaseURL != "" {
+		return s.urlPrefix + s.objectName(key), nil
+	}
+
+	presigned, err := s.client.PresignedGetObject(ctx, s.config.Bucket, s.objectName(key), s.config.PresignExpiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign URL: %w", err)
+	}
+	return presigned.String(), nil
+}
+
+// KeyFromURL returns the key of a public or presigned URL of the bucket.
+// The signature of presigned URLs is not checked, since S3 checks it on download.
+func (s *S3Storage) KeyFromURL(rawURL string) (string, bool) {
+	if s.config.PublicBaseURL == "" {
+		// Drop the signature of presigned URLs.
+		rawURL, _, _ = strings.Cut(rawURL, "?")
+	}
+
+	objectName, ok := strings.CutPrefix(rawURL, s.urlPrefix)
+	if !ok {
+		return "", false
+	}
+	key, ok := strings.CutPrefix(objectName, s.config.Prefix)
+	if !ok || !isValidKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// Open streams the object from the bucket.
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	if !isValidKey(key) {
+		return nil, nil, ErrInvalidKey
+	}
+
+	object, err := s.client.GetObject(ctx, s.config.Bucket, s.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	// The object is fetched lazily, so errors such as a missing key only surface here.
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, s3Error(err)
+	}
+	return object, s.fileInfo(stat), nil
+}
+
+// Stat reads the object's metadata.
+func (s *S3Storage) Stat(ctx context.Context, key string) (*FileInfo, error) {
+	if !isValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	stat, err := s.client.StatObject(ctx, s.config.Bucket, s.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return s.fileInfo(stat), nil
+}
+
+// Move copies the object on the server and removes the original, as S3 cannot rename objects.
+func (s *S3Storage) Move(ctx context.Context, from, to string) (string, error) {
+	if !isValidKey(from) || !isValidKey(to) {
+		return "", ErrInvalidKey
+	}
+
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.config.Bucket, Object: s.objectName(to)},
+		minio.CopySrcOptions{Bucket: s.config.Bucket, Object: s.objectName(from)},
+	)
+	if err != nil {
+		return "", s3Error(err)
+	}
+	if err := s.client.RemoveObject(ctx, s.config.Bucket, s.objectName(from), minio.RemoveObjectOptions{}); err != nil {
+		return "", fmt.Errorf("failed to delete object: %w", err)
+	}
+	return s.url(ctx, to)
+}
+
+// Delete removes the object. S3 deletes are idempotent, so the object is checked first
+// to report missing keys like the other backends.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	if err := s.client.RemoveObject(ctx, s.config.Bucket, s.objectName(key), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// List returns the objects under the storage prefix followed by prefix.
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]*FileInfo, error) {
+	// Cancelling stops the listing goroutine if the loop returns early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var files []*FileInfo
+	objects := s.client.ListObjects(ctx, s.config.Bucket, minio.ListObjectsOptions{
+		Prefix:    s.objectName(prefix),
+		Recursive: true,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		files = append(files, s.fileInfo(object))
+	}
+	return files, nil
+}
+
+// fileInfo converts object metadata to file details.
+func (s *S3Storage) fileInfo(object minio.ObjectInfo) *FileInfo {
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &FileInfo{
+		Key:         strings.TrimPrefix(object.Key, s.config.Prefix),
+		Size:        object.Size,
+		ContentType: contentType,
+		ModTime:     object.LastModified,
+	}
+}
+
+// s3Error maps a missing object to ErrNotFound.
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return fmt.Errorf("failed to stat object: %w", err)
+}
@@ -0,0 +1,131 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks streamed to clamd. It must stay below clamd's StreamMaxLength.
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner scans content with a ClamAV daemon using the INSTREAM command.
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner creates a new ClamdScanner.
+// The address is either "tcp://host:port" or "unix:///path/to/clamd.sock"; a plain path is treated as a unix socket.
+// The timeout applies to each scan, including the connection.
+func NewClamdScanner(address string, timeout time.Duration) (*ClamdScanner, error) {
+	network, addr := "unix", address
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		network, addr = "tcp", strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		addr = strings.TrimPrefix(address, "unix://")
+	case !strings.HasPrefix(address, "/"):
+		return nil, fmt.Errorf("invalid clamd address %q", address)
+	}
+
+	return &ClamdScanner{
+		network: network,
+		address: addr,
+		timeout: timeout,
+	}, nil
+}
+
+// Scan streams the content to clamd in length-prefixed chunks and parses its reply,
+// such as "stream: OK" or "stream: Eicar-Test-Signature FOUND".
+func (s *ClamdScanner) Scan(ctx context.Context, reader io.Reader) (*Result, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The "z" prefix makes clamd expect and send null-terminated messages.
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send command to clamd: %w", err)
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(reader, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection once the stream exceeds its size limit, and says so.
+				if reply, replyErr := readClamdReply(conn); replyErr == nil {
+					return parseClamdReply(reply)
+				}
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	// A zero-length chunk ends the stream.
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+	}
+
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseClamdReply(reply)
+}
+
+// readClamdReply reads a null-terminated reply.
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	reply, _, _ = bytes.Cut(reply, []byte{0})
+	return strings.TrimSpace(string(reply)), nil
+}
+
+// parseClamdReply converts a reply to a result.
+func parseClamdReply(reply string) (*Result, error) {
+	// Replies are prefixed with the scanned stream, e.g. "stream: ".
+	_, verdict, found := strings.Cut(reply, ": ")
+	if !found {
+		verdict = reply
+	}
+
+	switch {
+	case verdict == "OK":
+		return &Result{Clean: true}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Threat: strings.TrimSuffix(verdict, " FOUND")}, nil
+	case strings.HasPrefix(verdict, "INSTREAM size limit exceeded"):
+		return nil, fmt.Errorf("%w: clamd replied %s", ErrTooLarge, reply)
+	default:
+		return nil, fmt.Errorf("clamd failed to scan: %s", reply)
+	}
+}
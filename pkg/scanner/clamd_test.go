@@ -0,0 +1,33 @@
+package scanner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseClamdReply(t *testing.T) {
+	for _, tt := range []struct {
+		reply      string
+		wantClean  bool
+		wantThreat string
+		wantErr    error // Checked with errors.Is; any error if set to errAny
+	}{
+		{"stream: OK", true, "", nil},
+		{"stream: Eicar-Test-Signature FOUND", false, "Eicar-Test-Signature", nil},
+		{"INSTREAM size limit exceeded. ERROR", false, "", ErrTooLarge},
+		{"stream: lstat() failed: No such file or directory. ERROR", false, "", errAny},
+	} {
+		result, err := parseClamdReply(tt.reply)
+		if tt.wantErr != nil {
+			if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("%q: got %+v, %v, want error %v", tt.reply, result, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || result.Clean != tt.wantClean || result.Threat != tt.wantThreat {
+			t.Errorf("%q: got %+v, %v, want clean %v and threat %q", tt.reply, result, err, tt.wantClean, tt.wantThreat)
+		}
+	}
+}
+
+var errAny = errors.New("any error")
@@ -0,0 +1,41 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// EICAR is the standard antivirus test file, which real scanners detect as malware.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// FakeScanner is a Scanner for tests. It reports content containing one of its signatures as infected.
+type FakeScanner struct {
+	signatures map[string]string
+}
+
+// NewFakeScanner creates a FakeScanner detecting the EICAR test file and the given signatures,
+// which map byte sequences to threat names.
+func NewFakeScanner(signatures map[string]string) *FakeScanner {
+	all := map[string]string{EICAR: "Eicar-Test-Signature"}
+	for signature, threat := range signatures {
+		all[signature] = threat
+	}
+	return &FakeScanner{
+		signatures: all,
+	}
+}
+
+// Scan reads the whole content and searches it for the signatures.
+func (s *FakeScanner) Scan(ctx context.Context, reader io.Reader) (*Result, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	for signature, threat := range s.signatures {
+		if bytes.Contains(content, []byte(signature)) {
+			return &Result{Threat: threat}, nil
+		}
+	}
+	return &Result{Clean: true}, nil
+}
@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrTooLarge is returned by scanners when the content exceeds the size they accept.
+var ErrTooLarge = errors.New("content is too large to scan")
+
+// Result is the verdict of a scan.
+type Result struct {
+	Clean  bool
+	Threat string // The name of the detected malware, if the content is not clean
+}
+
+// Scanner defines the interface for a malware scanner.
+// Scanners must be safe for concurrent use.
+type Scanner interface {
+	// Scan reads the content and reports whether it is clean. An error means that the content
+	// could not be scanned, not that it is infected.
+	Scan(ctx context.Context, reader io.Reader) (*Result, error)
+}
@@ -0,0 +1,146 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+)
+
+// ErrTooLarge is returned for images with more pixels than the generator accepts.
+var ErrTooLarge = errors.New("image is too large to generate thumbnails")
+
+// jpegQuality is the quality of JPEG thumbnails.
+const jpegQuality = 80
+
+// Thumbnail is an encoded, resized copy of an image.
+type Thumbnail struct {
+	Size        int // The bounding box the image was fitted into
+	Width       int
+	Height      int
+	ContentType string
+	Extension   string // Including the dot, e.g. ".jpg"
+	Data        []byte
+}
+
+// Generator creates thumbnails of images.
+// Generators must be safe for concurrent use.
+type Generator interface {
+	// Generate decodes an image and returns a thumbnail for each configured size that is smaller
+	// than the image. GIF, JPEG, PNG and WebP images are supported; animated GIFs use their first frame.
+	Generate(reader io.Reader) ([]*Thumbnail, error)
+}
+
+type generator struct {
+	sizes     []int
+	maxPixels int
+	slots     chan struct{} // Bounds the images decoded at once
+}
+
+// NewGenerator creates a Generator fitting images into squares of the given sizes, in pixels.
+// Thumbnails are returned smallest first.
+// Images with more than maxPixels pixels are refused before they are decoded; zero disables the limit.
+// At most concurrency images are decoded at once, since each takes 4 bytes per pixel; other calls wait.
+func NewGenerator(sizes []int, maxPixels, concurrency int) (Generator, error) {
+	for _, size := range sizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid thumbnail size %d", size)
+		}
+	}
+	if concurrency <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail concurrency %d", concurrency)
+	}
+	sorted := slices.Clone(sizes)
+	slices.Sort(sorted)
+
+	return &generator{
+		sizes:     sorted,
+		maxPixels: maxPixels,
+		slots:     make(chan struct{}, concurrency),
+	}, nil
+}
+
+// Generate resizes the image to each size.
+func (g *generator) Generate(reader io.Reader) ([]*Thumbnail, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the dimensions first, so that a small file cannot make us allocate a huge image.
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if g.maxPixels > 0 && config.Width*config.Height > g.maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	g.slots <- struct{}{}
+	defer func() { <-g.slots }()
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var thumbnails []*Thumbnail
+	for _, size := range g.sizes {
+		width, height := fit(src.Bounds().Dx(), src.Bounds().Dy(), size)
+		if width == src.Bounds().Dx() && height == src.Bounds().Dy() {
+			continue // Never upscale
+		}
+
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+		thumbnail, err := encode(dst, format)
+		if err != nil {
+			return nil, err
+		}
+		thumbnail.Size = size
+		thumbnail.Width = width
+		thumbnail.Height = height
+		thumbnails = append(thumbnails, thumbnail)
+	}
+	return thumbnails, nil
+}
+
+// fit returns the dimensions of an image scaled down to fit into a square, keeping its aspect ratio.
+func fit(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
+
+// encode encodes a thumbnail as JPEG for JPEG sources, and as PNG otherwise to keep transparency.
+func encode(img image.Image, format string) (*Thumbnail, error) {
+	var buf bytes.Buffer
+	thumbnail := &Thumbnail{}
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		thumbnail.ContentType, thumbnail.Extension = "image/jpeg", ".jpg"
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		thumbnail.ContentType, thumbnail.Extension = "image/png", ".png"
+	}
+	thumbnail.Data = buf.Bytes()
+	return thumbnail, nil
+}
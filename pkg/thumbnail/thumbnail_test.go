@@ -0,0 +1,64 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+// encodePNG returns a blank PNG image of the given dimensions.
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerate(t *testing.T) {
+	generator, err := NewGenerator([]int{1024, 256}, 4_000_000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnails, err := generator.Generate(bytes.NewReader(encodePNG(t, 800, 400)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The image already fits into 1024 pixels, so only the smaller thumbnail is generated.
+	if len(thumbnails) != 1 || thumbnails[0].Width != 256 || thumbnails[0].Height != 128 || thumbnails[0].ContentType != "image/png" {
+		t.Fatalf("got %+v, want a single 256x128 PNG", thumbnails)
+	}
+
+	if _, err := generator.Generate(bytes.NewReader(encodePNG(t, 2001, 2000))); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("image over the pixel limit: got %v, want ErrTooLarge", err)
+	}
+}
+
+func TestGenerateConcurrently(t *testing.T) {
+	generator, err := NewGenerator([]int{64}, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := encodePNG(t, 300, 300)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := generator.Generate(bytes.NewReader(data)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := NewGenerator([]int{64}, 0, 0); err == nil {
+		t.Fatal("NewGenerator accepted a concurrency of 0")
+	}
+}
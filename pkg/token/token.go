@@ -0,0 +1,100 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when an access token is malformed, tampered with or expired.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are the claims carried by an access token.
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Manager issues and verifies short-lived, HMAC-signed JWT access tokens.
+type Manager struct {
+	secret    []byte
+	issuer    string
+	accessTTL time.Duration
+}
+
+// NewManager creates a new Manager.
+// - secret is the HMAC key used to sign access tokens.
+// - issuer is written to and required in the "iss" claim.
+// - accessTTL is the lifetime of access tokens.
+func NewManager(secret, issuer string, accessTTL time.Duration) *Manager {
+	return &Manager{
+		secret:    []byte(secret),
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}
+}
+
+// IssueAccessToken creates a signed access token for a user and returns it with its expiry time.
+func (m *Manager) IssueAccessToken(userID, role string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.accessTTL)
+
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    m.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken verifies an access token and returns its claims.
+func (m *Manager) ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// AccessTTL returns the lifetime of access tokens.
+func (m *Manager) AccessTTL() time.Duration {
+	return m.accessTTL
+}
+
+// NewRefreshToken generates an opaque, random refresh token.
+func NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashRefreshToken returns the SHA-256 digest under which a refresh token is stored,
+// so that a leaked token store cannot be used to refresh sessions.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
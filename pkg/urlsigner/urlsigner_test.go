@@ -0,0 +1,85 @@
+package urlsigner
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignerVerify(t *testing.T) {
+	signer := NewSigner("file-url-secret")
+	claims := Claims{UserID: "user-1", RoomID: "room-1", ExpiresAt: time.Now().Add(time.Hour)}
+	signed, err := signer.Sign("https://chat.example.com/files/sha256/ab/ab12.png", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		signer  *Signer
+		claims  Claims
+		change  func(u *url.URL)
+		wantErr error
+	}{
+		{"valid", signer, claims, func(u *url.URL) {}, nil},
+		{"other host", signer, claims, func(u *url.URL) { u.Host = "proxy.internal" }, nil},
+		{"tampered path", signer, claims, func(u *url.URL) { u.Path = "/files/sha256/cd/cd56.png" }, ErrInvalidSignature},
+		{"tampered expiry", signer, claims, setParam(paramExpires, "4102444800"), ErrInvalidSignature},
+		{"other user", signer, claims, setParam(paramUser, "user-2"), ErrInvalidSignature},
+		{"other room", signer, claims, setParam(paramRoom, "room-2"), ErrInvalidSignature},
+		{"user removed", signer, claims, setParam(paramUser, ""), ErrInvalidSignature},
+		{"missing signature", signer, claims, setParam(paramSignature, ""), ErrInvalidSignature},
+		{"other key", NewSigner("jwt-secret"), claims, func(u *url.URL) {}, ErrInvalidSignature},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(u)
+
+			got, err := tt.signer.Verify(u.EscapedPath(), u.Query())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.UserID != tt.claims.UserID || got.RoomID != tt.claims.RoomID || got.ExpiresAt.Unix() != tt.claims.ExpiresAt.Unix() {
+				t.Fatalf("got %+v, want %+v", got, tt.claims)
+			}
+		})
+	}
+}
+
+func TestSignerVerifyExpired(t *testing.T) {
+	signer := NewSigner("file-url-secret")
+	signed, err := signer.Sign("/files/photo.png", Claims{UserID: "user-1", RoomID: "room-1", ExpiresAt: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.Verify(u.EscapedPath(), u.Query()); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got %v, want ErrExpired", err)
+	}
+}
+
+// setParam returns a change that sets a query parameter, or removes it if value is empty.
+func setParam(name, value string) func(u *url.URL) {
+	return func(u *url.URL) {
+		query := u.Query()
+		if value == "" {
+			query.Del(name)
+		} else {
+			query.Set(name, value)
+		}
+		u.RawQuery = query.Encode()
+	}
+}
@@ -2,6 +2,7 @@ package ws
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
@@ -13,19 +14,25 @@ type Client struct {
 	ID string
 	// Conn is the underlying WebSocket connection.
 	Conn *websocket.Conn
-	// Send is a buffered channel of outbound messages.
+	// Send is a buffered channel of outbound messages. It is closed by Close, never directly.
 	Send chan []byte
+	// mu guards closed, so that no message is sent on a closed channel.
+	mu     sync.Mutex
+	closed bool
 	// handler holds the parent ConnectionManager.
 	handler *ConnectionManager
 	RoomID  string // Add RoomID to track which room the client is in
 }
 
 // NewClient creates a new Client instance.
-// It extracts the client ID from the query parameter "id".
+// It extracts the client ID from the query parameter "userId" and the room from "roomId".
 func NewClient(conn *websocket.Conn, handler *ConnectionManager) *Client {
-	clientID := conn.Query("userId")
-	roomID := conn.Query("roomId")
+	return NewClientWithID(conn, handler, conn.Query("userId"), conn.Query("roomId"))
+}
 
+// NewClientWithID creates a new Client instance with an ID established by the caller,
+// e.g. from an authenticated token.
+func NewClientWithID(conn *websocket.Conn, handler *ConnectionManager, clientID, roomID string) *Client {
 	return &Client{
 		ID:      clientID,
 		Conn:    conn,
@@ -108,7 +115,14 @@ func (c *Client) WritePump() {
 }
 
 // SendMessage writes a message directly to the client's send channel.
+// Messages for a closed client are dropped.
 func (c *Client) SendMessage(message []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
 	select {
 	case c.Send <- message:
 	default:
@@ -116,6 +130,18 @@ func (c *Client) SendMessage(message []byte) {
 	}
 }
 
+// Close closes the send channel, which makes the write pump close the connection.
+// It is safe to call more than once and concurrently with SendMessage.
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
 // GetID returns the client ID
 func (c *Client) GetID() string {
 	return c.ID
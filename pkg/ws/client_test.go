@@ -0,0 +1,34 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSendMessageAfterCloseDoesNotPanic(t *testing.T) {
+	client := &Client{Send: make(chan []byte, 1)}
+	client.Close()
+	client.Close()
+	client.SendMessage([]byte("dropped"))
+
+	if _, ok := <-client.Send; ok {
+		t.Fatal("message was queued on a closed client")
+	}
+}
+
+func TestSendMessageConcurrentWithClose(t *testing.T) {
+	client := &Client{Send: make(chan []byte, 16)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.SendMessage([]byte("message"))
+			}
+		}()
+	}
+	client.Close()
+	wg.Wait()
+}
@@ -22,13 +22,19 @@ type Hub struct {
 	Unregister    chan *Client
 	Broadcast     chan RoomMessage
 	DirectMessage chan DirectMessage // Added for sending to a specific client
+	Disconnect    chan Disconnect    // Forcibly closes a client's connections
+	rewrite       RecipientRewriter  // Adapts per-recipient broadcasts to each client
 	mu            sync.Mutex
 }
 
+// RecipientRewriter returns the copy of a room broadcast that is sent to one of the room's clients.
+type RecipientRewriter func(clientID, roomID string, message []byte) []byte
+
 // RoomMessage is a message to be broadcast to a specific room.
 type RoomMessage struct {
-	RoomID  string
-	Message []byte
+	RoomID       string
+	Message      []byte
+	PerRecipient bool // Rewritten for each client by the hub's RecipientRewriter
 }
 
 // DirectMessage is a message to be sent to a specific client.
@@ -37,6 +43,13 @@ type DirectMessage struct {
 	Message  []byte
 }
 
+// Disconnect is a request to close the connections of a client.
+// An empty RoomID closes the client's connections in every room.
+type Disconnect struct {
+	ClientID string
+	RoomID   string
+}
+
 // NewHub creates a new Hub instance.
 func NewHub() *Hub {
 	return &Hub{
@@ -46,6 +59,7 @@ func NewHub() *Hub {
 		Unregister:    make(chan *Client),
 		Broadcast:     make(chan RoomMessage),
 		DirectMessage: make(chan DirectMessage),
+		Disconnect:    make(chan Disconnect),
 	}
 }
 
@@ -71,7 +85,7 @@ func (h *Hub) Run() {
 			if room, ok := h.Rooms[client.RoomID]; ok {
 				if _, exists := room.Clients[client]; exists {
 					delete(room.Clients, client)
-					close(client.Send)
+					client.Close()
 					if len(room.Clients) == 0 {
 						delete(h.Rooms, client.RoomID)
 					}
@@ -85,6 +99,10 @@ func (h *Hub) Run() {
 			h.mu.Lock()
 			if room, ok := h.Rooms[roomMsg.RoomID]; ok {
 				for client := range room.Clients {
+					if roomMsg.PerRecipient && h.rewrite != nil {
+						client.SendMessage(h.rewrite(client.ID, roomMsg.RoomID, roomMsg.Message))
+						continue
+					}
 					client.SendMessage(roomMsg.Message)
 				}
 			}
@@ -96,6 +114,30 @@ func (h *Hub) Run() {
 				client.SendMessage(directMsg.Message)
 			}
 			h.mu.Unlock()
+
+		case disconnect := <-h.Disconnect:
+			h.mu.Lock()
+			for roomID, room := range h.Rooms {
+				if disconnect.RoomID != "" && roomID != disconnect.RoomID {
+					continue
+				}
+				for client := range room.Clients {
+					if client.ID != disconnect.ClientID {
+						continue
+					}
+					// Closing the send channel makes the write pump close the connection,
+					// which in turn ends the client's read loop.
+					delete(room.Clients, client)
+					client.Close()
+					if h.ClientsByID[client.ID] == client {
+						delete(h.ClientsByID, client.ID)
+					}
+				}
+				if len(room.Clients) == 0 {
+					delete(h.Rooms, roomID)
+				}
+			}
+			h.mu.Unlock()
 		}
 	}
 }
@@ -123,8 +165,14 @@ type SyncMessage struct {
 	ClientID string `json:"client_id,omitempty"` // Can be empty if it's a room broadcast
 	RoomID   string `json:"room_id"`
 	Data     []byte `json:"data"`
+	Action   string `json:"action,omitempty"` // Empty for regular message delivery
+
+	PerRecipient bool `json:"per_recipient,omitempty"` // Rewritten for each client of the room
 }
 
+// syncActionDisconnect asks every node to close the connections of a client.
+const syncActionDisconnect = "disconnect"
+
 // NewConnectionManager initializes a new ConnectionManager with its hub and message broker.
 func NewConnectionManager(opts ...Option) *ConnectionManager {
 	// Start with a default configuration
@@ -172,11 +220,16 @@ func (cm *ConnectionManager) handleSyncMessage(data []byte) {
 		return
 	}
 
+	if syncMsg.Action == syncActionDisconnect {
+		cm.hub.Disconnect <- Disconnect{ClientID: syncMsg.ClientID, RoomID: syncMsg.RoomID}
+		return
+	}
+
 	// If ClientID is present, it's a direct message. Otherwise, broadcast to the room.
 	if syncMsg.ClientID != "" {
 		cm.hub.DirectMessage <- DirectMessage{ClientID: syncMsg.ClientID, Message: syncMsg.Data}
 	} else {
-		cm.hub.Broadcast <- RoomMessage{RoomID: syncMsg.RoomID, Message: syncMsg.Data}
+		cm.hub.Broadcast <- RoomMessage{RoomID: syncMsg.RoomID, Message: syncMsg.Data, PerRecipient: syncMsg.PerRecipient}
 	}
 }
 
@@ -203,6 +256,28 @@ func (cm *ConnectionManager) BroadcastToRoom(roomID string, message []byte) {
 	}
 }
 
+// BroadcastToRoomRecipients sends a message to all clients in a room, rewritten for each of them
+// by the RecipientRewriter. Without a rewriter, every client receives the message as it is.
+// If auto-sync is enabled, it publishes the message to the message broker.
+func (cm *ConnectionManager) BroadcastToRoomRecipients(roomID string, message []byte) {
+	if cm.config.EnableAutoSync {
+		syncMsg := SyncMessage{RoomID: roomID, Data: message, PerRecipient: true}
+		if err := cm.broker.Publish(context.Background(), cm.config.SyncChannel, syncMsg); err != nil {
+			log.Printf("Failed to publish sync message: %v", err)
+		}
+	} else {
+		cm.hub.Broadcast <- RoomMessage{RoomID: roomID, Message: message, PerRecipient: true}
+	}
+}
+
+// SetRecipientRewriter sets the function that adapts per-recipient broadcasts to each client.
+// It is set after construction, since the rewriter usually depends on the manager itself.
+func (cm *ConnectionManager) SetRecipientRewriter(rewrite RecipientRewriter) {
+	cm.hub.mu.Lock()
+	defer cm.hub.mu.Unlock()
+	cm.hub.rewrite = rewrite
+}
+
 // SendMessage sends a message directly to a specific client by their ID.
 func (cm *ConnectionManager) SendMessage(clientID string, message []byte) error {
 	if cm.config.EnableAutoSync {
@@ -224,6 +299,19 @@ func (cm *ConnectionManager) SendMessage(clientID string, message []byte) error
 	return nil
 }
 
+// DisconnectClient closes all connections of a client in a room, or in every room if roomID is empty.
+// If auto-sync is enabled, the request is published so that every node closes its connections.
+func (cm *ConnectionManager) DisconnectClient(clientID, roomID string) {
+	if cm.config.EnableAutoSync {
+		syncMsg := SyncMessage{ClientID: clientID, RoomID: roomID, Action: syncActionDisconnect}
+		if err := cm.broker.Publish(context.Background(), cm.config.SyncChannel, syncMsg); err != nil {
+			log.Printf("Failed to publish disconnect sync message: %v", err)
+		}
+	} else {
+		cm.hub.Disconnect <- Disconnect{ClientID: clientID, RoomID: roomID}
+	}
+}
+
 // Close closes the WebSocket handler and message broker
 func (cm *ConnectionManager) Close() error {
 	return cm.broker.Close()
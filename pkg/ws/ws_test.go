@@ -0,0 +1,34 @@
+package ws
+
+import (
+	"testing"
+)
+
+func TestBroadcastToRoomRecipients(t *testing.T) {
+	manager := NewConnectionManager()
+	manager.SetRecipientRewriter(func(clientID, roomID string, message []byte) []byte {
+		return []byte(string(message) + " for " + clientID + " in " + roomID)
+	})
+
+	alice := &Client{ID: "user-1", RoomID: "room-1", Send: make(chan []byte, 2)}
+	bob := &Client{ID: "user-2", RoomID: "room-1", Send: make(chan []byte, 2)}
+	manager.RegisterClient(alice)
+	manager.RegisterClient(bob)
+
+	manager.BroadcastToRoomRecipients("room-1", []byte("file"))
+	manager.BroadcastToRoom("room-1", []byte("text"))
+
+	for _, tt := range []struct {
+		client *Client
+		want   []string
+	}{
+		{alice, []string{"file for user-1 in room-1", "text"}},
+		{bob, []string{"file for user-2 in room-1", "text"}},
+	} {
+		for _, want := range tt.want {
+			if got := string(<-tt.client.Send); got != want {
+				t.Errorf("%s: got %q, want %q", tt.client.ID, got, want)
+			}
+		}
+	}
+}